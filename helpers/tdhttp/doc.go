@@ -38,6 +38,72 @@
 //
 // See the full example below.
 //
+// Requests can be chained using captured variables:
+//
+//   ta.PostJSON("/person", Person{Name: "Bob", Age: 26}).
+//     CmpStatus(http.StatusCreated).
+//     Capture("id", "/id")
+//
+//   ta.Get("/person/{id}").
+//     CmpStatus(http.StatusOK)
+//
+// CmpBody can also unmarshal the body by itself, depending on the
+// response Content-Type and Content-Encoding headers. See
+// RegisterMediaType and RegisterContentEncoding to extend it.
+//
+// Each request and response can also be checked against an OpenAPI 3
+// spec, each violation being reported as a test failure:
+//
+//   spec, err := tdhttp.LoadOpenAPI("openapi.yaml")
+//   td.Require(t).CmpNoError(err)
+//   ta := tdhttp.NewTestAPI(t, mux).OpenAPI(spec)
+//
+// GraphQL APIs can be tested using PostGraphQL, CmpGraphQLData and
+// CmpGraphQLErrors:
+//
+//   ta.PostGraphQL("/graphql", tdhttp.GraphQLQuery{
+//     Query:     `query($id: ID!) { user(id: $id) { name } }`,
+//     Variables: map[string]interface{}{"id": 42},
+//   }).
+//     CmpGraphQLData(td.JSON(`{"user": {"name": "Bob"}}`))
+//
+// JSON-RPC 2.0 services can be tested using JSONRPC, JSONRPCNotify
+// and JSONRPCBatch, ids being automatically handled:
+//
+//   ta.JSONRPC("/rpc", "subtract", []int{42, 23}).
+//     CmpJSONRPCResult(19)
+//
+// A middleware can be tested using NewMiddlewareTestAPI, the
+// request received by its next handler being tested by CmpNext*
+// methods:
+//
+//   tdhttp.NewMiddlewareTestAPI(t, StripPrefix, nil).
+//     Get("/api/v1/users").
+//     CmpNextPath("/users")
+//
+// Redirections can be tested using CmpRedirect, and followed using
+// FollowRedirects:
+//
+//   ta.FollowRedirects(5).
+//     PostForm("/login", url.Values{"user": {"bob"}}).
+//     CmpRedirect(http.StatusSeeOther, "/home").
+//     CmpStatus(http.StatusOK)
+//
+// Fanout and FanoutRequests send requests concurrently, to test
+// idempotency or locking logic on the set of responses:
+//
+//   ta.Fanout(3, tdhttp.PostJSON("/orders", order, "Idempotency-Key", "42")).
+//     CmpStatuses(td.Bag(201, 409, 409))
+//
+// A handler panic does not crash the test binary but is reported as
+// a test failure, unless expected by CmpPanic. Context and Timeout
+// control the context received by the handler, CmpCancelHonored
+// checking it stops soon after this context is done:
+//
+//   ta.Timeout(50 * time.Millisecond).
+//     Get("/slow").
+//     CmpCancelHonored(10 * time.Millisecond)
+//
 // Cmp…Response functions
 //
 // Historically, it was the only way to test HTTP APIs using
@@ -60,4 +126,37 @@
 // It now uses TestAPI behind the scene. It is better to directly use
 // TestAPI and its methods instead, as it is more flexible and
 // readable.
+//
+// MockServer
+//
+// When the tested code is a client of another HTTP API, MockServer
+// stands for this upstream API. Expected requests are declared using
+// TestDeep operators, each one bound to a response.
+//
+//   ms := tdhttp.NewMockServer(t)
+//   defer ms.Close()
+//
+//   ms.Expect("GET", "/person/42").
+//     Header(td.ContainsKey("Authorization")).
+//     RespondJSON(http.StatusOK, Person{ID: 42, Name: "Bob", Age: 26})
+//
+//   client := NewPersonClient(ms.URL()) // the tested code
+//   …
+//
+//   ms.Verify() // all expectations satisfied, no unexpected requests
+//
+// RoundTripper
+//
+// HTTP client code can also be tested without any server, using
+// RoundTripper as the transport of its *http.Client. It records all
+// the outgoing requests and replies from a scripted list of
+// responses.
+//
+//   rt := tdhttp.NewRoundTripper(t).
+//     ReplyJSON(http.StatusOK, Person{ID: 42, Name: "Bob", Age: 26})
+//
+//   client := NewPersonClient("http://api.test", rt.Client()) // the tested code
+//   …
+//
+//   rt.CmpRequest(0, "GET", "http://api.test/person/42", nil, nil)
 package tdhttp
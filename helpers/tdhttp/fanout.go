@@ -0,0 +1,311 @@
+// Copyright (c) 2021, Maxime Soulé
+// All rights reserved.
+//
+// This source code is licensed under the BSD-style license found in the
+// LICENSE file in the root directory of this source tree.
+
+package tdhttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	"github.com/maxatome/go-testdeep/internal/color"
+	"github.com/maxatome/go-testdeep/internal/ctxerr"
+	"github.com/maxatome/go-testdeep/internal/types"
+)
+
+// FanoutResult contains all the responses received by a fan-out of
+// requests, see TestAPI.Fanout and TestAPI.FanoutRequests methods.
+type FanoutResult struct {
+	ta        *TestAPI
+	responses []*httptest.ResponseRecorder
+	failed    bool
+}
+
+// Fanout sends "n" copies of "req" concurrently to the tested API,
+// then waits for all the responses. The returned *FanoutResult
+// allows to test all these responses as a whole, typically to check
+// idempotency or locking logic:
+//
+//   ta.Fanout(10, tdhttp.PostJSON("/orders", order, "Idempotency-Key", "42")).
+//     CmpStatuses(td.Bag(201, 409, 409, 409, 409, 409, 409, 409, 409, 409))
+//
+// Using -race flag of go test is then recommended.
+//
+// Variables are substituted once in "req", its body being replayed
+// for each copy. See FanoutRequests for details.
+//
+// It panics if "n" is not positive.
+func (t *TestAPI) Fanout(n int, req *http.Request) *FanoutResult {
+	t.t.Helper()
+
+	if n <= 0 {
+		panic(color.Bad("Fanout(n, req): n must be > 0, not %d", n))
+	}
+
+	if !t.substituteVars(req) {
+		return &FanoutResult{ta: t, failed: true}
+	}
+
+	body := readRequestBody(req)
+	reqs := make([]*http.Request, n)
+	for i := range reqs {
+		reqs[i] = cloneRequest(req, body)
+	}
+	return t.fanout(reqs)
+}
+
+// FanoutRequests sends all "reqs" concurrently to the tested API,
+// then waits for all the responses. The returned *FanoutResult
+// allows to test all these responses as a whole:
+//
+//   ta.FanoutRequests(
+//     tdhttp.PutJSON("/stock/42", map[string]int{"delta": -1}),
+//     tdhttp.PutJSON("/stock/42", map[string]int{"delta": -1}),
+//     tdhttp.Get("/stock/42"),
+//   ).
+//     CmpStatuses([]int{200, 200, 200})
+//
+// All the requests are released at the same time to maximize the
+// concurrency. Variables are substituted in each request before
+// (see SetVar) and the handler receives the context set by Context
+// and Timeout methods, if any. A handler panic is recovered and
+// reported as a test failure. Contrary to requests sent by Request
+// method, these exchanges are neither recorded in HAR (see RecordHAR)
+// nor checked against the OpenAPI spec (see OpenAPI), and
+// redirections are not followed. The last response of t is not
+// changed either.
+//
+// It panics if "reqs" is empty.
+func (t *TestAPI) FanoutRequests(reqs ...*http.Request) *FanoutResult {
+	t.t.Helper()
+
+	if len(reqs) == 0 {
+		panic(color.Bad("FanoutRequests(reqs ...*http.Request): at least one request expected"))
+	}
+
+	for _, req := range reqs {
+		if !t.substituteVars(req) {
+			return &FanoutResult{ta: t, failed: true}
+		}
+	}
+	return t.fanout(reqs)
+}
+
+func (t *TestAPI) fanout(reqs []*http.Request) *FanoutResult {
+	t.t.Helper()
+
+	res := FanoutResult{
+		ta:        t,
+		responses: make([]*httptest.ResponseRecorder, len(reqs)),
+	}
+	panics := make([]*handlerPanic, len(reqs))
+
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i, req := range reqs {
+		res.responses[i] = httptest.NewRecorder()
+		req, cancel := t.requestContext(req)
+
+		wg.Add(1)
+		go func(i int, req *http.Request, cancel func()) {
+			defer wg.Done()
+			defer cancel()
+			<-start
+			panics[i] = recoverPanic(func() {
+				t.handler.ServeHTTP(res.responses[i], req)
+			})
+		}(i, req, cancel)
+	}
+	close(start)
+	wg.Wait()
+
+	for i, p := range panics {
+		if p == nil {
+			continue
+		}
+		res.failed = true
+		t.t.RootName(fmt.Sprintf("Handler[%d]", i)).Code(p,
+			func(p *handlerPanic) error {
+				return &ctxerr.Error{
+					Message:  "%% should NOT have panicked",
+					Got:      types.RawString(p.String()),
+					Expected: types.RawString("not panicking at all"),
+				}
+			},
+			t.name+"handler should not panic")
+	}
+
+	return &res
+}
+
+// cloneRequest returns a deep copy of "req" whose body is "body", so
+// each clone can be modified by the handler independently.
+func cloneRequest(req *http.Request, body []byte) *http.Request {
+	nreq := req.WithContext(req.Context())
+
+	if req.URL != nil {
+		u := *req.URL
+		nreq.URL = &u
+	}
+	nreq.Header = cloneValues(req.Header)
+	nreq.Trailer = cloneValues(req.Trailer)
+	nreq.Form = cloneValues(req.Form)
+	nreq.PostForm = cloneValues(req.PostForm)
+
+	if body != nil {
+		nreq.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	return nreq
+}
+
+// cloneValues returns a deep copy of "m", typically an http.Header
+// or an url.Values, or nil if "m" is nil.
+func cloneValues(m map[string][]string) map[string][]string {
+	if m == nil {
+		return nil
+	}
+	nm := make(map[string][]string, len(m))
+	for key, values := range m {
+		nm[key] = append([]string(nil), values...)
+	}
+	return nm
+}
+
+// Responses returns all the responses received, in the order of the
+// requests. It returns nil if the requests have not been sent, due
+// to a variable substitution failure.
+func (r *FanoutResult) Responses() []*http.Response {
+	if r.responses == nil {
+		return nil
+	}
+	resps := make([]*http.Response, len(r.responses))
+	for i, resp := range r.responses {
+		resps[i] = resp.Result()
+	}
+	return resps
+}
+
+// Failed returns true if a handler panicked, if any Cmp* method
+// failed or if the requests have not been sent.
+func (r *FanoutResult) Failed() bool {
+	return r.failed
+}
+
+func (r *FanoutResult) checkSent() bool {
+	r.ta.t.Helper()
+	return r.ta.t.RootName("Requests").
+		Code(r.responses != nil,
+			func(sent bool) error {
+				if sent {
+					return nil
+				}
+				return &ctxerr.Error{
+					Message: "%% not sent!",
+					Summary: ctxerr.NewSummary("Requests have not been sent due to a variable substitution failure"),
+				}
+			},
+			r.ta.name+"requests are sent")
+}
+
+// CmpStatuses tests the status codes of all the responses, in the
+// order of the requests, against "expectedStatuses". As responses
+// are collected in a []int, "expectedStatuses" is typically a []int
+// or a Bag, SuperBagOf or ArrayEach operator:
+//
+//   ta.Fanout(5, tdhttp.Post("/lock/42", nil)).
+//     CmpStatuses(td.Bag(200, 423, 423, 423, 423))
+func (r *FanoutResult) CmpStatuses(expectedStatuses interface{}) *FanoutResult {
+	defer r.ta.t.AnchorsPersistTemporarily()()
+
+	r.ta.t.Helper()
+
+	if !r.checkSent() {
+		r.failed = true
+		return r
+	}
+
+	statuses := make([]int, len(r.responses))
+	for i, resp := range r.responses {
+		statuses[i] = resp.Code
+	}
+	if !r.ta.t.RootName("Responses.Status").
+		Cmp(statuses, expectedStatuses, r.ta.name+"status codes should match") {
+		r.failed = true
+	}
+	return r
+}
+
+// CmpBodies tests the bodies of all the responses, in the order of
+// the requests, against "expectedBodies". As bodies are collected in
+// a []string, "expectedBodies" is typically a []string or a Bag,
+// SuperBagOf or ArrayEach operator:
+//
+//   ta.Fanout(3, tdhttp.Get("/counter/incr")).
+//     CmpBodies(td.Bag("1", "2", "3"))
+func (r *FanoutResult) CmpBodies(expectedBodies interface{}) *FanoutResult {
+	defer r.ta.t.AnchorsPersistTemporarily()()
+
+	r.ta.t.Helper()
+
+	if !r.checkSent() {
+		r.failed = true
+		return r
+	}
+
+	bodies := make([]string, len(r.responses))
+	for i, resp := range r.responses {
+		bodies[i] = resp.Body.String()
+	}
+	if !r.ta.t.RootName("Responses.Body").
+		Cmp(bodies, expectedBodies, r.ta.name+"bodies should match") {
+		r.failed = true
+	}
+	return r
+}
+
+// CmpJSONBodies unmarshals the JSON bodies of all the responses into
+// a []interface{}, in the order of the requests, and tests it
+// against "expectedBodies". Numbers are unmarshaled into float64, but
+// comparisons are done in lax mode, so ints can be used in
+// "expectedBodies":
+//
+//   ta.Fanout(3, tdhttp.PostJSON("/tickets", nil)).
+//     CmpJSONBodies(td.ArrayEach(td.JSON(`{"id": $1}`, td.NotZero())))
+func (r *FanoutResult) CmpJSONBodies(expectedBodies interface{}) *FanoutResult {
+	defer r.ta.t.AnchorsPersistTemporarily()()
+
+	r.ta.t.Helper()
+
+	if !r.checkSent() {
+		r.failed = true
+		return r
+	}
+
+	bodies := make([]interface{}, len(r.responses))
+	for i, resp := range r.responses {
+		if err := json.Unmarshal(resp.Body.Bytes(), &bodies[i]); err != nil {
+			r.failed = true
+			r.ta.t.RootName(fmt.Sprintf("Responses.Body[%d]", i)).Code(err,
+				func(err error) error {
+					return &ctxerr.Error{
+						Message: "%% unmarshaling error",
+						Summary: ctxerr.NewSummary(err.Error()),
+					}
+				},
+				r.ta.name+"bodies should be JSON")
+			return r
+		}
+	}
+	if !r.ta.t.RootName("Responses.Body").
+		CmpLax(bodies, expectedBodies, r.ta.name+"bodies should match") {
+		r.failed = true
+	}
+	return r
+}
@@ -0,0 +1,153 @@
+// Copyright (c) 2021, Maxime Soulé
+// All rights reserved.
+//
+// This source code is licensed under the BSD-style license found in the
+// LICENSE file in the root directory of this source tree.
+
+package tdhttp_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/maxatome/go-testdeep/helpers/tdhttp"
+	"github.com/maxatome/go-testdeep/internal/test"
+	"github.com/maxatome/go-testdeep/td"
+)
+
+func fanoutServer() http.Handler {
+	var (
+		mu      sync.Mutex
+		orders  = map[string]string{}
+		counter int
+	)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/orders", func(w http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		key := req.Header.Get("Idempotency-Key")
+
+		mu.Lock()
+		defer mu.Unlock()
+		if _, ok := orders[key]; ok {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		orders[key] = string(body)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"key":%q,"order":%s}`, key, body)
+	})
+	mux.HandleFunc("/counter", func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		counter++
+		n := counter
+		mu.Unlock()
+		fmt.Fprintf(w, "%d%v", n, req.Context().Value(ctxKey{}))
+	})
+	mux.HandleFunc("/mutate", func(w http.ResponseWriter, req *http.Request) {
+		req.URL.Path += "/x"
+		req.Header.Add("X-Seen", "1")
+		req.ParseForm() //nolint: errcheck
+		req.Form.Add("seen", "1")
+		fmt.Fprintf(w, "%s %d %d", req.URL.Path, len(req.Header["X-Seen"]), len(req.Form["seen"]))
+	})
+	mux.HandleFunc("/boom", func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Query().Get("boom") != "" {
+			panic("boom!")
+		}
+	})
+	return mux
+}
+
+func TestFanout(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		tb, ta := newTestAPI(t, fanoutServer())
+
+		res := ta.SetVar("key", "k1").
+			Fanout(5, tdhttp.PostJSON("/orders", map[string]int{"qty": 2},
+				"Idempotency-Key", "{key}")).
+			CmpStatuses(td.Bag(201, 409, 409, 409, 409)).
+			CmpBodies(td.Bag(`{"key":"k1","order":{"qty":2}}`, "", "", "", ""))
+		td.CmpFalse(t, res.Failed())
+		td.Cmp(t, res.Responses(), td.All(
+			td.Len(5),
+			td.SuperBagOf(td.Struct(&http.Response{StatusCode: 201}, nil)),
+		))
+
+		ta.FanoutRequests(
+			tdhttp.PostJSON("/orders", 1, "Idempotency-Key", "k2"),
+			tdhttp.PostJSON("/orders", 2, "Idempotency-Key", "k3"),
+		).
+			CmpStatuses([]int{201, 201}).
+			CmpJSONBodies([]interface{}{
+				map[string]interface{}{"key": "k2", "order": 1},
+				map[string]interface{}{"key": "k3", "order": 2},
+			})
+
+		ta.Context(context.WithValue(context.Background(), ctxKey{}, "!")).
+			Fanout(3, tdhttp.Get("/counter")).
+			CmpBodies(td.Bag("1!", "2!", "3!"))
+
+		// Each handler receives its own copy of the request
+		req := tdhttp.Get("/mutate?seen=0")
+		req.ParseForm() //nolint: errcheck
+		ta.Fanout(3, req).
+			CmpBodies(td.ArrayEach("/mutate/x 1 2"))
+
+		td.CmpEmpty(t, tb.Messages)
+	})
+
+	t.Run("Failures", func(t *testing.T) {
+		tb, ta := newTestAPI(t, fanoutServer())
+
+		res := ta.Fanout(3, tdhttp.PostJSON("/orders", 1, "Idempotency-Key", "k")).
+			CmpStatuses(td.Bag(201, 201, 409))
+		td.CmpTrue(t, res.Failed())
+		td.Cmp(t, tb.LastMessage(), td.All(
+			td.Contains("Failed test 'status codes should match'"),
+			td.Contains("comparing Responses.Status as a Bag"),
+		))
+
+		tb.ResetMessages()
+		ta.Fanout(2, tdhttp.Get("/counter")).CmpJSONBodies(td.Ignore())
+		td.Cmp(t, tb.LastMessage(), td.All(
+			td.Contains("Failed test 'bodies should be JSON'"),
+			td.Re(`Responses\.Body\[\d\] unmarshaling error`),
+		))
+
+		tb.ResetMessages()
+		res = ta.FanoutRequests(tdhttp.Get("/boom"), tdhttp.Get("/boom?boom=1")).
+			CmpStatuses([]int{200, 200})
+		td.CmpTrue(t, res.Failed())
+		td.Cmp(t, tb.Messages, td.Len(1))
+		td.Cmp(t, tb.LastMessage(), td.All(
+			td.Contains("Failed test 'handler should not panic'"),
+			td.Contains("Handler[1] should NOT have panicked"),
+			td.Contains(`panic: "boom!"`),
+		))
+
+		ta.Get("/counter").CaptureHeader("failed", "X-Nope")
+		tb.ResetMessages()
+		res = ta.SetVar("known", 1).
+			Fanout(2, tdhttp.Get("/counter/{failed}")).
+			CmpStatuses([]int{200, 200}).
+			CmpBodies(td.Ignore()).
+			CmpJSONBodies(td.Ignore())
+		td.CmpTrue(t, res.Failed())
+		td.CmpNil(t, res.Responses())
+		if td.CmpLen(t, tb.Messages, 4) {
+			td.Cmp(t, tb.Messages[0], td.Contains("Request uses variable {failed} whose capture failed"))
+			td.Cmp(t, tb.Messages[1:], td.ArrayEach(td.Contains("Requests not sent!")))
+		}
+
+		test.CheckPanic(t, func() { ta.Fanout(0, tdhttp.Get("/")) },
+			"Fanout(n, req): n must be > 0, not 0")
+		test.CheckPanic(t, func() { ta.FanoutRequests() },
+			"FanoutRequests(reqs ...*http.Request): at least one request expected")
+	})
+}
@@ -0,0 +1,243 @@
+// Copyright (c) 2021, Maxime Soulé
+// All rights reserved.
+//
+// This source code is licensed under the BSD-style license found in the
+// LICENSE file in the root directory of this source tree.
+
+package tdhttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/maxatome/go-testdeep/internal/ctxerr"
+)
+
+// GraphQLQuery is a GraphQL query or mutation sent by
+// NewGraphQLRequest function or TestAPI.PostGraphQL method.
+type GraphQLQuery struct {
+	// Query is the GraphQL document, a query or a mutation.
+	Query string
+	// OperationName is the name of the operation to execute when
+	// Query contains several ones. Optional.
+	OperationName string
+	// Variables are the values of the GraphQL variables used by
+	// Query, typically a map[string]interface{} or a struct. Optional.
+	Variables interface{}
+}
+
+// graphQLString is a GraphQL document, marshaled to JSON with its
+// braces escaped, so they cannot be taken for TestAPI variables.
+type graphQLString string
+
+func (s graphQLString) MarshalJSON() ([]byte, error) {
+	b, err := json.Marshal(string(s))
+	if err != nil {
+		return nil, err
+	}
+	b = bytes.Replace(b, []byte("{"), []byte(`\u007b`), -1)
+	return bytes.Replace(b, []byte("}"), []byte(`\u007d`), -1), nil
+}
+
+// NewGraphQLRequest creates a new HTTP POST request sending the
+// GraphQL "query" to "target", as recommended by the GraphQL over
+// HTTP spec: the body is the JSON object
+// {"query": …, "operationName": …, "variables": …}, empty
+// operationName and nil variables being omitted. "Content-Type"
+// header is automatically set to "application/json". Other headers
+// can be added via headers, as in:
+//
+//   req := NewGraphQLRequest("/graphql",
+//     tdhttp.GraphQLQuery{
+//       Query:     `query($id: ID!) { user(id: $id) { name } }`,
+//       Variables: map[string]interface{}{"id": 42},
+//     },
+//     "Authorization", "Bearer token",
+//   )
+//
+// The braces of Query are escaped in the JSON body, so when the
+// request is sent using TestAPI, variables (see TestAPI.SetVar) are
+// only substituted in Variables, never in Query.
+//
+// See NewRequest for all possible formats accepted in headers.
+func NewGraphQLRequest(target string, query GraphQLQuery, headers ...interface{}) *http.Request {
+	return NewJSONRequest(http.MethodPost, target,
+		struct {
+			Query         graphQLString `json:"query"`
+			OperationName string        `json:"operationName,omitempty"`
+			Variables     interface{}   `json:"variables,omitempty"`
+		}{
+			Query:         graphQLString(query.Query),
+			OperationName: query.OperationName,
+			Variables:     query.Variables,
+		},
+		headers...)
+}
+
+// PostGraphQL sends the GraphQL "query" to "target" of the tested
+// API. See NewGraphQLRequest for details. CmpGraphQLData and
+// CmpGraphQLErrors can then be used to test the response, as well as
+// any other Cmp* or NoBody methods.
+//
+//   ta.PostGraphQL("/graphql", tdhttp.GraphQLQuery{
+//     Query:     `query($id: ID!) { user(id: $id) { id name } }`,
+//     Variables: map[string]interface{}{"id": "{id}"},
+//   }).
+//     CmpStatus(http.StatusOK).
+//     CmpGraphQLData(td.JSON(`{"user": {"id": $1, "name": "Bob"}}`, ta.Var("id")))
+//
+// Note that Failed() status is reset just after this call.
+//
+// See NewRequest for all possible formats accepted in headers.
+func (t *TestAPI) PostGraphQL(target string, query GraphQLQuery, headers ...interface{}) *TestAPI {
+	return t.Request(NewGraphQLRequest(target, query, headers...))
+}
+
+// graphQLResponse is a GraphQL response. Fields are kept raw to be
+// unmarshaled according to the expected value type.
+type graphQLResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors json.RawMessage `json:"errors"`
+}
+
+func isJSONNull(b json.RawMessage) bool {
+	return len(b) == 0 || string(b) == "null"
+}
+
+// graphQLResponse unmarshals the last response as a GraphQL one. It
+// returns nil and reports a failure if it is not possible.
+func (t *TestAPI) graphQLResponse() *graphQLResponse {
+	t.t.Helper()
+
+	if !t.checkRequestSent() {
+		return nil
+	}
+
+	var resp graphQLResponse
+	if !t.t.RootName("unmarshal(Response.Body)").
+		CmpNoError(json.Unmarshal(t.response.Body.Bytes(), &resp),
+			t.name+"body should be a GraphQL response") {
+		t.dumpResponse()
+		return nil
+	}
+	return &resp
+}
+
+// CmpGraphQLData tests the "data" member of the last GraphQL response
+// against "expectedData". It is first unmarshaled into the type of
+// "expectedData" or into the type behind it if it is a TestDeep
+// operator, or into an interface{} if this type cannot be
+// determined. So JSON, SubJSONOf or SuperJSONOf operators fit well:
+//
+//   ta.PostGraphQL("/graphql", tdhttp.GraphQLQuery{
+//     Query: `{ users { name } }`,
+//   }).
+//     CmpGraphQLData(td.JSON(`{"users": [{"name": "Bob"}, {"name": "Alice"}]}`))
+//
+// If the response contains a non-empty "errors" member, the test
+// fails without comparing data, and errors are displayed. Use
+// CmpGraphQLErrors to test expected errors.
+//
+// It fails if no request has been sent yet.
+func (t *TestAPI) CmpGraphQLData(expectedData interface{}) *TestAPI {
+	defer t.t.AnchorsPersistTemporarily()()
+
+	t.t.Helper()
+
+	resp := t.graphQLResponse()
+	if resp == nil {
+		t.bodyFailed = true
+		return t
+	}
+
+	if !isJSONNull(resp.Errors) && string(resp.Errors) != "[]" {
+		t.bodyFailed = true
+		var errors bytes.Buffer
+		json.Indent(&errors, resp.Errors, "", "  ") //nolint: errcheck
+		t.t.RootName("Response.Body.errors").Code(errors.String(),
+			func(errors string) error {
+				return &ctxerr.Error{
+					Message: "%% is present but not expected",
+					Summary: ctxerr.NewSummary(strings.TrimSpace(errors)),
+				}
+			},
+			t.name+"GraphQL response should not contain errors")
+		if t.autoDumpResponse {
+			t.dumpResponse()
+		}
+		return t
+	}
+
+	t.cmpGraphQLSection("data", resp.Data, expectedData)
+	return t
+}
+
+// CmpGraphQLErrors tests the "errors" member of the last GraphQL
+// response against "expectedErrors". It is first unmarshaled into
+// the type of "expectedErrors" or into the type behind it if it is a
+// TestDeep operator, or into an interface{} if this type cannot be
+// determined:
+//
+//   ta.PostGraphQL("/graphql", tdhttp.GraphQLQuery{
+//     Query: `{ user(id: 0) { name } }`,
+//   }).
+//     CmpGraphQLErrors(td.JSON(`[{"message": "user not found", "path": ["user"]}]`))
+//
+//   ta.CmpGraphQLErrors(td.Len(1))
+//
+// It fails if the response does not contain an "errors" member.
+//
+// It fails if no request has been sent yet.
+func (t *TestAPI) CmpGraphQLErrors(expectedErrors interface{}) *TestAPI {
+	defer t.t.AnchorsPersistTemporarily()()
+
+	t.t.Helper()
+
+	resp := t.graphQLResponse()
+	if resp == nil {
+		t.bodyFailed = true
+		return t
+	}
+
+	if isJSONNull(resp.Errors) {
+		t.bodyFailed = true
+		t.t.RootName("Response.Body.errors").Code(false,
+			func(bool) error {
+				return &ctxerr.Error{
+					Message: "%% is absent",
+					Summary: ctxerr.NewSummary("GraphQL response does not contain errors"),
+				}
+			},
+			t.name+"GraphQL response should contain errors")
+		if t.autoDumpResponse {
+			t.dumpResponse()
+		}
+		return t
+	}
+
+	t.cmpGraphQLSection("errors", resp.Errors, expectedErrors)
+	return t
+}
+
+// cmpGraphQLSection compares the raw "name" member of a GraphQL
+// response to "expected".
+func (t *TestAPI) cmpGraphQLSection(name string, raw json.RawMessage, expected interface{}) {
+	t.t.Helper()
+
+	if len(raw) == 0 {
+		raw = json.RawMessage("null")
+	}
+
+	tt := t.t.RootName("Response.Body." + name)
+	got, err := unmarshalBody(raw, json.Unmarshal, expected)
+	if !tt.RootName("unmarshal(Response.Body."+name+")").
+		CmpNoError(err, t.name+"GraphQL "+name+" unmarshaling") ||
+		!tt.Cmp(got, expected, t.name+"GraphQL "+name+" should match") {
+		t.bodyFailed = true
+		if t.autoDumpResponse {
+			t.dumpResponse()
+		}
+	}
+}
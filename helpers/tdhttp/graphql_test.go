@@ -0,0 +1,166 @@
+// Copyright (c) 2021, Maxime Soulé
+// All rights reserved.
+//
+// This source code is licensed under the BSD-style license found in the
+// LICENSE file in the root directory of this source tree.
+
+package tdhttp_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/maxatome/go-testdeep/helpers/tdhttp"
+	"github.com/maxatome/go-testdeep/td"
+)
+
+func graphQLServer() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var q struct {
+			Query         string                 `json:"query"`
+			OperationName string                 `json:"operationName"`
+			Variables     map[string]interface{} `json:"variables"`
+		}
+		if err := json.NewDecoder(req.Body).Decode(&q); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		switch q.OperationName {
+		case "error":
+			w.Write([]byte(`{"data":{"user":null},"errors":[{"message":"user not found","path":["user"]}]}`)) //nolint: errcheck
+		case "not-json":
+			w.Write([]byte(`oops`)) //nolint: errcheck
+		default:
+			json.NewEncoder(w).Encode(map[string]interface{}{ //nolint: errcheck
+				"data": map[string]interface{}{
+					"query":     q.Query,
+					"variables": q.Variables,
+				},
+			})
+		}
+	})
+}
+
+func TestNewGraphQLRequest(t *testing.T) {
+	req := tdhttp.NewGraphQLRequest("/graphql",
+		tdhttp.GraphQLQuery{
+			Query:         `query Q($id: ID!) { user(id: $id) { name } }`,
+			OperationName: "Q",
+			Variables:     map[string]int{"id": 42},
+		},
+		"X-Test", "1")
+	td.Cmp(t, req.Method, http.MethodPost)
+	td.Cmp(t, req.Header, td.SuperMapOf(http.Header{
+		"Content-Type": {"application/json"},
+		"X-Test":       {"1"},
+	}, nil))
+
+	body, err := ioutil.ReadAll(req.Body)
+	if td.CmpNoError(t, err) {
+		// Braces of the query are escaped
+		td.Cmp(t, string(body), td.Not(td.Re(`"query":"[^"]*[{}]`)))
+		td.Cmp(t, json.RawMessage(body), td.JSON(`{
+  "query":         "query Q($id: ID!) { user(id: $id) { name } }",
+  "operationName": "Q",
+  "variables":     {"id": 42}
+}`))
+	}
+
+	req = tdhttp.NewGraphQLRequest("/graphql", tdhttp.GraphQLQuery{Query: "{ me }"})
+	body, err = ioutil.ReadAll(req.Body)
+	if td.CmpNoError(t, err) {
+		td.Cmp(t, json.RawMessage(body), td.JSON(`{"query": "{ me }"}`))
+	}
+}
+
+func TestGraphQL(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		tb, ta := newTestAPI(t, graphQLServer())
+
+		ta.SetVar("id", 42).
+			PostGraphQL("/graphql", tdhttp.GraphQLQuery{
+				Query:     `query($id: ID!) {user(id: $id) {name}}`,
+				Variables: map[string]interface{}{"id": "{id}"},
+			}).
+			CmpStatus(http.StatusOK).
+			CmpGraphQLData(td.JSON(`{
+  "query":     "query($id: ID!) {user(id: $id) {name}}",
+  "variables": {"id": 42}
+}`)).
+			CmpGraphQLData(td.SuperMapOf(map[string]interface{}{
+				"variables": map[string]interface{}{"id": 42.0},
+			}, nil))
+
+		ta.PostGraphQL("/graphql", tdhttp.GraphQLQuery{
+			Query:         `{ user(id: 0) { name } }`,
+			OperationName: "error",
+		}).
+			CmpGraphQLErrors(td.JSON(`[{"message": "user not found", "path": ["user"]}]`)).
+			CmpGraphQLErrors(td.Len(1)).
+			CmpGraphQLErrors([]struct {
+				Message string `json:"message"`
+			}{{Message: "user not found"}})
+
+		td.CmpFalse(t, ta.Failed())
+		td.CmpEmpty(t, tb.Messages)
+	})
+
+	t.Run("Failures", func(t *testing.T) {
+		tb, ta := newTestAPI(t, graphQLServer())
+
+		td.CmpTrue(t, ta.CmpGraphQLData(td.Ignore()).Failed())
+		td.Cmp(t, tb.LastMessage(), td.Contains("Request not sent!"))
+
+		tb.ResetMessages()
+		td.CmpTrue(t,
+			ta.PostGraphQL("/graphql", tdhttp.GraphQLQuery{
+				Query:         `{ user(id: 0) { name } }`,
+				OperationName: "error",
+			}).
+				CmpGraphQLData(td.Ignore()).
+				Failed())
+		td.Cmp(t, tb.LastMessage(), td.All(
+			td.Contains("Failed test 'GraphQL response should not contain errors'"),
+			td.Contains("Response.Body.errors is present but not expected"),
+			td.Contains(`"message": "user not found"`),
+		))
+
+		tb.ResetMessages()
+		td.CmpTrue(t,
+			ta.PostGraphQL("/graphql", tdhttp.GraphQLQuery{Query: `{ me }`}).
+				CmpGraphQLErrors(td.Ignore()).
+				Failed())
+		td.Cmp(t, tb.LastMessage(), td.All(
+			td.Contains("Failed test 'GraphQL response should contain errors'"),
+			td.Contains("Response.Body.errors is absent"),
+		))
+
+		tb.ResetMessages()
+		td.CmpTrue(t, ta.CmpGraphQLData(td.JSON(`{"query": "{ you }"}`)).Failed())
+		td.Cmp(t, tb.LastMessage(), td.All(
+			td.Contains("Failed test 'GraphQL data should match'"),
+			td.Contains(`Response.Body.data["query"]: values differ`),
+		))
+
+		tb.ResetMessages()
+		td.CmpTrue(t, ta.CmpGraphQLData([]int{}).Failed())
+		td.Cmp(t, tb.LastMessage(), td.All(
+			td.Contains("Failed test 'GraphQL data unmarshaling'"),
+			td.Contains("unmarshal(Response.Body.data): should NOT be an error"),
+		))
+
+		tb.ResetMessages()
+		td.CmpTrue(t,
+			ta.PostGraphQL("/graphql", tdhttp.GraphQLQuery{OperationName: "not-json"}).
+				CmpGraphQLData(td.Ignore()).
+				Failed())
+		td.Cmp(t, tb.Messages, td.SuperBagOf(td.All(
+			td.Contains("Failed test 'body should be a GraphQL response'"),
+			td.Contains("unmarshal(Response.Body): should NOT be an error"),
+		)))
+	})
+}
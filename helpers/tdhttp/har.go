@@ -0,0 +1,316 @@
+// Copyright (c) 2021, Maxime Soulé
+// All rights reserved.
+//
+// This source code is licensed under the BSD-style license found in the
+// LICENSE file in the root directory of this source tree.
+
+package tdhttp
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/maxatome/go-testdeep/helpers/tdutil"
+)
+
+// HAR 1.2 format, see http://www.softwareishard.com/blog/har-12-spec/
+type (
+	harArchive struct {
+		Log harLog `json:"log"`
+	}
+
+	harLog struct {
+		Version string     `json:"version"`
+		Creator harCreator `json:"creator"`
+		Entries []harEntry `json:"entries"`
+	}
+
+	harCreator struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
+
+	harEntry struct {
+		StartedDateTime string      `json:"startedDateTime"`
+		Time            float64     `json:"time"`
+		Request         harRequest  `json:"request"`
+		Response        harResponse `json:"response"`
+		Cache           struct{}    `json:"cache"`
+		Timings         harTimings  `json:"timings"`
+	}
+
+	harRequest struct {
+		Method      string         `json:"method"`
+		URL         string         `json:"url"`
+		HTTPVersion string         `json:"httpVersion"`
+		Cookies     []harNameValue `json:"cookies"`
+		Headers     []harNameValue `json:"headers"`
+		QueryString []harNameValue `json:"queryString"`
+		PostData    *harPostData   `json:"postData,omitempty"`
+		HeadersSize int            `json:"headersSize"`
+		BodySize    int            `json:"bodySize"`
+	}
+
+	harPostData struct {
+		MimeType string `json:"mimeType"`
+		Text     string `json:"text"`
+	}
+
+	harResponse struct {
+		Status      int            `json:"status"`
+		StatusText  string         `json:"statusText"`
+		HTTPVersion string         `json:"httpVersion"`
+		Cookies     []harNameValue `json:"cookies"`
+		Headers     []harNameValue `json:"headers"`
+		Content     harContent     `json:"content"`
+		RedirectURL string         `json:"redirectURL"`
+		HeadersSize int            `json:"headersSize"`
+		BodySize    int            `json:"bodySize"`
+	}
+
+	harContent struct {
+		Size     int    `json:"size"`
+		MimeType string `json:"mimeType"`
+		Text     string `json:"text,omitempty"`
+		Encoding string `json:"encoding,omitempty"`
+	}
+
+	harNameValue struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	}
+
+	harTimings struct {
+		Send    float64 `json:"send"`
+		Wait    float64 `json:"wait"`
+		Receive float64 `json:"receive"`
+	}
+)
+
+// harRecorder records all the exchanges of a TestAPI instance.
+type harRecorder struct {
+	dir       string
+	onFailure bool
+
+	mu      sync.Mutex
+	entries []harEntry
+}
+
+// RecordHAR enables the recording of all the following
+// request/response exchanges into a HTTP Archive (HAR 1.2) file,
+// that can then be loaded in browser devtools. The archive is
+// written in directory "dir" (created if needed) when the test ends,
+// its file name being derived from the test name, as in
+// "dir/TestMyAPI_create_user.har".
+//
+//   ta := tdhttp.NewTestAPI(t, mux).RecordHAR("testdata/har")
+//
+// Before go 1.14, as testing.TB has no Cleanup method, the archive
+// is rewritten after each exchange instead.
+//
+// See RecordHAROnFailure to only write the archive when the test
+// fails. Instances derived using With or Run methods record their
+// own archive in the same directory.
+func (t *TestAPI) RecordHAR(dir string) *TestAPI {
+	return t.recordHAR(dir, false)
+}
+
+// RecordHAROnFailure is the same as RecordHAR, except that the
+// archive is only written if the test failed. In this case, the path
+// of the archive is logged.
+func (t *TestAPI) RecordHAROnFailure(dir string) *TestAPI {
+	return t.recordHAR(dir, true)
+}
+
+func (t *TestAPI) recordHAR(dir string, onFailure bool) *TestAPI {
+	if t.har == nil {
+		t.har = &harRecorder{dir: dir, onFailure: onFailure}
+		t.harRegister()
+	} else {
+		t.har.mu.Lock()
+		t.har.dir, t.har.onFailure = dir, onFailure
+		t.har.mu.Unlock()
+	}
+	return t
+}
+
+// harCopy enables the HAR recording of "nt", derived from "t", if
+// "t" records its exchanges.
+func (t *TestAPI) harCopy(nt *TestAPI) {
+	if t.har != nil {
+		t.har.mu.Lock()
+		dir, onFailure := t.har.dir, t.har.onFailure
+		t.har.mu.Unlock()
+		nt.recordHAR(dir, onFailure)
+	}
+}
+
+// harAdd records the exchange composed by "req", whose body is
+// "reqBody", and the last response received.
+func (t *TestAPI) harAdd(req *http.Request, reqBody []byte, duration time.Duration) {
+	ms := float64(duration) / float64(time.Millisecond)
+
+	url := req.URL.String()
+	if !req.URL.IsAbs() {
+		url = "http://" + req.Host + req.URL.RequestURI()
+	}
+
+	entry := harEntry{
+		StartedDateTime: t.sentAt.Format(time.RFC3339Nano),
+		Time:            ms,
+		Request: harRequest{
+			Method:      req.Method,
+			URL:         url,
+			HTTPVersion: req.Proto,
+			Cookies:     harCookies(req.Cookies()),
+			Headers:     harHeaders(req.Header),
+			QueryString: harQueryString(req),
+			HeadersSize: -1,
+			BodySize:    len(reqBody),
+		},
+		Response: harResponse{
+			Status:      t.response.Code,
+			StatusText:  http.StatusText(t.response.Code),
+			HTTPVersion: "HTTP/1.1",
+			Cookies:     harCookies(t.response.Result().Cookies()),
+			Headers:     harHeaders(t.response.Header()),
+			Content:     harResponseContent(t.response.Header(), t.response.Body.Bytes()),
+			RedirectURL: t.response.Header().Get("Location"),
+			HeadersSize: -1,
+			BodySize:    t.response.Body.Len(),
+		},
+		Timings: harTimings{Wait: ms},
+	}
+	if reqBody != nil {
+		entry.Request.PostData = &harPostData{
+			MimeType: req.Header.Get("Content-Type"),
+			Text:     string(reqBody),
+		}
+	}
+
+	t.har.mu.Lock()
+	t.har.entries = append(t.har.entries, entry)
+	t.har.mu.Unlock()
+
+	t.harRecorded()
+}
+
+// harFinalize writes the HAR archive, if needed.
+func (t *TestAPI) harFinalize() {
+	t.har.mu.Lock()
+	defer t.har.mu.Unlock()
+
+	if t.har.onFailure && !t.t.Failed() {
+		return
+	}
+
+	archive := harArchive{
+		Log: harLog{
+			Version: "1.2",
+			Creator: harCreator{Name: "go-testdeep/tdhttp", Version: "1"},
+			Entries: t.har.entries,
+		},
+	}
+	if archive.Log.Entries == nil {
+		archive.Log.Entries = []harEntry{}
+	}
+
+	b, err := json.MarshalIndent(archive, "", "  ")
+	if err != nil {
+		t.t.Errorf("cannot marshal HAR archive: %s", err)
+		return
+	}
+
+	path := filepath.Join(t.har.dir, harFileName(t.t.Name()))
+	err = os.MkdirAll(t.har.dir, 0755)
+	if err == nil {
+		err = ioutil.WriteFile(path, b, 0644)
+	}
+	if err != nil {
+		t.t.Errorf("cannot write HAR archive: %s", err)
+		return
+	}
+
+	if t.har.onFailure {
+		t.t.Logf("HAR archive of all HTTP exchanges written to %s", path)
+	}
+}
+
+// harFileName returns the file name of the HAR archive of test
+// "testName".
+func harFileName(testName string) string {
+	name := []byte(tdutil.BuildTestName(testName))
+	for i, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' ||
+			c >= '0' && c <= '9' || c == '-' || c == '.') {
+			name[i] = '_'
+		}
+	}
+	if len(name) == 0 {
+		return "tdhttp.har"
+	}
+	return string(name) + ".har"
+}
+
+func harHeaders(header http.Header) []harNameValue {
+	keys := make([]string, 0, len(header))
+	for key := range header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	nvs := []harNameValue{}
+	for _, key := range keys {
+		for _, value := range header[key] {
+			nvs = append(nvs, harNameValue{Name: key, Value: value})
+		}
+	}
+	return nvs
+}
+
+func harQueryString(req *http.Request) []harNameValue {
+	query := req.URL.Query()
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	nvs := []harNameValue{}
+	for _, key := range keys {
+		for _, value := range query[key] {
+			nvs = append(nvs, harNameValue{Name: key, Value: value})
+		}
+	}
+	return nvs
+}
+
+func harCookies(cookies []*http.Cookie) []harNameValue {
+	nvs := make([]harNameValue, len(cookies))
+	for i, cookie := range cookies {
+		nvs[i] = harNameValue{Name: cookie.Name, Value: cookie.Value}
+	}
+	return nvs
+}
+
+func harResponseContent(header http.Header, body []byte) harContent {
+	content := harContent{
+		Size:     len(body),
+		MimeType: header.Get("Content-Type"),
+	}
+	if utf8.Valid(body) {
+		content.Text = string(body)
+	} else {
+		content.Text = base64.StdEncoding.EncodeToString(body)
+		content.Encoding = "base64"
+	}
+	return content
+}
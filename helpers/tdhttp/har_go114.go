@@ -0,0 +1,18 @@
+// Copyright (c) 2021, Maxime Soulé
+// All rights reserved.
+//
+// This source code is licensed under the BSD-style license found in the
+// LICENSE file in the root directory of this source tree.
+
+// +build go1.14
+
+package tdhttp
+
+// harRegister arranges the HAR archive to be written at the end of
+// the test.
+func (t *TestAPI) harRegister() {
+	t.t.Cleanup(t.harFinalize)
+}
+
+// harRecorded is called each time an exchange is recorded.
+func (t *TestAPI) harRecorded() {}
@@ -0,0 +1,109 @@
+// Copyright (c) 2021, Maxime Soulé
+// All rights reserved.
+//
+// This source code is licensed under the BSD-style license found in the
+// LICENSE file in the root directory of this source tree.
+
+// +build go1.14
+
+package tdhttp_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/maxatome/go-testdeep/helpers/tdhttp"
+	"github.com/maxatome/go-testdeep/internal/test"
+	"github.com/maxatome/go-testdeep/td"
+)
+
+func TestRecordHAR(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tdhttp-har")
+	td.Require(t).CmpNoError(err)
+	defer os.RemoveAll(dir)
+
+	mux := server()
+
+	readHAR := func(t *testing.T, name string) (har map[string]interface{}) {
+		t.Helper()
+		b, err := ioutil.ReadFile(filepath.Join(dir, name))
+		td.Require(t).CmpNoError(err)
+		td.Require(t).CmpNoError(json.Unmarshal(b, &har))
+		return
+	}
+
+	t.Run("Always", func(t *testing.T) {
+		tb := test.NewTestingTB("TestAPI/Always #1")
+		ta := tdhttp.NewTestAPI(tb, mux).RecordHAR(dir)
+
+		ta.Get("/any?a=1&a=2", "X-Test", "foo").CmpStatus(http.StatusOK)
+		ta.Post("/any", strings.NewReader("body!"), "Content-Type", "text/plain").
+			CmpStatus(http.StatusOK)
+
+		tb.RunCleanup()
+		td.CmpFalse(t, tb.HasFailed)
+		td.CmpEmpty(t, tb.Messages)
+
+		td.Cmp(t, readHAR(t, "TestAPI_Always__1.har"), td.JSON(`
+{
+  "log": {
+    "version": "1.2",
+    "creator": {"name": "go-testdeep/tdhttp", "version": "1"},
+    "entries": [
+      SuperMapOf({
+        "startedDateTime": Re("^\\d{4}-\\d\\d-\\d\\dT"),
+        "time":            Gte(0),
+        "request": SuperMapOf({
+          "method":      "GET",
+          "url":         "http://example.com/any?a=1&a=2",
+          "httpVersion": "HTTP/1.1",
+          "headers":     [{"name": "X-Test", "value": "foo"}],
+          "queryString": [{"name": "a", "value": "1"}, {"name": "a", "value": "2"}],
+          "bodySize":    0
+        }),
+        "response": SuperMapOf({
+          "status":     200,
+          "statusText": "OK",
+          "content":    {"size": 4, "mimeType": "text/plain", "text": "GET!"}
+        })
+      }),
+      SuperMapOf({
+        "request": SuperMapOf({
+          "method":   "POST",
+          "postData": {"mimeType": "text/plain", "text": "body!"},
+          "bodySize": 5
+        }),
+        "response": SuperMapOf({
+          "content": SuperMapOf({"text": "POST!\n---\nbody!"})
+        })
+      })
+    ]
+  }
+}`))
+	})
+
+	t.Run("On failure", func(t *testing.T) {
+		tb := test.NewTestingTB("TestAPI/OK")
+		tdhttp.NewTestAPI(tb, mux).RecordHAROnFailure(dir).
+			Get("/any").CmpStatus(http.StatusOK)
+		tb.RunCleanup()
+		td.CmpFalse(t, tb.HasFailed)
+		_, err := os.Stat(filepath.Join(dir, "TestAPI_OK.har"))
+		td.CmpTrue(t, os.IsNotExist(err))
+
+		tb = test.NewTestingTB("TestAPI/KO")
+		tdhttp.NewTestAPI(tb, mux).RecordHAROnFailure(dir).
+			Get("/any").CmpStatus(http.StatusNotFound)
+		tb.RunCleanup()
+		td.CmpTrue(t, tb.HasFailed)
+		td.Cmp(t, tb.LastMessage(),
+			"HAR archive of all HTTP exchanges written to "+filepath.Join(dir, "TestAPI_KO.har"))
+		td.Cmp(t, readHAR(t, "TestAPI_KO.har"),
+			td.JSONPointer("/log/entries", td.Len(1)))
+	})
+}
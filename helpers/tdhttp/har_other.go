@@ -0,0 +1,20 @@
+// Copyright (c) 2021, Maxime Soulé
+// All rights reserved.
+//
+// This source code is licensed under the BSD-style license found in the
+// LICENSE file in the root directory of this source tree.
+
+// +build !go1.14
+
+package tdhttp
+
+// harRegister does nothing as testing.TB has no Cleanup method
+// before go1.14.
+func (t *TestAPI) harRegister() {}
+
+// harRecorded is called each time an exchange is recorded. As the
+// end of the test cannot be detected before go1.14, the HAR archive
+// is rewritten each time.
+func (t *TestAPI) harRecorded() {
+	t.harFinalize()
+}
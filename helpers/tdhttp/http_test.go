@@ -32,6 +32,9 @@ type CmpResponseTest struct {
 	Success      bool
 	ExpectedResp tdhttp.Response
 	ExpectedLogs []string
+	// ExpectedTestAPILogs, if not nil, replaces ExpectedLogs when
+	// testing TestAPI.
+	ExpectedTestAPILogs []string
 }
 
 func TestCmpResponse(tt *testing.T) {
@@ -181,7 +184,19 @@ func TestCmpResponse(tt *testing.T) {
 			ExpectedLogs: []string{
 				`~ Failed test 'body unmarshaling'
 \s+unmarshal\(Response\.Body\): should NOT be an error
-\s+got: .*Cmp(Response|Body) only accepts expected(Resp\.)?Body be a \[\]byte, a string or a TestDeep operator allowing to match these types, but not type int.*
+\s+got: .*CmpResponse only accepts expectedResp\.Body be a \[\]byte, a string or a TestDeep operator allowing to match these types.*
+\s+expected: nil`,
+				`~ Received response:
+\s+\x60(?s:.+?)
+\s+
+\s+text response
+\s+\x60
+`, // check the complete body is shown
+			},
+			ExpectedTestAPILogs: []string{
+				`~ Failed test 'body unmarshaling'
+\s+unmarshal\(Response\.Body\): should NOT be an error
+\s+got: .*body declared as text/plain by Content-Type header cannot be unmarshaled into int: only \[\]byte, string or a TestDeep operator allowing to match these types.*
 \s+expected: nil`,
 				`~ Received response:
 \s+\x60(?s:.+?)
@@ -517,6 +532,9 @@ func testTestAPI(t *td.T,
 
 	t.Cmp(ta.Failed(), !curTest.Success)
 
+	if curTest.ExpectedTestAPILogs != nil {
+		curTest.ExpectedLogs = curTest.ExpectedTestAPILogs
+	}
 	testLogs(t, mockT, curTest)
 }
 
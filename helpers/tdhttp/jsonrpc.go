@@ -0,0 +1,394 @@
+// Copyright (c) 2021, Maxime Soulé
+// All rights reserved.
+//
+// This source code is licensed under the BSD-style license found in the
+// LICENSE file in the root directory of this source tree.
+
+package tdhttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/maxatome/go-testdeep/internal/color"
+	"github.com/maxatome/go-testdeep/internal/ctxerr"
+)
+
+// JSONRPCCall is a JSON-RPC 2.0 call, part of a batch sent by
+// TestAPI.JSONRPCBatch method.
+type JSONRPCCall struct {
+	// Method is the name of the method to be invoked.
+	Method string
+	// Params are the parameters of the call, typically a slice or a
+	// map. Optional.
+	Params interface{}
+	// Notification is true if no response is expected for this call:
+	// in this case, no id is sent.
+	Notification bool
+}
+
+// JSONRPCError is a JSON-RPC 2.0 error object. It can be used as
+// expected value of TestAPI.CmpJSONRPCError method:
+//
+//   ta.CmpJSONRPCError(tdhttp.JSONRPCError{
+//     Code:    -32601,
+//     Message: "Method not found",
+//   })
+//
+//   ta.CmpJSONRPCError(td.SStruct(
+//     tdhttp.JSONRPCError{Code: -32602},
+//     td.StructFields{"Message": td.Contains("invalid")}))
+type JSONRPCError struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+type jsonrpcRequest struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+	ID      *int64      `json:"id,omitempty"`
+}
+
+type jsonrpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result"`
+	Error   json.RawMessage `json:"error"`
+}
+
+// jsonrpcExchange records the ids of the calls sent by the last
+// JSON-RPC request. A nil id means a notification.
+type jsonrpcExchange struct {
+	ids   []*int64
+	batch bool
+}
+
+func (t *TestAPI) jsonrpcRequest(call JSONRPCCall) jsonrpcRequest {
+	req := jsonrpcRequest{
+		JSONRPC: "2.0",
+		Method:  call.Method,
+		Params:  call.Params,
+	}
+	if !call.Notification {
+		t.jsonrpcLastID++
+		id := t.jsonrpcLastID
+		req.ID = &id
+	}
+	return req
+}
+
+// JSONRPC sends a JSON-RPC 2.0 call of "method" with "params" to
+// "target" of the tested API, using a HTTP POST. "params" can be nil
+// if the method has no parameters. The id of the call is
+// automatically generated. The response can then be tested using
+// CmpJSONRPCResult or CmpJSONRPCError, as well as any other Cmp* or
+// NoBody methods:
+//
+//   ta.JSONRPC("/rpc", "subtract", []int{42, 23}).
+//     CmpStatus(http.StatusOK).
+//     CmpJSONRPCResult(19)
+//
+// "Content-Type" header is automatically set to
+// "application/json". Variables are substituted in "params" (see
+// SetVar).
+//
+// Note that Failed() status is reset just after this call.
+//
+// See NewRequest for all possible formats accepted in headers.
+func (t *TestAPI) JSONRPC(target, method string, params interface{}, headers ...interface{}) *TestAPI {
+	req := t.jsonrpcRequest(JSONRPCCall{Method: method, Params: params})
+	t.Request(PostJSON(target, req, headers...))
+	t.jsonrpc = &jsonrpcExchange{ids: []*int64{req.ID}}
+	return t
+}
+
+// JSONRPCNotify sends a JSON-RPC 2.0 notification of "method" with
+// "params" to "target" of the tested API, using a HTTP POST. "params"
+// can be nil if the method has no parameters. As no response is
+// expected for a notification, NoBody is typically used to test it:
+//
+//   ta.JSONRPCNotify("/rpc", "update", []int{1, 2, 3}).
+//     NoBody()
+//
+// See JSONRPC for details.
+func (t *TestAPI) JSONRPCNotify(target, method string, params interface{}, headers ...interface{}) *TestAPI {
+	req := t.jsonrpcRequest(JSONRPCCall{Method: method, Params: params, Notification: true})
+	t.Request(PostJSON(target, req, headers...))
+	t.jsonrpc = &jsonrpcExchange{ids: []*int64{nil}}
+	return t
+}
+
+// JSONRPCBatch sends a JSON-RPC 2.0 batch of "calls" to "target" of
+// the tested API, using a HTTP POST. The id of each call, except
+// notifications, is automatically generated. The response to each
+// call can then be tested using CmpJSONRPCBatchResult and
+// CmpJSONRPCBatchError, responses being matched to calls by id,
+// regardless of their order:
+//
+//   ta.JSONRPCBatch("/rpc", []tdhttp.JSONRPCCall{
+//     {Method: "sum", Params: []int{1, 2, 4}},
+//     {Method: "notify_hello", Params: []int{7}, Notification: true},
+//     {Method: "foo.get", Params: map[string]string{"name": "myself"}},
+//   }).
+//     CmpStatus(http.StatusOK).
+//     CmpJSONRPCBatchResult(0, 7).
+//     CmpJSONRPCBatchError(2, td.Struct(tdhttp.JSONRPCError{Code: -32601}, nil))
+//
+// See JSONRPC for details.
+//
+// It panics if "calls" is empty.
+func (t *TestAPI) JSONRPCBatch(target string, calls []JSONRPCCall, headers ...interface{}) *TestAPI {
+	if len(calls) == 0 {
+		panic(color.Bad("JSONRPCBatch(target, calls, …): calls cannot be empty"))
+	}
+
+	reqs := make([]jsonrpcRequest, len(calls))
+	ids := make([]*int64, len(calls))
+	for i, call := range calls {
+		reqs[i] = t.jsonrpcRequest(call)
+		ids[i] = reqs[i].ID
+	}
+	t.Request(PostJSON(target, reqs, headers...))
+	t.jsonrpc = &jsonrpcExchange{ids: ids, batch: true}
+	return t
+}
+
+// CmpJSONRPCResult tests the result of the last JSON-RPC call (see
+// JSONRPC) against "expectedResult". The result is first unmarshaled
+// into the type of "expectedResult" or into the type behind it if it
+// is a TestDeep operator, or into an interface{} if this type cannot
+// be determined:
+//
+//   ta.JSONRPC("/rpc", "user.get", map[string]int{"id": 42}).
+//     CmpJSONRPCResult(td.JSON(`{"id": 42, "name": "Bob"}`))
+//
+// It fails if the response is not a valid JSON-RPC 2.0 response
+// having the id of the call, or if it contains an error object.
+//
+// It fails if no request has been sent yet, and panics if the last
+// request is not a JSON-RPC call.
+func (t *TestAPI) CmpJSONRPCResult(expectedResult interface{}) *TestAPI {
+	t.t.Helper()
+	t.cmpJSONRPC(-1, "result", expectedResult)
+	return t
+}
+
+// CmpJSONRPCError tests the error object of the last JSON-RPC call
+// (see JSONRPC) against "expectedError". The error object is first
+// unmarshaled into the type of "expectedError" or into the type
+// behind it if it is a TestDeep operator, or into an interface{} if
+// this type cannot be determined. JSONRPCError type is designed for
+// this purpose:
+//
+//   ta.JSONRPC("/rpc", "foobar", nil).
+//     CmpJSONRPCError(tdhttp.JSONRPCError{
+//       Code:    -32601,
+//       Message: "Method not found",
+//     })
+//
+//   ta.JSONRPC("/rpc", "foobar", nil).
+//     CmpJSONRPCError(td.SuperJSONOf(`{"code": -32601}`))
+//
+// It fails if the response is not a valid JSON-RPC 2.0 response
+// having the id of the call, or if it contains a result.
+//
+// It fails if no request has been sent yet, and panics if the last
+// request is not a JSON-RPC call.
+func (t *TestAPI) CmpJSONRPCError(expectedError interface{}) *TestAPI {
+	t.t.Helper()
+	t.cmpJSONRPC(-1, "error", expectedError)
+	return t
+}
+
+// CmpJSONRPCBatchResult tests the result of the call at index "idx"
+// in the last JSON-RPC batch (see JSONRPCBatch) against
+// "expectedResult". The response of this call is found using its id,
+// regardless of the order of responses in the batch. See
+// CmpJSONRPCResult for details.
+//
+// It fails if no request has been sent yet, and panics if the last
+// request is not a JSON-RPC batch, or if "idx" is out of range or
+// designates a notification.
+func (t *TestAPI) CmpJSONRPCBatchResult(idx int, expectedResult interface{}) *TestAPI {
+	t.t.Helper()
+	t.cmpJSONRPC(idx, "result", expectedResult)
+	return t
+}
+
+// CmpJSONRPCBatchError tests the error object of the call at index
+// "idx" in the last JSON-RPC batch (see JSONRPCBatch) against
+// "expectedError". The response of this call is found using its id,
+// regardless of the order of responses in the batch. See
+// CmpJSONRPCError for details.
+//
+// It fails if no request has been sent yet, and panics if the last
+// request is not a JSON-RPC batch, or if "idx" is out of range or
+// designates a notification.
+func (t *TestAPI) CmpJSONRPCBatchError(idx int, expectedError interface{}) *TestAPI {
+	t.t.Helper()
+	t.cmpJSONRPC(idx, "error", expectedError)
+	return t
+}
+
+// cmpJSONRPC compares the "member" ("result" or "error") of the
+// response to the call at index "idx" of the last batch, or of the
+// last single call if "idx" is negative, to "expected".
+func (t *TestAPI) cmpJSONRPC(idx int, member string, expected interface{}) {
+	defer t.t.AnchorsPersistTemporarily()()
+
+	t.t.Helper()
+
+	if !t.checkRequestSent() {
+		t.bodyFailed = true
+		return
+	}
+
+	var rootName string
+	switch {
+	case t.jsonrpc == nil || t.jsonrpc.ids[0] == nil && !t.jsonrpc.batch:
+		if idx < 0 {
+			panic(color.Bad("CmpJSONRPC%s(): no JSON-RPC call sent, see JSONRPC method",
+				jsonrpcMember(member)))
+		}
+		panic(color.Bad("CmpJSONRPCBatch%s(): no JSON-RPC batch sent, see JSONRPCBatch method",
+			jsonrpcMember(member)))
+
+	case idx < 0:
+		if t.jsonrpc.batch {
+			panic(color.Bad("CmpJSONRPC%s(): last JSON-RPC request is a batch, use CmpJSONRPCBatch%[1]s instead",
+				jsonrpcMember(member)))
+		}
+		idx = 0
+		rootName = "Response.Body"
+
+	default:
+		if !t.jsonrpc.batch {
+			panic(color.Bad("CmpJSONRPCBatch%s(): last JSON-RPC request is not a batch, use CmpJSONRPC%[1]s instead",
+				jsonrpcMember(member)))
+		}
+		if idx >= len(t.jsonrpc.ids) {
+			panic(color.Bad("CmpJSONRPCBatch%s(): index %d out of range, only %d calls in the batch",
+				jsonrpcMember(member), idx, len(t.jsonrpc.ids)))
+		}
+		if t.jsonrpc.ids[idx] == nil {
+			panic(color.Bad("CmpJSONRPCBatch%s(): call #%d is a notification, so has no response",
+				jsonrpcMember(member), idx))
+		}
+		rootName = fmt.Sprintf("Response.Body[call#%d]", idx)
+	}
+
+	resp := t.jsonrpcResponse(rootName, *t.jsonrpc.ids[idx])
+	if resp == nil {
+		t.bodyFailed = true
+		if t.autoDumpResponse {
+			t.dumpResponse()
+		}
+		return
+	}
+
+	got, other := resp.Result, resp.Error
+	otherMember := "error"
+	if member == "error" {
+		got, other = other, got
+		otherMember = "result"
+	}
+
+	if len(got) == 0 {
+		t.bodyFailed = true
+		t.t.RootName(rootName).Code(string(other),
+			func(other string) error {
+				return &ctxerr.Error{
+					Message: fmt.Sprintf("%%%% contains %s instead of %s", otherMember, member),
+					Summary: ctxerr.NewSummary(otherMember + ": " + other),
+				}
+			},
+			t.name+"JSON-RPC response should contain "+member)
+		if t.autoDumpResponse {
+			t.dumpResponse()
+		}
+		return
+	}
+
+	tt := t.t.RootName(rootName + "." + member)
+	value, err := unmarshalBody(got, json.Unmarshal, expected)
+	if !tt.RootName("unmarshal("+rootName+"."+member+")").
+		CmpNoError(err, t.name+"JSON-RPC "+member+" unmarshaling") ||
+		!tt.Cmp(value, expected, t.name+"JSON-RPC "+member+" should match") {
+		t.bodyFailed = true
+		if t.autoDumpResponse {
+			t.dumpResponse()
+		}
+	}
+}
+
+func jsonrpcMember(member string) string {
+	if member == "error" {
+		return "Error"
+	}
+	return "Result"
+}
+
+// jsonrpcResponse returns the JSON-RPC response having the id "id"
+// in the last response body. It returns nil and reports a failure if
+// it is not possible.
+func (t *TestAPI) jsonrpcResponse(rootName string, id int64) *jsonrpcResponse {
+	t.t.Helper()
+
+	body := bytes.TrimSpace(t.response.Body.Bytes())
+
+	var resps []jsonrpcResponse
+	var err error
+	if t.jsonrpc.batch {
+		err = json.Unmarshal(body, &resps)
+	} else {
+		resps = make([]jsonrpcResponse, 1)
+		err = json.Unmarshal(body, &resps[0])
+	}
+	if !t.t.RootName("unmarshal(Response.Body)").
+		CmpNoError(err, t.name+"body should be a JSON-RPC response") {
+		return nil
+	}
+
+	rawID := strconv.FormatInt(id, 10)
+	for _, resp := range resps {
+		if string(resp.ID) != rawID {
+			continue
+		}
+
+		var mesg string
+		switch {
+		case resp.JSONRPC != "2.0":
+			mesg = fmt.Sprintf(`jsonrpc member must be "2.0", not %q`, resp.JSONRPC)
+		case len(resp.Result) > 0 && len(resp.Error) > 0:
+			mesg = "result and error members cannot be both present"
+		case len(resp.Result) == 0 && len(resp.Error) == 0:
+			mesg = "either result or error member must be present"
+		}
+		if mesg != "" {
+			t.t.RootName(rootName).Code(mesg,
+				func(mesg string) error {
+					return &ctxerr.Error{
+						Message: "%% is not a valid JSON-RPC 2.0 response",
+						Summary: ctxerr.NewSummary(mesg),
+					}
+				},
+				t.name+"JSON-RPC response should be valid")
+			return nil
+		}
+		return &resp
+	}
+
+	t.t.RootName(rootName).Code(rawID,
+		func(id string) error {
+			return &ctxerr.Error{
+				Message: "%% not found",
+				Summary: ctxerr.NewSummary("no JSON-RPC response with id " + id),
+			}
+		},
+		t.name+"JSON-RPC response should be found")
+	return nil
+}
@@ -0,0 +1,224 @@
+// Copyright (c) 2021, Maxime Soulé
+// All rights reserved.
+//
+// This source code is licensed under the BSD-style license found in the
+// LICENSE file in the root directory of this source tree.
+
+package tdhttp_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/maxatome/go-testdeep/helpers/tdhttp"
+	"github.com/maxatome/go-testdeep/internal/test"
+	"github.com/maxatome/go-testdeep/td"
+)
+
+func jsonrpcServer() http.Handler {
+	type request struct {
+		Method string          `json:"method"`
+		Params []int           `json:"params"`
+		ID     json.RawMessage `json:"id"`
+	}
+
+	call := func(req request) map[string]interface{} {
+		resp := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+		switch req.Method {
+		case "sum":
+			sum := 0
+			for _, p := range req.Params {
+				sum += p
+			}
+			resp["result"] = sum
+		case "bad-version":
+			resp["jsonrpc"] = "1.0"
+			resp["result"] = 0
+		case "both":
+			resp["result"] = 0
+			resp["error"] = 0
+		case "none":
+		case "bad-id":
+			resp["id"] = 0
+			resp["result"] = 0
+		default:
+			resp["error"] = map[string]interface{}{
+				"code":    -32601,
+				"message": "Method not found",
+				"data":    req.Method,
+			}
+		}
+		return resp
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var raw json.RawMessage
+		if err := json.NewDecoder(req.Body).Decode(&raw); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var resp interface{}
+		if raw[0] == '[' {
+			var reqs []request
+			json.Unmarshal(raw, &reqs) //nolint: errcheck
+			var resps []interface{}
+			// Respond in reverse order
+			for i := len(reqs) - 1; i >= 0; i-- {
+				if reqs[i].ID != nil {
+					resps = append(resps, call(reqs[i]))
+				}
+			}
+			resp = resps
+		} else {
+			var r request
+			json.Unmarshal(raw, &r) //nolint: errcheck
+			if r.ID == nil {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			if r.Method == "not-json" {
+				w.Write([]byte("oops")) //nolint: errcheck
+				return
+			}
+			resp = call(r)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp) //nolint: errcheck
+	})
+}
+
+func TestJSONRPC(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		tb, ta := newTestAPI(t, jsonrpcServer())
+
+		ta.JSONRPC("/rpc", "sum", []int{1, 2, 3}).
+			CmpStatus(http.StatusOK).
+			CmpJSONRPCResult(6).
+			CmpJSONRPCResult(td.Between(5, 7)).
+			CmpJSONBody(td.JSON(`{"jsonrpc": "2.0", "id": 1, "result": 6}`))
+
+		ta.JSONRPC("/rpc", "foo", nil).
+			CmpJSONRPCError(tdhttp.JSONRPCError{
+				Code:    -32601,
+				Message: "Method not found",
+				Data:    "foo",
+			}).
+			CmpJSONRPCError(td.SuperJSONOf(`{"code": -32601}`))
+
+		ta.JSONRPCNotify("/rpc", "sum", []int{1}).
+			CmpStatus(http.StatusNoContent).
+			NoBody()
+
+		ta.SetVar("n", 40).
+			JSONRPCBatch("/rpc", []tdhttp.JSONRPCCall{
+				{Method: "sum", Params: []interface{}{1, "{n}"}},
+				{Method: "sum", Params: []int{1}, Notification: true},
+				{Method: "unknown"},
+				{Method: "sum", Params: []int{2, 3}},
+			}).
+			CmpStatus(http.StatusOK).
+			CmpJSONRPCBatchResult(0, 41).
+			CmpJSONRPCBatchError(2, td.Struct(tdhttp.JSONRPCError{Code: -32601}, nil)).
+			CmpJSONRPCBatchResult(3, 5).
+			CmpJSONBody(td.Len(3))
+
+		td.CmpFalse(t, ta.Failed())
+		td.CmpEmpty(t, tb.Messages)
+	})
+
+	t.Run("Failures", func(t *testing.T) {
+		tb, ta := newTestAPI(t, jsonrpcServer())
+
+		td.CmpTrue(t, ta.CmpJSONRPCResult(1).Failed())
+		td.Cmp(t, tb.LastMessage(), td.Contains("Request not sent!"))
+		tb.ResetMessages()
+
+		check := func(expected ...interface{}) {
+			t.Helper()
+			td.CmpTrue(t, ta.Failed())
+			td.Cmp(t, tb.LastMessage(), td.All(expected...))
+			tb.ResetMessages()
+		}
+
+		ta.JSONRPC("/rpc", "sum", []int{1, 2}).CmpJSONRPCResult(4)
+		check(
+			td.Contains("Failed test 'JSON-RPC result should match'"),
+			td.Contains("Response.Body.result: values differ"),
+		)
+
+		ta.JSONRPC("/rpc", "foo", nil).CmpJSONRPCResult(4)
+		check(
+			td.Contains("Failed test 'JSON-RPC response should contain result'"),
+			td.Contains("Response.Body contains error instead of result"),
+			td.Contains(`error: {"code":-32601,"data":"foo","message":"Method not found"}`),
+		)
+
+		ta.JSONRPC("/rpc", "sum", nil).CmpJSONRPCError(td.Ignore())
+		check(
+			td.Contains("Response.Body contains result instead of error"),
+			td.Contains("result: 0"),
+		)
+
+		ta.JSONRPC("/rpc", "sum", nil).CmpJSONRPCResult("zip")
+		check(
+			td.Contains("Failed test 'JSON-RPC result unmarshaling'"),
+			td.Contains("unmarshal(Response.Body.result): should NOT be an error"),
+		)
+
+		ta.JSONRPC("/rpc", "bad-version", nil).CmpJSONRPCResult(0)
+		check(
+			td.Contains("Failed test 'JSON-RPC response should be valid'"),
+			td.Contains("Response.Body is not a valid JSON-RPC 2.0 response"),
+			td.Contains(`jsonrpc member must be "2.0", not "1.0"`),
+		)
+
+		ta.JSONRPC("/rpc", "both", nil).CmpJSONRPCResult(0)
+		check(td.Contains("result and error members cannot be both present"))
+
+		ta.JSONRPC("/rpc", "none", nil).CmpJSONRPCResult(0)
+		check(td.Contains("either result or error member must be present"))
+
+		ta.JSONRPC("/rpc", "bad-id", nil).CmpJSONRPCResult(0)
+		check(
+			td.Contains("Failed test 'JSON-RPC response should be found'"),
+			td.Contains("Response.Body not found"),
+			td.Re(`no JSON-RPC response with id \d+`),
+		)
+
+		ta.JSONRPC("/rpc", "not-json", nil).CmpJSONRPCResult(0)
+		check(
+			td.Contains("Failed test 'body should be a JSON-RPC response'"),
+			td.Contains("unmarshal(Response.Body): should NOT be an error"),
+		)
+
+		ta.JSONRPCBatch("/rpc", []tdhttp.JSONRPCCall{
+			{Method: "sum", Params: []int{1}},
+			{Method: "foo"},
+		}).
+			CmpJSONRPCBatchResult(1, 12)
+		check(td.Contains("Response.Body[call#1] contains error instead of result"))
+
+		test.CheckPanic(t, func() { ta.CmpJSONRPCResult(1) },
+			"CmpJSONRPCResult(): last JSON-RPC request is a batch, use CmpJSONRPCBatchResult instead")
+		test.CheckPanic(t, func() { ta.CmpJSONRPCBatchError(2, 1) },
+			"CmpJSONRPCBatchError(): index 2 out of range, only 2 calls in the batch")
+		test.CheckPanic(t, func() { ta.JSONRPCBatch("/rpc", nil) },
+			"JSONRPCBatch(target, calls, …): calls cannot be empty")
+
+		ta.JSONRPCBatch("/rpc", []tdhttp.JSONRPCCall{{Method: "sum", Notification: true}})
+		test.CheckPanic(t, func() { ta.CmpJSONRPCBatchResult(0, 1) },
+			"CmpJSONRPCBatchResult(): call #0 is a notification, so has no response")
+
+		ta.JSONRPC("/rpc", "sum", nil)
+		test.CheckPanic(t, func() { ta.CmpJSONRPCBatchResult(0, 1) },
+			"CmpJSONRPCBatchResult(): last JSON-RPC request is not a batch, use CmpJSONRPCResult instead")
+
+		ta.Get("/")
+		test.CheckPanic(t, func() { ta.CmpJSONRPCError(1) },
+			"CmpJSONRPCError(): no JSON-RPC call sent, see JSONRPC method")
+		test.CheckPanic(t, func() { ta.CmpJSONRPCBatchError(0, 1) },
+			"CmpJSONRPCBatchError(): no JSON-RPC batch sent, see JSONRPCBatch method")
+	})
+}
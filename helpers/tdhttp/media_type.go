@@ -0,0 +1,243 @@
+// Copyright (c) 2021, Maxime Soulé
+// All rights reserved.
+//
+// This source code is licensed under the BSD-style license found in the
+// LICENSE file in the root directory of this source tree.
+
+package tdhttp
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	mediaTypesMu sync.RWMutex
+	mediaTypes   = map[string]func([]byte, interface{}) error{
+		"application/json":                  json.Unmarshal,
+		"application/xml":                   xml.Unmarshal,
+		"text/xml":                          xml.Unmarshal,
+		"application/x-www-form-urlencoded": unmarshalForm,
+		"text/plain":                        unmarshalRaw,
+		"+json":                             json.Unmarshal,
+		"+xml":                              xml.Unmarshal,
+	}
+
+	contentEncodingsMu sync.RWMutex
+	contentEncodings   = map[string]func(io.Reader) (io.Reader, error){
+		"identity": func(r io.Reader) (io.Reader, error) { return r, nil },
+		"gzip":     func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+		"x-gzip":   func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+		"deflate":  newDeflateReader,
+	}
+)
+
+// RegisterMediaType registers "unmarshal" as the function used by
+// CmpBody to unmarshal response bodies whose Content-Type media type
+// is "mediaType". "mediaType" can also be a structured syntax suffix
+// like "+json", used when no exact media type has been registered.
+// Registering a nil "unmarshal" removes "mediaType" from the
+// registry.
+//
+//   tdhttp.RegisterMediaType("application/msgpack", msgpack.Unmarshal)
+//
+// By default, the following media types are registered:
+//   - application/json and +json suffix → encoding/json.Unmarshal
+//   - application/xml, text/xml and +xml suffix → encoding/xml.Unmarshal
+//   - application/x-www-form-urlencoded → net/url.ParseQuery, only
+//     into url.Values or map[string][]string
+//   - text/plain and any other text/* media type → raw body, only
+//     into string or []byte
+//
+// It is safe to call RegisterMediaType concurrently.
+func RegisterMediaType(mediaType string, unmarshal func([]byte, interface{}) error) {
+	mediaType = strings.ToLower(mediaType)
+
+	mediaTypesMu.Lock()
+	defer mediaTypesMu.Unlock()
+
+	if unmarshal == nil {
+		delete(mediaTypes, mediaType)
+		return
+	}
+	mediaTypes[mediaType] = unmarshal
+}
+
+// RegisterContentEncoding registers "decode" as the function used by
+// CmpBody to decode response bodies whose Content-Encoding is
+// "encoding". Registering a nil "decode" removes "encoding" from the
+// registry.
+//
+// By default, identity, gzip, x-gzip and deflate encodings are
+// registered. Any other encoding has to be registered by the user, as
+// br using github.com/andybalholm/brotli package:
+//
+//   tdhttp.RegisterContentEncoding("br",
+//     func(r io.Reader) (io.Reader, error) {
+//       return brotli.NewReader(r), nil
+//     })
+//
+// It is safe to call RegisterContentEncoding concurrently.
+func RegisterContentEncoding(encoding string, decode func(io.Reader) (io.Reader, error)) {
+	encoding = strings.ToLower(encoding)
+
+	contentEncodingsMu.Lock()
+	defer contentEncodingsMu.Unlock()
+
+	if decode == nil {
+		delete(contentEncodings, encoding)
+		return
+	}
+	contentEncodings[encoding] = decode
+}
+
+// newDeflateReader handles the "deflate" Content-Encoding, that
+// should be zlib format, but is sometimes raw deflate format.
+func newDeflateReader(r io.Reader) (io.Reader, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if zr, err := zlib.NewReader(bytes.NewReader(b)); err == nil {
+		return zr, nil
+	}
+	return flate.NewReader(bytes.NewReader(b)), nil
+}
+
+// unmarshalForm unmarshals an application/x-www-form-urlencoded body.
+func unmarshalForm(body []byte, target interface{}) error {
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		return err
+	}
+
+	switch target := target.(type) {
+	case *url.Values:
+		*target = values
+	case *map[string][]string:
+		*target = values
+	case *interface{}:
+		*target = values
+	default:
+		return fmt.Errorf(
+			"only url.Values, map[string][]string or a TestDeep operator allowing to match these types are accepted, but not type %s",
+			reflect.TypeOf(target).Elem())
+	}
+	return nil
+}
+
+// decodeContentEncoding decodes "body" according to the
+// Content-Encoding "encoding" value, that can list several encodings
+// in the order they have been applied.
+func decodeContentEncoding(body []byte, encoding string) ([]byte, error) {
+	encodings := strings.Split(encoding, ",")
+	for i := len(encodings) - 1; i >= 0; i-- {
+		enc := strings.ToLower(strings.TrimSpace(encodings[i]))
+		if enc == "" {
+			continue
+		}
+
+		contentEncodingsMu.RLock()
+		decode := contentEncodings[enc]
+		contentEncodingsMu.RUnlock()
+
+		if decode == nil {
+			return nil, fmt.Errorf(
+				"Content-Encoding %q is not supported, use tdhttp.RegisterContentEncoding to register a decoder for it",
+				enc)
+		}
+
+		r, err := decode(bytes.NewReader(body))
+		if err == nil {
+			body, err = ioutil.ReadAll(r)
+		}
+		if err != nil {
+			return nil, fmt.Errorf(
+				"body declared as %q encoded by Content-Encoding header cannot be decoded: %s",
+				enc, err)
+		}
+	}
+	return body, nil
+}
+
+// mediaTypeUnmarshaler returns the unmarshal function registered for
+// "mediaType".
+func mediaTypeUnmarshaler(mediaType string) func([]byte, interface{}) error {
+	mediaTypesMu.RLock()
+	defer mediaTypesMu.RUnlock()
+
+	if unmarshal := mediaTypes[mediaType]; unmarshal != nil {
+		return unmarshal
+	}
+	if pos := strings.LastIndexByte(mediaType, '+'); pos >= 0 {
+		if unmarshal := mediaTypes[mediaType[pos:]]; unmarshal != nil {
+			return unmarshal
+		}
+	}
+	if strings.HasPrefix(mediaType, "text/") {
+		return unmarshalRaw
+	}
+	return nil
+}
+
+func registeredMediaTypes() string {
+	mediaTypesMu.RLock()
+	defer mediaTypesMu.RUnlock()
+
+	names := make([]string, 0, len(mediaTypes))
+	for name := range mediaTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// autoUnmarshal returns an unmarshal function choosing the real
+// unmarshaler depending on Content-Type header of "header", after
+// having decoded the body according to Content-Encoding header.
+func autoUnmarshal(header http.Header) func([]byte, interface{}) error {
+	return func(body []byte, target interface{}) error {
+		body, err := decodeContentEncoding(body, header.Get("Content-Encoding"))
+		if err != nil {
+			return err
+		}
+
+		contentType := header.Get("Content-Type")
+		if contentType == "" {
+			return fmt.Errorf("no Content-Type header, cannot guess how to unmarshal the body")
+		}
+
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil {
+			return fmt.Errorf("cannot parse Content-Type header %q: %s", contentType, err)
+		}
+
+		unmarshal := mediaTypeUnmarshaler(mediaType)
+		if unmarshal == nil {
+			return fmt.Errorf(
+				"Content-Type %s is not supported, use tdhttp.RegisterMediaType to register an unmarshaler for it (registered: %s)",
+				mediaType, registeredMediaTypes())
+		}
+
+		if err = unmarshal(body, target); err != nil {
+			return fmt.Errorf(
+				"body declared as %s by Content-Type header cannot be unmarshaled into %s: %s",
+				mediaType, reflect.TypeOf(target).Elem(), err)
+		}
+		return nil
+	}
+}
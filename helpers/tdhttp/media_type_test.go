@@ -0,0 +1,161 @@
+// Copyright (c) 2021, Maxime Soulé
+// All rights reserved.
+//
+// This source code is licensed under the BSD-style license found in the
+// LICENSE file in the root directory of this source tree.
+
+package tdhttp_test
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/maxatome/go-testdeep/helpers/tdhttp"
+	"github.com/maxatome/go-testdeep/td"
+)
+
+func TestCmpBodyAuto(t *testing.T) {
+	type Person struct {
+		ID   int64  `json:"id" xml:"ID"`
+		Name string `json:"name" xml:"Name"`
+	}
+
+	compress := func(enc string, s string) string {
+		var buf bytes.Buffer
+		var w io.WriteCloser
+		switch enc {
+		case "gzip":
+			w = gzip.NewWriter(&buf)
+		case "zlib":
+			w = zlib.NewWriter(&buf)
+		default:
+			w, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+		}
+		io.WriteString(w, s) //nolint: errcheck
+		w.Close()
+		return buf.String()
+	}
+
+	mux := http.NewServeMux()
+	reply := func(path string, body string, headers ...string) {
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			for i := 0; i < len(headers); i += 2 {
+				w.Header().Set(headers[i], headers[i+1])
+			}
+			io.WriteString(w, body) //nolint: errcheck
+		})
+	}
+	reply("/json", `{"id":42,"name":"Bob"}`,
+		"Content-Type", "application/json; charset=utf-8")
+	reply("/problem", `{"id":42,"name":"Bob"}`,
+		"Content-Type", "application/problem+json")
+	reply("/xml", `<Person><ID>42</ID><Name>Bob</Name></Person>`,
+		"Content-Type", "text/xml")
+	reply("/form", `id=42&name=Bob`,
+		"Content-Type", "application/x-www-form-urlencoded")
+	reply("/gzip", compress("gzip", `{"id":42,"name":"Bob"}`),
+		"Content-Type", "application/json",
+		"Content-Encoding", "gzip")
+	reply("/zlib", compress("zlib", `{"id":42,"name":"Bob"}`),
+		"Content-Type", "application/json",
+		"Content-Encoding", "deflate")
+	reply("/deflate", compress("deflate", `{"id":42,"name":"Bob"}`),
+		"Content-Type", "application/json",
+		"Content-Encoding", "deflate")
+	reply("/bad-gzip", "xxx",
+		"Content-Type", "application/json",
+		"Content-Encoding", "gzip")
+	reply("/br", "xxx",
+		"Content-Type", "application/json",
+		"Content-Encoding", "br")
+	reply("/zstd", "xxx",
+		"Content-Type", "application/json",
+		"Content-Encoding", "zstd")
+	reply("/bad-json", `<Person/>`,
+		"Content-Type", "application/json")
+	reply("/custom", `id:42`,
+		"Content-Type", "application/x-custom")
+	reply("/no-content-type", `{"id":42,"name":"Bob"}`,
+		"Content-Type", "")
+
+	t.Run("OK", func(t *testing.T) {
+		tb, ta := newTestAPI(t, mux)
+
+		for _, path := range []string{"/json", "/problem", "/xml", "/gzip", "/zlib", "/deflate"} {
+			ta.Get(path).
+				CmpStatus(http.StatusOK).
+				CmpBody(Person{ID: 42, Name: "Bob"})
+		}
+
+		ta.Get("/json").
+			CmpBody(td.JSON(`{"id": 42, "name": "Bob"}`))
+
+		ta.Get("/gzip").
+			CmpBody(td.SuperMapOf(map[string]interface{}{"name": "Bob"}, nil))
+
+		ta.Get("/form").
+			CmpBody(url.Values{"id": {"42"}, "name": {"Bob"}})
+
+		// raw comparison, Content-Encoding is not decoded
+		ta.Get("/json").
+			CmpBody(td.HasPrefix(`{"id":42`))
+
+		td.CmpFalse(t, ta.Failed())
+		td.CmpEmpty(t, tb.Messages)
+	})
+
+	t.Run("Failures", func(t *testing.T) {
+		for path, expected := range map[string]string{
+			"/zstd":            `Content-Encoding "zstd" is not supported, use tdhttp.RegisterContentEncoding to register a decoder for it`,
+			"/br":              `Content-Encoding "br" is not supported, use tdhttp.RegisterContentEncoding to register a decoder for it`,
+			"/bad-gzip":        `body declared as "gzip" encoded by Content-Encoding header cannot be decoded`,
+			"/bad-json":        `body declared as application/json by Content-Type header cannot be unmarshaled into tdhttp_test.Person: invalid character '<'`,
+			"/custom":          `Content-Type application/x-custom is not supported, use tdhttp.RegisterMediaType to register an unmarshaler for it`,
+			"/no-content-type": `no Content-Type header, cannot guess how to unmarshal the body`,
+			"/form":            `body declared as application/x-www-form-urlencoded by Content-Type header cannot be unmarshaled into tdhttp_test.Person: only url.Values`,
+		} {
+			tb, ta := newTestAPI(t, mux)
+
+			ta.Get(path).CmpBody(Person{ID: 42, Name: "Bob"})
+			td.CmpTrue(t, ta.Failed(), path)
+			td.Cmp(t, strings.Join(tb.Messages, "\n"), td.All(
+				td.Contains("unmarshal(Response.Body): should NOT be an error"),
+				td.Contains(expected),
+			), path)
+		}
+	})
+
+	t.Run("Registry", func(t *testing.T) {
+		tdhttp.RegisterMediaType("application/x-custom",
+			func(b []byte, target interface{}) error {
+				p := target.(*Person)
+				p.ID = 42
+				p.Name = string(b)
+				return nil
+			})
+		defer tdhttp.RegisterMediaType("application/x-custom", nil)
+
+		tdhttp.RegisterContentEncoding("zstd",
+			func(r io.Reader) (io.Reader, error) {
+				return strings.NewReader(`{"id":42,"name":"Zstd"}`), nil
+			})
+		defer tdhttp.RegisterContentEncoding("zstd", nil)
+
+		tb, ta := newTestAPI(t, mux)
+
+		ta.Get("/custom").
+			CmpBody(Person{ID: 42, Name: "id:42"})
+		ta.Get("/zstd").
+			CmpBody(Person{ID: 42, Name: "Zstd"})
+
+		td.CmpFalse(t, ta.Failed())
+		td.CmpEmpty(t, tb.Messages)
+	})
+}
@@ -0,0 +1,263 @@
+// Copyright (c) 2021, Maxime Soulé
+// All rights reserved.
+//
+// This source code is licensed under the BSD-style license found in the
+// LICENSE file in the root directory of this source tree.
+
+package tdhttp
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/maxatome/go-testdeep/internal/color"
+	"github.com/maxatome/go-testdeep/internal/ctxerr"
+)
+
+// nextRecorder is the next handler of a tested middleware. It
+// records the request it receives before calling the real next
+// handler.
+type nextRecorder struct {
+	middleware func(http.Handler) http.Handler
+	next       http.Handler
+
+	mu      sync.Mutex
+	request *http.Request
+}
+
+// newNextRecorder returns a new *nextRecorder calling "next" and the
+// handler composed of "middleware" wrapped around it.
+func newNextRecorder(middleware func(http.Handler) http.Handler, next http.Handler) (*nextRecorder, http.Handler) {
+	n := &nextRecorder{middleware: middleware, next: next}
+	return n, middleware(n)
+}
+
+// clone returns a new *nextRecorder not sharing the recorded request
+// with "n", and the new handler wrapped around it.
+func (n *nextRecorder) clone() (*nextRecorder, http.Handler) {
+	return newNextRecorder(n.middleware, n.next)
+}
+
+func (n *nextRecorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	n.mu.Lock()
+	n.request = req
+	n.mu.Unlock()
+
+	n.next.ServeHTTP(w, req)
+}
+
+func (n *nextRecorder) reset() {
+	n.mu.Lock()
+	n.request = nil
+	n.mu.Unlock()
+}
+
+func (n *nextRecorder) get() *http.Request {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.request
+}
+
+// NewMiddlewareTestAPI creates a TestAPI that can be used to test
+// "middleware". The tested handler is "middleware" wrapped around a
+// next handler recording the request it receives, before passing it
+// to "next". If "next" is nil, the next handler always responds with
+// a 200 status code and an empty body.
+//
+//   ta := tdhttp.NewMiddlewareTestAPI(t, AuthMiddleware, nil)
+//
+//   ta.Get("/admin", "Authorization", "Bearer admin-token").
+//     CmpStatus(http.StatusOK).
+//     CmpNextHeader(td.SuperMapOf(http.Header{"X-User": {"admin"}}, nil)).
+//     CmpNextContextValue(userKey{}, "admin")
+//
+//   ta.Get("/admin").
+//     CmpStatus(http.StatusUnauthorized).
+//     NoNext()
+//
+// Besides all Cmp* methods testing the response, CmpNextRequest,
+// CmpNextHeader, CmpNextPath, CmpNextContextValue and NoNext methods
+// test the request received by the next handler. NextRequest method
+// returns it.
+//
+// As each instance returned by With or Run methods records the
+// requests received by the next handler on its own, "middleware" is
+// called again for each of them.
+//
+// Note that "tb" can be a *testing.T as well as a *td.T.
+func NewMiddlewareTestAPI(tb testing.TB, middleware func(http.Handler) http.Handler, next http.Handler) *TestAPI {
+	if next == nil {
+		next = http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+	}
+	recorder, handler := newNextRecorder(middleware, next)
+
+	ta := NewTestAPI(tb, handler)
+	ta.next = recorder
+	return ta
+}
+
+// NextRequest returns the request received by the next handler of
+// the tested middleware during the last request, or nil if the next
+// handler has not been called. It panics if t has not been created
+// by NewMiddlewareTestAPI.
+func (t *TestAPI) NextRequest() *http.Request {
+	t.checkMiddleware("NextRequest")
+	return t.next.get()
+}
+
+func (t *TestAPI) checkMiddleware(method string) {
+	if t.next == nil {
+		panic(color.Bad("%s(): only usable with a TestAPI created by NewMiddlewareTestAPI", method))
+	}
+}
+
+// cmpNext compares the value returned by "get" from the request
+// received by the next handler to "expected".
+func (t *TestAPI) cmpNext(rootName string, expected interface{}, testName string,
+	get func(*http.Request) interface{},
+) *TestAPI {
+	defer t.t.AnchorsPersistTemporarily()()
+
+	t.t.Helper()
+
+	if !t.checkRequestSent() {
+		t.nextFailed = true
+		return t
+	}
+
+	req := t.next.get()
+	if req == nil {
+		t.nextFailed = true
+		t.t.RootName("NextRequest").Code(false,
+			func(bool) error {
+				return &ctxerr.Error{
+					Message: "%% not received",
+					Summary: ctxerr.NewSummary("next handler has not been called by the middleware"),
+				}
+			},
+			t.name+"next handler should be called")
+	} else if !t.t.RootName(rootName).Cmp(get(req), expected, t.name+testName) {
+		t.nextFailed = true
+	}
+
+	if t.nextFailed && t.autoDumpResponse {
+		t.dumpResponse()
+	}
+	return t
+}
+
+// CmpNextRequest tests the request received by the next handler of
+// the tested middleware against "expectedRequest", typically using
+// Smuggle or Struct operators:
+//
+//   ta.Get("/api/v1/users").
+//     CmpNextRequest(td.Smuggle("URL.RawQuery", "version=1"))
+//
+// It fails if no request has been sent yet or if the next handler has
+// not been called. It panics if t has not been created by
+// NewMiddlewareTestAPI.
+func (t *TestAPI) CmpNextRequest(expectedRequest interface{}) *TestAPI {
+	t.checkMiddleware("CmpNextRequest")
+	t.t.Helper()
+	return t.cmpNext("NextRequest", expectedRequest,
+		"next request should match",
+		func(req *http.Request) interface{} { return req })
+}
+
+// CmpNextHeader tests the header of the request received by the next
+// handler of the tested middleware against "expectedHeader". As for
+// CmpHeader, "expectedHeader" can be a http.Header or a TestDeep
+// operator:
+//
+//   ta.Get("/").
+//     CmpNextHeader(td.ContainsKey("X-Request-Id"))
+//
+// It fails if no request has been sent yet or if the next handler has
+// not been called. It panics if t has not been created by
+// NewMiddlewareTestAPI.
+func (t *TestAPI) CmpNextHeader(expectedHeader interface{}) *TestAPI {
+	t.checkMiddleware("CmpNextHeader")
+	t.t.Helper()
+	return t.cmpNext("NextRequest.Header", expectedHeader,
+		"next request header should match",
+		func(req *http.Request) interface{} { return req.Header })
+}
+
+// CmpNextPath tests the URL path of the request received by the next
+// handler of the tested middleware against "expectedPath", typically
+// to check a path rewriting:
+//
+//   ta.Get("/api/v1/users").
+//     CmpNextPath("/users")
+//
+// It fails if no request has been sent yet or if the next handler has
+// not been called. It panics if t has not been created by
+// NewMiddlewareTestAPI.
+func (t *TestAPI) CmpNextPath(expectedPath interface{}) *TestAPI {
+	t.checkMiddleware("CmpNextPath")
+	t.t.Helper()
+	return t.cmpNext("NextRequest.URL.Path", expectedPath,
+		"next request path should match",
+		func(req *http.Request) interface{} { return req.URL.Path })
+}
+
+// CmpNextContextValue tests the value associated with "key" in the
+// context of the request received by the next handler of the tested
+// middleware against "expectedValue":
+//
+//   ta.Get("/", "Authorization", "Bearer token").
+//     CmpNextContextValue(userKey{}, td.Struct(&User{Name: "Bob"}, nil))
+//
+// It fails if no request has been sent yet or if the next handler has
+// not been called. It panics if t has not been created by
+// NewMiddlewareTestAPI.
+func (t *TestAPI) CmpNextContextValue(key, expectedValue interface{}) *TestAPI {
+	t.checkMiddleware("CmpNextContextValue")
+	t.t.Helper()
+	return t.cmpNext(fmt.Sprintf("NextRequest.Context().Value(%v)", key),
+		expectedValue,
+		"next request context value should match",
+		func(req *http.Request) interface{} { return req.Context().Value(key) })
+}
+
+// NoNext tests that the next handler of the tested middleware has not
+// been called during the last request, typically because the
+// middleware rejected it:
+//
+//   ta.Get("/admin").
+//     CmpStatus(http.StatusUnauthorized).
+//     NoNext()
+//
+// It fails if no request has been sent yet. It panics if t has not
+// been created by NewMiddlewareTestAPI.
+func (t *TestAPI) NoNext() *TestAPI {
+	t.checkMiddleware("NoNext")
+	t.t.Helper()
+
+	if !t.checkRequestSent() {
+		t.nextFailed = true
+		return t
+	}
+
+	t.nextFailed = !t.t.RootName("NextRequest").
+		Code(t.next.get(),
+			func(req *http.Request) error {
+				if req == nil {
+					return nil
+				}
+				return &ctxerr.Error{
+					Message: "%% received",
+					Summary: ctxerr.NewSummary(
+						"next handler has been called by the middleware with " +
+							req.Method + " " + req.URL.String()),
+				}
+			},
+			t.name+"next handler should not be called")
+
+	if t.nextFailed && t.autoDumpResponse {
+		t.dumpResponse()
+	}
+	return t
+}
@@ -0,0 +1,156 @@
+// Copyright (c) 2021, Maxime Soulé
+// All rights reserved.
+//
+// This source code is licensed under the BSD-style license found in the
+// LICENSE file in the root directory of this source tree.
+
+package tdhttp_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/maxatome/go-testdeep/helpers/tdhttp"
+	"github.com/maxatome/go-testdeep/internal/test"
+	"github.com/maxatome/go-testdeep/td"
+)
+
+type userKey struct{}
+
+func authMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		user := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+		if user == "" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		req.Header.Set("X-User", user)
+		req.URL.Path = strings.TrimPrefix(req.URL.Path, "/api")
+		next.ServeHTTP(w, req.WithContext(
+			context.WithValue(req.Context(), userKey{}, user)))
+	})
+}
+
+func TestMiddlewareTestAPI(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		tb := test.NewTestingTB(t.Name())
+		ta := tdhttp.NewMiddlewareTestAPI(tb, authMiddleware, nil)
+
+		ta.Get("/api/users", "Authorization", "Bearer bob").
+			CmpStatus(http.StatusOK).
+			NoBody().
+			CmpNextPath("/users").
+			CmpNextHeader(td.SuperMapOf(http.Header{"X-User": {"bob"}}, nil)).
+			CmpNextContextValue(userKey{}, "bob").
+			CmpNextRequest(td.Smuggle("Method", "GET"))
+		td.CmpFalse(t, ta.Failed())
+		td.Cmp(t, ta.NextRequest(), td.Smuggle("URL.Path", "/users"))
+
+		ta.Get("/api/users").
+			CmpStatus(http.StatusUnauthorized).
+			NoNext()
+		td.CmpFalse(t, ta.Failed())
+		td.CmpNil(t, ta.NextRequest())
+
+		td.CmpEmpty(t, tb.Messages)
+	})
+
+	t.Run("Next handler", func(t *testing.T) {
+		tb := test.NewTestingTB(t.Name())
+		ta := tdhttp.NewMiddlewareTestAPI(tb, authMiddleware,
+			http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				w.Write([]byte("Hello " + req.Context().Value(userKey{}).(string))) //nolint: errcheck
+			}))
+
+		ta.Get("/api/hello", "Authorization", "Bearer alice").
+			CmpStatus(http.StatusOK).
+			CmpBody("Hello alice").
+			CmpNextPath("/hello")
+		td.CmpFalse(t, ta.Failed())
+
+		ta.Run("sub", func(ta *tdhttp.TestAPI) {
+			ta.Get("/api/sub", "Authorization", "Bearer alice").
+				CmpNextPath("/sub")
+			td.CmpFalse(t, ta.Failed())
+		})
+
+		td.Cmp(t, tb.Messages, []string{"++++ sub"})
+	})
+
+	t.Run("With", func(t *testing.T) {
+		tb := test.NewTestingTB(t.Name())
+		ta := tdhttp.NewMiddlewareTestAPI(tb, authMiddleware, nil)
+
+		// Each instance records its own next request
+		ta1, ta2 := ta.With(tb), ta.With(tb)
+		ta1.Get("/api/one", "Authorization", "Bearer bob")
+		ta2.Get("/api/two", "Authorization", "Bearer bob")
+
+		ta1.CmpNextPath("/one")
+		ta2.CmpNextPath("/two")
+		td.CmpFalse(t, ta1.Failed())
+		td.CmpFalse(t, ta2.Failed())
+		td.CmpNil(t, ta.NextRequest())
+
+		td.CmpEmpty(t, tb.Messages)
+	})
+
+	t.Run("Failures", func(t *testing.T) {
+		tb := test.NewTestingTB(t.Name())
+		ta := tdhttp.NewMiddlewareTestAPI(tb, authMiddleware, nil)
+
+		td.CmpTrue(t, ta.CmpNextPath("/").Failed())
+		td.Cmp(t, tb.LastMessage(), td.Contains("Request not sent!"))
+		tb.ResetMessages()
+
+		td.CmpTrue(t, ta.NoNext().Failed())
+		td.Cmp(t, tb.LastMessage(), td.Contains("Request not sent!"))
+		tb.ResetMessages()
+
+		ta.Get("/api/users", "Authorization", "Bearer bob").CmpNextPath("/api/users")
+		td.CmpTrue(t, ta.Failed())
+		td.Cmp(t, tb.LastMessage(), td.All(
+			td.Contains("Failed test 'next request path should match'"),
+			td.Contains("NextRequest.URL.Path: values differ"),
+		))
+		tb.ResetMessages()
+
+		ta.Get("/api/users", "Authorization", "Bearer bob").
+			CmpNextContextValue(userKey{}, "alice")
+		td.CmpTrue(t, ta.Failed())
+		td.Cmp(t, tb.LastMessage(), td.All(
+			td.Contains("Failed test 'next request context value should match'"),
+			td.Contains("NextRequest.Context().Value({}): values differ"),
+		))
+		tb.ResetMessages()
+
+		ta.Get("/api/users", "Authorization", "Bearer bob").NoNext()
+		td.CmpTrue(t, ta.Failed())
+		td.Cmp(t, tb.LastMessage(), td.All(
+			td.Contains("Failed test 'next handler should not be called'"),
+			td.Contains("NextRequest received"),
+			td.Contains("next handler has been called by the middleware with GET /users"),
+		))
+		tb.ResetMessages()
+
+		ta.Get("/api/users").CmpNextHeader(td.Ignore())
+		td.CmpTrue(t, ta.Failed())
+		td.Cmp(t, tb.LastMessage(), td.All(
+			td.Contains("Failed test 'next handler should be called'"),
+			td.Contains("NextRequest not received"),
+		))
+		tb.ResetMessages()
+
+		// Failed() status is reset by a new request
+		ta.Get("/api/users").CmpStatus(http.StatusUnauthorized)
+		td.CmpFalse(t, ta.Failed())
+
+		ta = tdhttp.NewTestAPI(tb, authMiddleware(nil))
+		test.CheckPanic(t, func() { ta.NoNext() },
+			"NoNext(): only usable with a TestAPI created by NewMiddlewareTestAPI")
+		test.CheckPanic(t, func() { ta.NextRequest() },
+			"NextRequest(): only usable with a TestAPI created by NewMiddlewareTestAPI")
+	})
+}
@@ -0,0 +1,510 @@
+// Copyright (c) 2021, Maxime Soulé
+// All rights reserved.
+//
+// This source code is licensed under the BSD-style license found in the
+// LICENSE file in the root directory of this source tree.
+
+package tdhttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/maxatome/go-testdeep/helpers/tdutil"
+	"github.com/maxatome/go-testdeep/internal/color"
+	"github.com/maxatome/go-testdeep/td"
+)
+
+// MockServer is a HTTP server standing for an upstream API the
+// tested code calls. Expectations are declared using Expect method,
+// each one being bound to a response. See NewMockServer function to
+// create a new instance and get some examples of use.
+type MockServer struct {
+	t      *td.T
+	server *httptest.Server
+
+	mu           sync.Mutex
+	expectations []*Expectation
+	unexpected   []*mockRequest
+	inOrder      bool
+	cursor       int
+}
+
+// Expectation is an expected request of a MockServer, bound to a
+// response. See MockServer.Expect method.
+type Expectation struct {
+	ms *MockServer
+
+	name   string
+	method interface{}
+	path   interface{}
+	query  interface{}
+	header interface{}
+	body   interface{}
+
+	bodyUnmarshal func([]byte, interface{}) error
+
+	minCalls, maxCalls int // maxCalls < 0 means unlimited
+	calls              int
+
+	respond http.HandlerFunc
+}
+
+// mockRequest is a request received by a MockServer.
+type mockRequest struct {
+	method string
+	url    *url.URL
+	header http.Header
+	body   []byte
+}
+
+// NewMockServer creates and starts a MockServer using "tb" to report
+// failures. The server has to be closed by the caller when finished,
+// typically using a defer statement, and Verify has to be called to
+// check all expectations have been satisfied and no unexpected
+// requests have been received:
+//
+//   ms := tdhttp.NewMockServer(t)
+//   defer ms.Close()
+//
+//   ms.Expect("GET", "/person/42").
+//     Header(td.SuperMapOf(http.Header{"Accept": {"application/json"}}, nil)).
+//     RespondJSON(http.StatusOK, Person{ID: 42, Name: "Bob", Age: 26})
+//
+//   ms.Expect("POST", "/person").
+//     JSONBody(`{"name": HasPrefix("Bo"), "age": $1}`, td.Between(20, 30)).
+//     RespondJSON(http.StatusCreated, Person{ID: 43, Name: "Bob", Age: 26}).
+//     Times(2)
+//
+//   client := NewPersonClient(ms.URL()) // the tested code
+//   …
+//
+//   ms.Verify()
+//
+// All expectations have to be declared before the tested code sends
+// its first request, see Expect method.
+//
+// Note that "tb" can be a *testing.T as well as a *td.T.
+func NewMockServer(tb testing.TB) *MockServer {
+	ms := &MockServer{
+		t: td.NewT(tb),
+	}
+	ms.server = httptest.NewServer(ms)
+	return ms
+}
+
+// URL returns the base URL of the server, of the form
+// http://ipaddr:port with no trailing slash.
+func (ms *MockServer) URL() string {
+	return ms.server.URL
+}
+
+// Client returns a *http.Client configured to make requests to the
+// server.
+func (ms *MockServer) Client() *http.Client {
+	return ms.server.Client()
+}
+
+// Close shuts down the server and blocks until all outstanding
+// requests on this server have completed.
+func (ms *MockServer) Close() {
+	ms.server.Close()
+}
+
+// InOrder enables the ordering of expectations: requests have to be
+// received in the order the expectations are declared. An expectation
+// can only be matched if all the previous ones have been called at
+// least their minimum number of times, and once an expectation has
+// been matched, the previous ones cannot be matched anymore.
+func (ms *MockServer) InOrder() *MockServer {
+	ms.mu.Lock()
+	ms.inOrder = true
+	ms.mu.Unlock()
+	return ms
+}
+
+// Expect declares a new expectation whose request method has to match
+// "method" and URL path has to match "path". Each of them can be a
+// string or a TestDeep operator, as in:
+//
+//   ms.Expect("GET", td.Re(`^/person/\d+\z`))
+//   ms.Expect(td.Any("PUT", "PATCH"), "/person/42")
+//
+// By default, the expectation has to be matched exactly once (see
+// Times, AtLeast, AtMost and AnyTimes to change this behavior) and
+// responds with an empty 200 response (see Respond, RespondJSON and
+// RespondWith to change this behavior).
+//
+// When several expectations match a received request, the first
+// declared one that has not reached its maximum number of calls wins.
+//
+// The expectation is registered, and so can be matched, as soon as
+// Expect returns. As a request received before the end of its
+// configuration would be matched against a half-configured
+// expectation, all expectations have to be fully declared before the
+// tested code starts sending requests to the server.
+func (ms *MockServer) Expect(method, path interface{}) *Expectation {
+	e := &Expectation{
+		ms:       ms,
+		method:   method,
+		path:     path,
+		minCalls: 1,
+		maxCalls: 1,
+	}
+	ms.mu.Lock()
+	ms.expectations = append(ms.expectations, e)
+	ms.mu.Unlock()
+	return e
+}
+
+// Name allows to name the expectation. This name is used in case of
+// failure to qualify the expectation. If len(args) > 1 and the first
+// item of "args" is a string and contains a '%' rune then
+// fmt.Fprintf is used to compose the name, else "args" are passed to
+// fmt.Fprint.
+func (e *Expectation) Name(args ...interface{}) *Expectation {
+	name := tdutil.BuildTestName(args...)
+	return e.locked(func() { e.name = name })
+}
+
+// Query sets the expected query parameters of the request. "expected"
+// can be a url.Values or a TestDeep operator. Keep in mind that if it
+// is a url.Values, it has to match exactly the query parameters,
+// td.SuperMapOf is often more appropriate:
+//
+//   ms.Expect("GET", "/persons").
+//     Query(td.SuperMapOf(url.Values{"page": {"2"}}, nil))
+func (e *Expectation) Query(expected interface{}) *Expectation {
+	return e.locked(func() { e.query = expected })
+}
+
+// Header sets the expected header of the request. "expected" can be
+// a http.Header or a TestDeep operator. As headers generally contain
+// a lot of keys automatically added by the HTTP client, td.SuperMapOf
+// or td.ContainsKey are often more appropriate:
+//
+//   ms.Expect("GET", "/persons").
+//     Header(td.ContainsKey("Authorization"))
+func (e *Expectation) Header(expected interface{}) *Expectation {
+	return e.locked(func() { e.header = expected })
+}
+
+// Body sets the expected raw body of the request. "expected" can be a
+// []byte, a string or a TestDeep operator allowing to match these
+// types.
+func (e *Expectation) Body(expected interface{}) *Expectation {
+	return e.locked(func() {
+		e.body = expected
+		e.bodyUnmarshal = unmarshalRaw
+	})
+}
+
+// JSONBody sets the expected JSON body of the request.
+//
+// If "expected" is a string or a []byte, it is handled as td.JSON
+// does, and "params" are its placeholder parameters:
+//
+//   ms.Expect("POST", "/person").
+//     JSONBody(`{"name": $name, "age": $1}`,
+//       td.Between(20, 30),
+//       td.Tag("name", td.HasPrefix("Bo")))
+//
+// Otherwise, "expected" can be any type encoding/json can Unmarshal
+// into, or a TestDeep operator, and "params" have to be empty:
+//
+//   ms.Expect("POST", "/person").
+//     JSONBody(Person{Name: "Bob", Age: 26})
+func (e *Expectation) JSONBody(expected interface{}, params ...interface{}) *Expectation {
+	switch expected.(type) {
+	case string, []byte:
+		expected = td.JSON(expected, params...)
+	default:
+		if len(params) > 0 {
+			panic(color.Bad("JSONBody(): params are only allowed when expected is a string or a []byte, not a %T", expected))
+		}
+	}
+	return e.locked(func() {
+		e.body = expected
+		e.bodyUnmarshal = json.Unmarshal
+	})
+}
+
+// Times sets the exact number of times the expectation has to be
+// matched.
+func (e *Expectation) Times(n int) *Expectation {
+	return e.locked(func() { e.minCalls, e.maxCalls = n, n })
+}
+
+// Once is a shortcut for Times(1).
+func (e *Expectation) Once() *Expectation {
+	return e.Times(1)
+}
+
+// AtLeast sets the minimum number of times the expectation has to be
+// matched. The maximum number of times becomes unlimited.
+func (e *Expectation) AtLeast(n int) *Expectation {
+	return e.locked(func() { e.minCalls, e.maxCalls = n, -1 })
+}
+
+// AtMost sets the maximum number of times the expectation can be
+// matched. The minimum number of times becomes 0.
+func (e *Expectation) AtMost(n int) *Expectation {
+	return e.locked(func() { e.minCalls, e.maxCalls = 0, n })
+}
+
+// AnyTimes allows the expectation to be matched any number of times,
+// including never.
+func (e *Expectation) AnyTimes() *Expectation {
+	return e.locked(func() { e.minCalls, e.maxCalls = 0, -1 })
+}
+
+// Respond sets the canned response of the expectation. "body" can be
+// nil (empty body), a string, a []byte or any other value that is
+// then JSON-encoded (in this last case, "Content-Type" header is
+// automatically set to "application/json"). See NewRequest for all
+// possible formats accepted in headers.
+//
+//   ms.Expect("GET", "/ping").
+//     Respond(http.StatusOK, "pong", "Content-Type", "text/plain")
+func (e *Expectation) Respond(status int, body interface{}, headers ...interface{}) *Expectation {
+	respond := newResponder(status, body, headers)
+	return e.locked(func() { e.respond = respond })
+}
+
+// RespondJSON sets the canned response of the expectation, "body"
+// being JSON-encoded. "Content-Type" header is automatically set to
+// "application/json". See NewRequest for all possible formats
+// accepted in headers.
+//
+//   ms.Expect("GET", "/person/42").
+//     RespondJSON(http.StatusOK, Person{ID: 42, Name: "Bob", Age: 26})
+func (e *Expectation) RespondJSON(status int, body interface{}, headers ...interface{}) *Expectation {
+	respond := newJSONResponder(status, body, headers)
+	return e.locked(func() { e.respond = respond })
+}
+
+// RespondWith sets the function computing the response of the
+// expectation. The body of the request received by "fn" can be read
+// even if it has already been read to check the expectation.
+//
+//   ms.Expect("POST", "/echo").
+//     RespondWith(func(w http.ResponseWriter, r *http.Request) {
+//       io.Copy(w, r.Body)
+//     })
+func (e *Expectation) RespondWith(fn func(w http.ResponseWriter, r *http.Request)) *Expectation {
+	return e.locked(func() { e.respond = fn })
+}
+
+// locked calls "set" with the server lock held, so the expectation
+// can be modified while the server handles requests.
+func (e *Expectation) locked(set func()) *Expectation {
+	e.ms.mu.Lock()
+	defer e.ms.mu.Unlock()
+	set()
+	return e
+}
+
+// String returns a short representation of the expectation, as
+// "GET /person/42".
+func (e *Expectation) String() string {
+	s := mockString(e.method) + " " + mockString(e.path)
+	if e.name != "" {
+		s = e.name + " (" + s + ")"
+	}
+	return s
+}
+
+func mockString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case td.TestDeep:
+		return v.String()
+	}
+	return fmt.Sprint(v)
+}
+
+func (e *Expectation) exhausted() bool {
+	return e.maxCalls >= 0 && e.calls >= e.maxCalls
+}
+
+func (e *Expectation) satisfied() bool {
+	return e.calls >= e.minCalls
+}
+
+// check calls "cmp" for each field set in the expectation. If "cmp"
+// returns false, check stops and returns false.
+func (e *Expectation) check(req *mockRequest,
+	cmp func(rootName string, got, expected interface{}) bool,
+) bool {
+	if !cmp("Request.Method", req.method, e.method) ||
+		!cmp("Request.URL.Path", req.url.Path, e.path) {
+		return false
+	}
+	if e.query != nil && !cmp("Request.URL.Query", req.url.Query(), e.query) {
+		return false
+	}
+	if e.header != nil && !cmp("Request.Header", req.header, e.header) {
+		return false
+	}
+	if e.body != nil {
+		body, err := unmarshalBody(req.body, e.bodyUnmarshal, e.body)
+		if err != nil {
+			return cmp("unmarshal(Request.Body)", err, nil)
+		}
+		return cmp("Request.Body", body, e.body)
+	}
+	return true
+}
+
+func (e *Expectation) match(req *mockRequest) bool {
+	return e.check(req, func(_ string, got, expected interface{}) bool {
+		return td.EqDeeply(got, td.Lax(expected))
+	})
+}
+
+// score returns the number of consecutive fields of "req" matching
+// the expectation.
+func (e *Expectation) score(req *mockRequest) int {
+	score := 0
+	e.check(req, func(_ string, got, expected interface{}) bool {
+		if td.EqDeeply(got, td.Lax(expected)) {
+			score++
+			return true
+		}
+		return false
+	})
+	return score
+}
+
+// ServeHTTP implements http.Handler interface. It allows to use the
+// MockServer without starting any network listener, for example with
+// a TestAPI instance.
+func (ms *MockServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	req := &mockRequest{
+		method: r.Method,
+		url:    r.URL,
+		header: r.Header,
+	}
+	if r.Body != nil {
+		req.body, _ = ioutil.ReadAll(r.Body)
+		r.Body.Close()
+		r.Body = ioutil.NopCloser(bytes.NewReader(req.body))
+	}
+
+	e, respond := ms.findExpectation(req)
+	if e == nil {
+		http.Error(w,
+			fmt.Sprintf("tdhttp.MockServer: unexpected request %s %s", r.Method, r.URL),
+			http.StatusNotImplemented)
+		return
+	}
+
+	if respond != nil {
+		respond(w, r)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// findExpectation returns the expectation matching "req" and its
+// responder, read with the lock held, or nil if none matches.
+func (ms *MockServer) findExpectation(req *mockRequest) (*Expectation, http.HandlerFunc) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	for i := ms.cursor; i < len(ms.expectations); i++ {
+		e := ms.expectations[i]
+		if !e.exhausted() && e.match(req) {
+			e.calls++
+			if ms.inOrder {
+				ms.cursor = i
+			}
+			return e, e.respond
+		}
+		if ms.inOrder && !e.satisfied() {
+			break
+		}
+	}
+
+	ms.unexpected = append(ms.unexpected, req)
+	return nil, nil
+}
+
+// Verify checks that all expectations have been satisfied and that
+// no unexpected requests have been received. For each unexpected
+// request, the differences with the closest expectation are
+// reported.
+//
+// It returns true if the verification succeeds, false otherwise.
+func (ms *MockServer) Verify() bool {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.t.Helper()
+
+	ok := true
+	for _, e := range ms.expectations {
+		if !e.satisfied() {
+			ms.t.Errorf("expectation %s not satisfied: called %d times, but expected %s",
+				e, e.calls, e.callsString())
+			ok = false
+		}
+	}
+
+	for _, req := range ms.unexpected {
+		ok = false
+
+		closest, best := (*Expectation)(nil), -1
+		for _, e := range ms.expectations {
+			if score := e.score(req); score > best {
+				closest, best = e, score
+			}
+		}
+
+		if closest == nil {
+			ms.t.Errorf("unexpected request %s %s, no expectations declared",
+				req.method, req.url)
+			continue
+		}
+
+		// Everything matches but the expectation is exhausted or
+		// the request came out of order
+		if closest.match(req) {
+			if closest.exhausted() {
+				ms.t.Errorf("unexpected request %s %s, closest expectation %s already called %d times, but expected %s",
+					req.method, req.url, closest, closest.calls, closest.callsString())
+			} else {
+				ms.t.Errorf("unexpected request %s %s, expectation %s matches but is out of order",
+					req.method, req.url, closest)
+			}
+			continue
+		}
+
+		name := fmt.Sprintf("unexpected request %s %s, closest expectation %s",
+			req.method, req.url, closest)
+		closest.check(req, func(rootName string, got, expected interface{}) bool {
+			return ms.t.RootName(rootName).CmpLax(got, expected, name)
+		})
+	}
+
+	return ok
+}
+
+func (e *Expectation) callsString() string {
+	switch {
+	case e.minCalls == e.maxCalls:
+		return fmt.Sprintf("exactly %d", e.minCalls)
+	case e.maxCalls < 0:
+		return fmt.Sprintf("at least %d", e.minCalls)
+	default:
+		return fmt.Sprintf("at most %d", e.maxCalls)
+	}
+}
@@ -0,0 +1,234 @@
+// Copyright (c) 2021, Maxime Soulé
+// All rights reserved.
+//
+// This source code is licensed under the BSD-style license found in the
+// LICENSE file in the root directory of this source tree.
+
+package tdhttp_test
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/maxatome/go-testdeep/helpers/tdhttp"
+	"github.com/maxatome/go-testdeep/internal/test"
+	"github.com/maxatome/go-testdeep/td"
+)
+
+func mockDo(t *testing.T, ms *tdhttp.MockServer, req *http.Request) (int, string) {
+	t.Helper()
+
+	u, err := url.Parse(ms.URL() + req.URL.String())
+	td.Require(t).CmpNoError(err)
+	req.URL = u
+
+	resp, err := ms.Client().Do(clientRequest(req))
+	td.Require(t).CmpNoError(err)
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	td.Require(t).CmpNoError(err)
+	return resp.StatusCode, string(b)
+}
+
+func TestMockServer(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		tb := test.NewTestingTB(t.Name())
+		ms := tdhttp.NewMockServer(tb)
+		defer ms.Close()
+
+		ms.Expect("GET", "/ping").
+			Respond(http.StatusOK, "pong", "Content-Type", "text/plain")
+		ms.Expect("POST", td.Re(`^/person/\d+\z`)).
+			Query(td.SuperMapOf(url.Values{"force": {"1"}}, nil)).
+			Header(td.ContainsKey("X-Token")).
+			JSONBody(`{"name": HasPrefix("Bo"), "age": $1}`, td.Between(20, 30)).
+			RespondJSON(http.StatusCreated, map[string]int{"id": 42}).
+			Times(2)
+		ms.Expect("PUT", "/echo").
+			Body(td.Contains("echo")).
+			RespondWith(func(w http.ResponseWriter, r *http.Request) {
+				io.Copy(w, r.Body) //nolint: errcheck
+			})
+		ms.Expect("DELETE", "/person/42").AnyTimes()
+
+		status, body := mockDo(t, ms, tdhttp.Get("/ping"))
+		td.Cmp(t, status, http.StatusOK)
+		td.Cmp(t, body, "pong")
+
+		for i := 0; i < 2; i++ {
+			status, body = mockDo(t, ms, tdhttp.PostJSON("/person/12?force=1",
+				map[string]interface{}{"name": "Bob", "age": 26},
+				"X-Token", "secret"))
+			td.Cmp(t, status, http.StatusCreated)
+			td.Cmp(t, body, `{"id":42}`)
+		}
+
+		status, body = mockDo(t, ms, tdhttp.Put("/echo", strings.NewReader("echo!")))
+		td.Cmp(t, status, http.StatusOK)
+		td.Cmp(t, body, "echo!")
+
+		td.CmpTrue(t, ms.Verify())
+		td.CmpFalse(t, tb.HasFailed)
+		td.CmpEmpty(t, tb.Messages)
+	})
+
+	t.Run("Unsatisfied expectation", func(t *testing.T) {
+		tb := test.NewTestingTB(t.Name())
+		ms := tdhttp.NewMockServer(tb)
+		defer ms.Close()
+
+		ms.Expect("GET", "/ping").Name("ping")
+		ms.Expect("GET", "/pong").AtLeast(2)
+
+		status, _ := mockDo(t, ms, tdhttp.Get("/pong"))
+		td.Cmp(t, status, http.StatusOK)
+
+		td.CmpFalse(t, ms.Verify())
+		td.Cmp(t, tb.Messages, []string{
+			"expectation ping (GET /ping) not satisfied: called 0 times, but expected exactly 1",
+			"expectation GET /pong not satisfied: called 1 times, but expected at least 2",
+		})
+	})
+
+	t.Run("Unexpected request", func(t *testing.T) {
+		tb := test.NewTestingTB(t.Name())
+		ms := tdhttp.NewMockServer(tb)
+		defer ms.Close()
+
+		status, body := mockDo(t, ms, tdhttp.Get("/nope"))
+		td.Cmp(t, status, http.StatusNotImplemented)
+		td.Cmp(t, body, td.Contains("unexpected request GET /nope"))
+
+		td.CmpFalse(t, ms.Verify())
+		td.Cmp(t, tb.Messages, []string{
+			"unexpected request GET /nope, no expectations declared",
+		})
+	})
+
+	t.Run("Closest expectation", func(t *testing.T) {
+		tb := test.NewTestingTB(t.Name())
+		ms := tdhttp.NewMockServer(tb)
+		defer ms.Close()
+
+		ms.Expect("GET", "/person/42").AnyTimes()
+		ms.Expect("POST", "/person").
+			JSONBody(`{"name": "Bob", "age": 26}`).
+			AnyTimes()
+
+		status, _ := mockDo(t, ms, tdhttp.PostJSON("/person",
+			map[string]interface{}{"name": "Alice", "age": 26}))
+		td.Cmp(t, status, http.StatusNotImplemented)
+
+		td.CmpFalse(t, ms.Verify())
+		td.Cmp(t, tb.Messages, td.Len(1))
+		td.Cmp(t, tb.Messages[0], td.All(
+			td.Contains("unexpected request POST /person, closest expectation POST /person"),
+			td.Contains(`Request.Body["name"]: values differ`),
+			td.Contains(`"Alice"`),
+			td.Contains(`"Bob"`),
+		))
+	})
+
+	t.Run("Exhausted expectation", func(t *testing.T) {
+		tb := test.NewTestingTB(t.Name())
+		ms := tdhttp.NewMockServer(tb)
+		defer ms.Close()
+
+		ms.Expect("GET", "/ping").Once()
+
+		mockDo(t, ms, tdhttp.Get("/ping"))
+		status, _ := mockDo(t, ms, tdhttp.Get("/ping"))
+		td.Cmp(t, status, http.StatusNotImplemented)
+
+		td.CmpFalse(t, ms.Verify())
+		td.Cmp(t, tb.Messages, []string{
+			"unexpected request GET /ping, closest expectation GET /ping already called 1 times, but expected exactly 1",
+		})
+	})
+
+	t.Run("InOrder", func(t *testing.T) {
+		tb := test.NewTestingTB(t.Name())
+		ms := tdhttp.NewMockServer(tb).InOrder()
+		defer ms.Close()
+
+		ms.Expect("POST", "/person")
+		ms.Expect("GET", "/person/42").AtMost(3)
+		ms.Expect("DELETE", "/person/42")
+
+		status, _ := mockDo(t, ms, tdhttp.Get("/person/42"))
+		td.Cmp(t, status, http.StatusNotImplemented)
+
+		for _, req := range []*http.Request{
+			tdhttp.Post("/person", nil),
+			tdhttp.Get("/person/42"),
+			tdhttp.Delete("/person/42", nil),
+		} {
+			status, _ = mockDo(t, ms, req)
+			td.Cmp(t, status, http.StatusOK)
+		}
+
+		// GET cannot be matched anymore as DELETE has been matched
+		status, _ = mockDo(t, ms, tdhttp.Get("/person/42"))
+		td.Cmp(t, status, http.StatusNotImplemented)
+
+		td.CmpFalse(t, ms.Verify())
+		td.Cmp(t, tb.Messages, []string{
+			"unexpected request GET /person/42, expectation GET /person/42 matches but is out of order",
+			"unexpected request GET /person/42, expectation GET /person/42 matches but is out of order",
+		})
+	})
+
+	t.Run("As handler", func(t *testing.T) {
+		tb := test.NewTestingTB(t.Name())
+		ms := tdhttp.NewMockServer(tb)
+		defer ms.Close()
+
+		ms.Expect("GET", "/ping").Respond(http.StatusOK, []byte("pong"))
+
+		tdhttp.NewTestAPI(t, ms).
+			Get("/ping").
+			CmpStatus(http.StatusOK).
+			CmpBody("pong")
+
+		td.CmpTrue(t, ms.Verify())
+	})
+
+	t.Run("Configured while serving", func(t *testing.T) {
+		tb := test.NewTestingTB(t.Name())
+		ms := tdhttp.NewMockServer(tb)
+		defer ms.Close()
+
+		e := ms.Expect("GET", "/ping").AnyTimes()
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := 0; i < 10; i++ {
+				ms.ServeHTTP(httptest.NewRecorder(), tdhttp.Get("/ping"))
+			}
+		}()
+		for i := 0; i < 10; i++ {
+			e.Respond(http.StatusOK, "pong").AtLeast(1)
+		}
+		<-done
+
+		td.CmpTrue(t, ms.Verify())
+		td.CmpEmpty(t, tb.Messages)
+	})
+
+	t.Run("Bad usage", func(t *testing.T) {
+		tb := test.NewTestingTB(t.Name())
+		ms := tdhttp.NewMockServer(tb)
+		defer ms.Close()
+
+		td.CmpPanic(t,
+			func() { ms.Expect("GET", "/").JSONBody(42, 1) },
+			td.Contains("JSONBody(): params are only allowed when expected is a string or a []byte, not a int"))
+	})
+}
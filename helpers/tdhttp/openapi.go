@@ -0,0 +1,524 @@
+// Copyright (c) 2021, Maxime Soulé
+// All rights reserved.
+//
+// This source code is licensed under the BSD-style license found in the
+// LICENSE file in the root directory of this source tree.
+
+package tdhttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/maxatome/go-testdeep/internal/jsonschema"
+	"github.com/maxatome/go-testdeep/internal/yaml"
+)
+
+// OpenAPI is an OpenAPI 3 specification, used by TestAPI to validate
+// requests and responses. See LoadOpenAPI and ParseOpenAPI to create
+// one, and TestAPI.OpenAPI to use it.
+type OpenAPI struct {
+	doc      map[string]interface{}
+	schema   *jsonschema.Schema
+	basePath string
+	paths    []*openAPIPath
+}
+
+type openAPIPath struct {
+	template string
+	re       *regexp.Regexp
+	params   []string
+	literal  int // number of literal characters in template
+	item     map[string]interface{}
+}
+
+type openAPIOperation struct {
+	pointer    string // JSON pointer of the operation in the spec
+	op         map[string]interface{}
+	path       *openAPIPath
+	pathParams map[string]string
+}
+
+// openAPIViolation is a violation of the spec.
+type openAPIViolation struct {
+	got     string // path in the request or the response
+	spec    string // JSON pointer in the spec, prefixed by "#"
+	message string
+}
+
+var openAPIParamRe = regexp.MustCompile(`\{([^{}/]+)\}`)
+
+// LoadOpenAPI loads the OpenAPI 3 spec contained in JSON or YAML file
+// "path". See ParseOpenAPI for details.
+func LoadOpenAPI(path string) (*OpenAPI, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseOpenAPI(b)
+}
+
+// ParseOpenAPI parses the OpenAPI 3 spec "spec", in JSON or YAML
+// format.
+//
+// As go-testdeep does not depend on any YAML library, YAML specs are
+// parsed by an internal minimal parser handling block and flow
+// collections, plain, quoted and block scalars, and comments.
+// Anchors, aliases, tags, complex keys and multiple documents are
+// rejected with an error. If your spec uses these features, convert
+// it to JSON first.
+//
+// Schemas are validated using a subset of JSON Schema: only local
+// $ref (as "#/components/schemas/Person") are supported, and
+// formats unknown to td.JSONSchema operator (as double) are ignored.
+// Using an unsupported keyword in a schema is reported as a contract
+// violation.
+func ParseOpenAPI(spec []byte) (*OpenAPI, error) {
+	var (
+		doc interface{}
+		err error
+	)
+	if trimmed := bytes.TrimSpace(spec); len(trimmed) > 0 && trimmed[0] == '{' {
+		err = json.Unmarshal(trimmed, &doc)
+	} else {
+		doc, err = yaml.Unmarshal(spec)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse OpenAPI spec: %s", err)
+	}
+
+	m, ok := doc.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("OpenAPI spec is not an object")
+	}
+	if version, _ := m["openapi"].(string); !strings.HasPrefix(version, "3.") {
+		return nil, fmt.Errorf("only OpenAPI 3 specs are supported, not version %q", m["openapi"])
+	}
+
+	oa := OpenAPI{
+		doc:    m,
+		schema: jsonschema.New(m),
+	}
+
+	if servers, ok := m["servers"].([]interface{}); ok && len(servers) > 0 {
+		if server, ok := servers[0].(map[string]interface{}); ok {
+			if u, err := url.Parse(fmt.Sprint(server["url"])); err == nil {
+				oa.basePath = strings.TrimRight(u.Path, "/")
+			}
+		}
+	}
+
+	paths, _ := m["paths"].(map[string]interface{})
+	for template, item := range paths {
+		item, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		p := openAPIPath{template: template, item: item}
+		var re bytes.Buffer
+		re.WriteByte('^')
+		last := 0
+		for _, loc := range openAPIParamRe.FindAllStringSubmatchIndex(template, -1) {
+			re.WriteString(regexp.QuoteMeta(template[last:loc[0]]))
+			re.WriteString(`([^/]+)`)
+			p.params = append(p.params, template[loc[2]:loc[3]])
+			p.literal += loc[0] - last
+			last = loc[1]
+		}
+		re.WriteString(regexp.QuoteMeta(template[last:]))
+		re.WriteByte('$')
+		p.literal += len(template) - last
+
+		if p.re, err = regexp.Compile(re.String()); err != nil {
+			return nil, fmt.Errorf("bad path %q in OpenAPI spec: %s", template, err)
+		}
+		oa.paths = append(oa.paths, &p)
+	}
+
+	// Concrete paths first
+	sort.Slice(oa.paths, func(i, j int) bool {
+		pi, pj := oa.paths[i], oa.paths[j]
+		if len(pi.params) != len(pj.params) {
+			return len(pi.params) < len(pj.params)
+		}
+		if pi.literal != pj.literal {
+			return pi.literal > pj.literal
+		}
+		return pi.template < pj.template
+	})
+
+	return &oa, nil
+}
+
+var openAPIPointerEsc = strings.NewReplacer("~", "~0", "/", "~1")
+
+func openAPIPointer(parts ...string) string {
+	var buf bytes.Buffer
+	for _, part := range parts {
+		buf.WriteByte('/')
+		buf.WriteString(openAPIPointerEsc.Replace(part))
+	}
+	return buf.String()
+}
+
+// resolve follows $ref of the object located at "pointer".
+func (oa *OpenAPI) resolve(pointer string) (map[string]interface{}, string) {
+	v, pointer := oa.schema.Resolve(pointer)
+	m, _ := v.(map[string]interface{})
+	return m, pointer
+}
+
+func (oa *OpenAPI) findOperation(req *http.Request) (*openAPIOperation, *openAPIViolation) {
+	path := req.URL.Path
+	if oa.basePath != "" && strings.HasPrefix(path, oa.basePath) {
+		path = path[len(oa.basePath):]
+	}
+
+	method := strings.ToLower(req.Method)
+	var pathFound *openAPIPath
+	for _, p := range oa.paths {
+		sub := p.re.FindStringSubmatch(path)
+		if sub == nil {
+			continue
+		}
+		op, ok := p.item[method].(map[string]interface{})
+		if !ok {
+			if pathFound == nil {
+				pathFound = p
+			}
+			continue
+		}
+
+		params := make(map[string]string, len(p.params))
+		for i, name := range p.params {
+			params[name], _ = url.PathUnescape(sub[i+1])
+		}
+		return &openAPIOperation{
+			pointer:    openAPIPointer("paths", p.template, method),
+			op:         op,
+			path:       p,
+			pathParams: params,
+		}, nil
+	}
+
+	if pathFound != nil {
+		return nil, &openAPIViolation{
+			got:     "Request.Method",
+			spec:    "#" + openAPIPointer("paths", pathFound.template),
+			message: fmt.Sprintf("method %s is not declared for path %s", req.Method, pathFound.template),
+		}
+	}
+	return nil, &openAPIViolation{
+		got:     "Request.URL.Path",
+		spec:    "#/paths",
+		message: fmt.Sprintf("%s does not match any path", req.URL.Path),
+	}
+}
+
+// schemaViolations validates "value" against the schema located at
+// "pointer" and converts errors to violations, prefixing instance
+// paths with "got".
+func (oa *OpenAPI) schemaViolations(got, pointer string, value interface{}) []openAPIViolation {
+	var vs []openAPIViolation
+	for _, err := range oa.schema.ValidateAt(pointer, value) {
+		vs = append(vs, openAPIViolation{
+			got:     got + err.InstancePath,
+			spec:    err.SchemaPath,
+			message: err.Message,
+		})
+	}
+	return vs
+}
+
+// validateRequest validates "req", whose body is "body", against the
+// spec. It returns the matching operation, if any, and all the
+// violations found.
+func (oa *OpenAPI) validateRequest(req *http.Request, body []byte) (*openAPIOperation, []openAPIViolation) {
+	op, v := oa.findOperation(req)
+	if v != nil {
+		return nil, []openAPIViolation{*v}
+	}
+
+	var vs []openAPIViolation
+
+	// Parameters: operation ones override path item ones
+	type param struct {
+		m       map[string]interface{}
+		pointer string
+	}
+	params := map[string]param{}
+	var keys []string
+	for _, src := range []struct {
+		obj     map[string]interface{}
+		pointer string
+	}{
+		{obj: op.path.item, pointer: openAPIPointer("paths", op.path.template)},
+		{obj: op.op, pointer: op.pointer},
+	} {
+		list, _ := src.obj["parameters"].([]interface{})
+		for i := range list {
+			m, pointer := oa.resolve(src.pointer + "/parameters/" + strconv.Itoa(i))
+			if m == nil {
+				continue
+			}
+			key := fmt.Sprintf("%s:%s", m["in"], m["name"])
+			if _, exists := params[key]; !exists {
+				keys = append(keys, key)
+			}
+			params[key] = param{m: m, pointer: pointer}
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		p := params[key]
+		name, _ := p.m["name"].(string)
+		required, _ := p.m["required"].(bool)
+
+		var (
+			values []string
+			got    string
+		)
+		switch p.m["in"] {
+		case "path":
+			got = "Request.Path[" + name + "]"
+			if value, ok := op.pathParams[name]; ok {
+				values = []string{value}
+			}
+			required = true
+		case "query":
+			got = "Request.Query[" + name + "]"
+			values = req.URL.Query()[name]
+		case "header":
+			got = "Request.Header[" + http.CanonicalHeaderKey(name) + "]"
+			values = req.Header[http.CanonicalHeaderKey(name)]
+		case "cookie":
+			got = "Request.Cookie[" + name + "]"
+			if c, err := req.Cookie(name); err == nil {
+				values = []string{c.Value}
+			}
+		default:
+			continue
+		}
+
+		if len(values) == 0 {
+			if required {
+				vs = append(vs, openAPIViolation{
+					got:     got,
+					spec:    "#" + p.pointer + "/required",
+					message: fmt.Sprintf("missing required %s parameter %q", p.m["in"], name),
+				})
+			}
+			continue
+		}
+
+		if _, ok := p.m["schema"]; ok {
+			pointer := p.pointer + "/schema"
+			explode, ok := p.m["explode"].(bool)
+			if !ok {
+				explode = p.m["style"] == nil || p.m["style"] == "form"
+			}
+			if value, ok := oa.coerce(pointer, values, explode); ok {
+				vs = append(vs, oa.schemaViolations(got, pointer, value)...)
+			}
+		}
+	}
+
+	// Request body
+	if _, ok := op.op["requestBody"]; ok {
+		rb, pointer := oa.resolve(op.pointer + "/requestBody")
+		if len(body) == 0 {
+			if required, _ := rb["required"].(bool); required {
+				vs = append(vs, openAPIViolation{
+					got:     "Request.Body",
+					spec:    "#" + pointer + "/required",
+					message: "missing required request body",
+				})
+			}
+		} else {
+			vs = append(vs, oa.validateContent("Request.Body", rb, pointer,
+				req.Header.Get("Content-Type"), body)...)
+		}
+	}
+
+	return op, vs
+}
+
+// validateResponse validates the response recorded by "resp" to the
+// request "req", that matched the operation "op".
+func (oa *OpenAPI) validateResponse(op *openAPIOperation, req *http.Request, resp *httptest.ResponseRecorder) []openAPIViolation {
+	responses, _ := op.op["responses"].(map[string]interface{})
+
+	status := strconv.Itoa(resp.Code)
+	key := ""
+	for _, k := range []string{status, status[:1] + "XX", status[:1] + "xx", "default"} {
+		if _, ok := responses[k]; ok {
+			key = k
+			break
+		}
+	}
+	if key == "" {
+		return []openAPIViolation{{
+			got:     "Response.Status",
+			spec:    "#" + op.pointer + "/responses",
+			message: fmt.Sprintf("status %d is not declared", resp.Code),
+		}}
+	}
+
+	var vs []openAPIViolation
+	r, pointer := oa.resolve(op.pointer + "/responses/" + openAPIPointerEsc.Replace(key))
+
+	// Headers
+	headers, _ := r["headers"].(map[string]interface{})
+	for _, name := range sortedStringKeys(headers) {
+		if strings.EqualFold(name, "Content-Type") {
+			continue
+		}
+		h, hPointer := oa.resolve(pointer + openAPIPointer("headers", name))
+		got := "Response.Header[" + http.CanonicalHeaderKey(name) + "]"
+		values := resp.Header()[http.CanonicalHeaderKey(name)]
+		if len(values) == 0 {
+			if required, _ := h["required"].(bool); required {
+				vs = append(vs, openAPIViolation{
+					got:     got,
+					spec:    "#" + hPointer + "/required",
+					message: fmt.Sprintf("missing required header %q", name),
+				})
+			}
+			continue
+		}
+		if _, ok := h["schema"]; ok {
+			if value, ok := oa.coerce(hPointer+"/schema", values, false); ok {
+				vs = append(vs, oa.schemaViolations(got, hPointer+"/schema", value)...)
+			}
+		}
+	}
+
+	// Body
+	if content, ok := r["content"].(map[string]interface{}); ok && len(content) > 0 {
+		if resp.Body.Len() == 0 {
+			if req.Method != http.MethodHead && resp.Code != http.StatusNotModified {
+				vs = append(vs, openAPIViolation{
+					got:     "Response.Body",
+					spec:    "#" + pointer + "/content",
+					message: "body is empty but content is declared",
+				})
+			}
+		} else {
+			body, err := decodeContentEncoding(resp.Body.Bytes(), resp.Header().Get("Content-Encoding"))
+			if err != nil {
+				vs = append(vs, openAPIViolation{
+					got:     "Response.Body",
+					spec:    "#" + pointer + "/content",
+					message: err.Error(),
+				})
+			} else {
+				vs = append(vs, oa.validateContent("Response.Body", r, pointer,
+					resp.Header().Get("Content-Type"), body)...)
+			}
+		}
+	}
+
+	return vs
+}
+
+// validateContent validates "body" against the "content" field of
+// "obj" (a request body or a response object) located at "pointer".
+// Only JSON bodies are validated against their schema.
+func (oa *OpenAPI) validateContent(got string, obj map[string]interface{}, pointer, contentType string, body []byte) []openAPIViolation {
+	content, _ := obj["content"].(map[string]interface{})
+	if len(content) == 0 {
+		return nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	key := ""
+	if _, ok := content[mediaType]; ok {
+		key = mediaType
+	} else if pos := strings.IndexByte(mediaType, '/'); pos > 0 {
+		if _, ok := content[mediaType[:pos]+"/*"]; ok {
+			key = mediaType[:pos] + "/*"
+		}
+	}
+	if key == "" {
+		if _, ok := content["*/*"]; ok {
+			key = "*/*"
+		}
+	}
+	if key == "" {
+		return []openAPIViolation{{
+			got:  got,
+			spec: "#" + pointer + "/content",
+			message: fmt.Sprintf("Content-Type %q is not declared, expected one of: %s",
+				contentType, strings.Join(sortedStringKeys(content), ", ")),
+		}}
+	}
+
+	mt, mtPointer := oa.resolve(pointer + openAPIPointer("content", key))
+	if _, ok := mt["schema"]; !ok ||
+		(mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json")) {
+		return nil
+	}
+
+	var value interface{}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&value); err != nil {
+		return []openAPIViolation{{
+			got:     got,
+			spec:    "#" + mtPointer,
+			message: "body is not valid JSON: " + err.Error(),
+		}}
+	}
+	return oa.schemaViolations(got, mtPointer+"/schema", value)
+}
+
+// coerce converts the string "values" of a parameter or a header,
+// according to the schema located at "pointer". It returns false if
+// the value cannot be validated.
+func (oa *OpenAPI) coerce(pointer string, values []string, explode bool) (interface{}, bool) {
+	schema, pointer := oa.resolve(pointer)
+	switch schema["type"] {
+	case "array":
+		if !explode && len(values) == 1 {
+			values = strings.Split(values[0], ",")
+		}
+		items := make([]interface{}, len(values))
+		for i, value := range values {
+			items[i], _ = oa.coerce(pointer+"/items", []string{value}, explode)
+		}
+		return items, true
+	case "object":
+		return nil, false
+	case "integer", "number":
+		if f, err := strconv.ParseFloat(values[0], 64); err == nil {
+			return f, true
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(values[0]); err == nil {
+			return b, true
+		}
+	}
+	return values[0], true
+}
+
+func sortedStringKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
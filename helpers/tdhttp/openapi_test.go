@@ -0,0 +1,277 @@
+// Copyright (c) 2021, Maxime Soulé
+// All rights reserved.
+//
+// This source code is licensed under the BSD-style license found in the
+// LICENSE file in the root directory of this source tree.
+
+package tdhttp_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/maxatome/go-testdeep/helpers/tdhttp"
+	"github.com/maxatome/go-testdeep/td"
+)
+
+const petsSpec = `
+openapi: 3.0.3
+info:
+  title: Pets
+  version: "1.0"
+servers:
+  - url: https://api.example.com/v1
+paths:
+  /pets:
+    get:
+      parameters:
+        - name: limit
+          in: query
+          schema:
+            type: integer
+            minimum: 1
+            maximum: 100
+      responses:
+        "200":
+          description: pets list
+          headers:
+            X-Total:
+              required: true
+              schema:
+                type: integer
+          content:
+            application/json:
+              schema:
+                type: array
+                items:
+                  $ref: "#/components/schemas/Pet"
+    post:
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              $ref: "#/components/schemas/Pet"
+      responses:
+        "201":
+          description: created
+          content:
+            application/json:
+              schema:
+                $ref: "#/components/schemas/Pet"
+        4XX:
+          description: error
+  /pets/{id}:
+    parameters:
+      - name: id
+        in: path
+        required: true
+        schema:
+          type: integer
+          format: int64
+    get:
+      responses:
+        "200":
+          description: a pet
+          content:
+            application/json:
+              schema:
+                $ref: "#/components/schemas/Pet"
+components:
+  schemas:
+    Pet:
+      description: |
+        A pet, as stored
+        in the shop.
+      type: object
+      required: [name]
+      properties:
+        id:
+          type: integer
+          minimum: 1
+        name:
+          type: string
+          minLength: 1
+        tag:
+          type: string
+          enum: [cat, dog]
+          nullable: true
+        born:
+          type: string
+          format: date-time
+        weight:
+          type: number
+          format: double
+`
+
+func TestOpenAPI(t *testing.T) {
+	spec, err := tdhttp.ParseOpenAPI([]byte(petsSpec))
+	td.Require(t).CmpNoError(err)
+
+	var (
+		petID     = 1
+		total     = "2"
+		brokenPet = false
+	)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/pets", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if req.Method == http.MethodPost {
+			var pet map[string]interface{}
+			if json.NewDecoder(req.Body).Decode(&pet) != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			pet["id"] = petID
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(pet) //nolint: errcheck
+			return
+		}
+		w.Header().Set("X-Total", total)
+		io := `[{"id":1,"name":"Rex","tag":null},{"id":2,"name":"Tom","born":"2020-01-02T03:04:05Z","weight":4.2}]`
+		if brokenPet {
+			io = `[{"id":1,"name":"Rex"},{"id":0,"name":""}]`
+		}
+		w.Write([]byte(io)) //nolint: errcheck
+	})
+	mux.HandleFunc("/v1/pets/", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("Rex")) //nolint: errcheck
+	})
+
+	t.Run("OK", func(t *testing.T) {
+		tb, ta := newTestAPI(t, mux)
+		ta.OpenAPI(spec)
+
+		ta.Get("/v1/pets?limit=10").
+			CmpStatus(http.StatusOK)
+		ta.PostJSON("/v1/pets", map[string]interface{}{"name": "Rex"}).
+			CmpStatus(http.StatusCreated)
+
+		td.CmpFalse(t, ta.Failed())
+		td.CmpEmpty(t, tb.Messages)
+	})
+
+	t.Run("Request violations", func(t *testing.T) {
+		tb, ta := newTestAPI(t, mux)
+		ta.OpenAPI(spec)
+
+		td.CmpTrue(t, ta.Get("/v1/pets?limit=1000").Failed())
+		td.Cmp(t, tb.Messages, []string{
+			"Failed test 'request should conform to OpenAPI spec'\n" +
+				"Request.Query[limit]: does not conform to OpenAPI spec\n" +
+				"\t1000 is greater than maximum 100\n" +
+				"\tspec: #/paths/~1pets/get/parameters/0/schema/maximum",
+		})
+
+		tb.ResetMessages()
+		td.CmpTrue(t, ta.Get("/v1/pets?limit=abc").Failed())
+		td.Cmp(t, tb.LastMessage(), td.Contains(`"abc" is not of type integer`))
+
+		tb.ResetMessages()
+		td.CmpTrue(t, ta.Post("/v1/pets", nil).Failed())
+		td.Cmp(t, tb.Messages[0], td.All(
+			td.Contains("Request.Body: does not conform to OpenAPI spec"),
+			td.Contains("missing required request body"),
+			td.Contains("spec: #/paths/~1pets/post/requestBody/required"),
+		))
+
+		tb.ResetMessages()
+		td.CmpTrue(t, ta.PostJSON("/v1/pets", map[string]interface{}{"name": 12}).Failed())
+		td.Cmp(t, tb.Messages, td.Contains(td.All(
+			td.Contains("Request.Body/name: does not conform to OpenAPI spec"),
+			td.Contains("12 is not of type string"),
+			td.Contains("spec: #/components/schemas/Pet/properties/name/type"),
+		)))
+
+		tb.ResetMessages()
+		td.CmpTrue(t, ta.Post("/v1/pets", strings.NewReader("{"), "Content-Type", "application/json").
+			CmpStatus(http.StatusBadRequest).
+			Failed())
+		td.Cmp(t, tb.Messages, []string{
+			"Failed test 'request should conform to OpenAPI spec'\n" +
+				"Request.Body: does not conform to OpenAPI spec\n" +
+				"\tbody is not valid JSON: unexpected EOF\n" +
+				"\tspec: #/paths/~1pets/post/requestBody/content/application~1json",
+		})
+
+		tb.ResetMessages()
+		td.CmpTrue(t, ta.Get("/v1/pets/abc").Failed())
+		td.Cmp(t, tb.Messages[0], td.All(
+			td.Contains("Request.Path[id]: does not conform to OpenAPI spec"),
+			td.Contains(`"abc" is not of type integer`),
+		))
+
+		tb.ResetMessages()
+		td.CmpTrue(t, ta.Delete("/v1/pets/12", nil).Failed())
+		td.Cmp(t, tb.Messages, []string{
+			"Failed test 'request should conform to OpenAPI spec'\n" +
+				"Request.Method: does not conform to OpenAPI spec\n" +
+				"\tmethod DELETE is not declared for path /pets/{id}\n" +
+				"\tspec: #/paths/~1pets~1{id}",
+		})
+
+		tb.ResetMessages()
+		td.CmpTrue(t, ta.Get("/v1/dogs").Failed())
+		td.Cmp(t, tb.LastMessage(), td.Contains("/v1/dogs does not match any path"))
+	})
+
+	t.Run("Response violations", func(t *testing.T) {
+		tb, ta := newTestAPI(t, mux)
+		ta.OpenAPI(spec)
+
+		brokenPet, total = true, "many"
+		defer func() { brokenPet, total = false, "2" }()
+
+		td.CmpTrue(t, ta.Get("/v1/pets").CmpStatus(http.StatusOK).Failed())
+		td.Cmp(t, tb.Messages, []string{
+			"Failed test 'response should conform to OpenAPI spec'\n" +
+				"Response.Header[X-Total]: does not conform to OpenAPI spec\n" +
+				"\t\"many\" is not of type integer\n" +
+				"\tspec: #/paths/~1pets/get/responses/200/headers/X-Total/schema/type",
+			"Failed test 'response should conform to OpenAPI spec'\n" +
+				"Response.Body/1/id: does not conform to OpenAPI spec\n" +
+				"\t0 is less than minimum 1\n" +
+				"\tspec: #/components/schemas/Pet/properties/id/minimum",
+			"Failed test 'response should conform to OpenAPI spec'\n" +
+				"Response.Body/1/name: does not conform to OpenAPI spec\n" +
+				"\t\"\" is shorter than 1 characters\n" +
+				"\tspec: #/components/schemas/Pet/properties/name/minLength",
+		})
+
+		tb.ResetMessages()
+		td.CmpTrue(t, ta.Get("/v1/pets/12").Failed())
+		td.Cmp(t, tb.Messages, []string{
+			"Failed test 'response should conform to OpenAPI spec'\n" +
+				"Response.Body: does not conform to OpenAPI spec\n" +
+				"\tContent-Type \"text/plain\" is not declared, expected one of: application/json\n" +
+				"\tspec: #/paths/~1pets~1{id}/get/responses/200/content",
+		})
+	})
+
+	t.Run("Load", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "tdhttp-openapi")
+		td.Require(t).CmpNoError(err)
+		defer os.RemoveAll(dir)
+
+		path := filepath.Join(dir, "openapi.json")
+		td.Require(t).CmpNoError(ioutil.WriteFile(path,
+			[]byte(`{"openapi": "3.1.0", "paths": {}}`), 0644))
+		_, err = tdhttp.LoadOpenAPI(path)
+		td.CmpNoError(t, err)
+
+		_, err = tdhttp.LoadOpenAPI(filepath.Join(dir, "unknown.yaml"))
+		td.CmpError(t, err)
+
+		_, err = tdhttp.ParseOpenAPI([]byte(`swagger: "2.0"`))
+		td.CmpString(t, err, `only OpenAPI 3 specs are supported, not version %!q(<nil>)`)
+
+		_, err = tdhttp.ParseOpenAPI([]byte("a: &anchor 1"))
+		td.CmpString(t, err, "cannot parse OpenAPI spec: yaml: line 1: anchors, aliases and tags are not supported")
+	})
+}
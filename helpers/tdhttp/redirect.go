@@ -0,0 +1,243 @@
+// Copyright (c) 2021, Maxime Soulé
+// All rights reserved.
+//
+// This source code is licensed under the BSD-style license found in the
+// LICENSE file in the root directory of this source tree.
+
+package tdhttp
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/maxatome/go-testdeep/internal/ctxerr"
+)
+
+// FollowRedirects enables the following of redirections for all the
+// following requests, up to "maxHops" redirections per request. A
+// zero "maxHops" disables it, which is the default.
+//
+//   ta := tdhttp.NewTestAPI(t, mux).FollowRedirects(5)
+//
+//   ta.PostForm("/login", url.Values{"user": {"bob"}, "pass": {"xxx"}}).
+//     CmpRedirect(http.StatusSeeOther, "/home").
+//     CmpStatus(http.StatusOK).
+//     CmpBody(td.Contains("Welcome Bob!"))
+//
+// A response is considered as a redirection if its status is 301,
+// 302, 303, 307 or 308 and it contains a Location header. As
+// net/http client does, the redirected request uses GET method
+// without body for 301, 302 and 303 statuses (except for HEAD
+// requests), while 307 and 308 ones keep the method and the body of
+// the original request. The headers of the request are kept, and
+// the cookies set by each redirection response are added to the
+// next request. Whatever the host targeted by the Location header,
+// the next request is always sent to the tested handler.
+//
+// Once the chain of redirections followed, Cmp* methods test the
+// last response received. CmpRedirect allows to test the first one
+// and Redirects to get all redirection responses.
+//
+// If more than "maxHops" redirections occur, the test fails and the
+// last received redirection response is kept.
+//
+// Instances derived using With or Run methods inherit this setting.
+func (t *TestAPI) FollowRedirects(maxHops int) *TestAPI {
+	t.maxRedirects = maxHops
+	return t
+}
+
+// Redirects returns the redirection responses followed during the
+// last request (see FollowRedirects), in order. The final response is
+// not included. The Request field of each response is the request
+// that produced it. It returns nil if no redirection has been
+// followed.
+func (t *TestAPI) Redirects() []*http.Response {
+	return t.redirects
+}
+
+// CmpRedirect tests that the first response received after the last
+// request sent is a redirection: its status code is compared to
+// "expectedStatus" and its Location header to "expectedLocation".
+// If redirections are followed (see FollowRedirects), the first
+// response is the first redirection of the chain, otherwise it is
+// the unique response received.
+//
+//   ta.Get("/old/path").
+//     CmpRedirect(http.StatusMovedPermanently, "/new/path")
+//
+//   ta.Post("/items", body).
+//     CmpRedirect(td.Between(301, 303), td.Re(`^/items/\d+\z`))
+//
+// Before the comparison, the Location header is resolved against the
+// URL of the request, and, if it targets the same scheme and host
+// as the request, it is then reduced to its path, query and
+// fragment. So if the request is http://example.com/a/b and the
+// Location is c, ../c or http://example.com/a/c, it always becomes
+// /a/c. When "expectedLocation" is a string, it is normalized the
+// same way, so it can be either relative or absolute. Otherwise it
+// is compared as is to the normalized Location.
+//
+// It fails if no request has been sent yet.
+func (t *TestAPI) CmpRedirect(expectedStatus, expectedLocation interface{}) *TestAPI {
+	defer t.t.AnchorsPersistTemporarily()()
+
+	t.t.Helper()
+
+	if !t.checkRequestSent() {
+		t.redirectFailed = true
+		return t
+	}
+
+	var (
+		req    *http.Request
+		status int
+		header http.Header
+	)
+	if len(t.redirects) > 0 {
+		resp := t.redirects[0]
+		req, status, header = resp.Request, resp.StatusCode, resp.Header
+	} else {
+		req, status, header = t.request, t.response.Code, t.response.Header()
+	}
+
+	t.redirectFailed = !t.t.RootName("Response.Status").
+		CmpLax(status, expectedStatus, t.name+"redirect status code should match")
+
+	if expected, ok := expectedLocation.(string); ok {
+		expectedLocation = normalizeLocation(req, expected)
+	}
+	if !t.t.RootName(`Response.Header["Location"]`).
+		Cmp(normalizeLocation(req, header.Get("Location")), expectedLocation,
+			t.name+"redirect location should match") {
+		t.redirectFailed = true
+	}
+
+	if t.redirectFailed && t.autoDumpResponse {
+		t.dumpResponse()
+	}
+
+	return t
+}
+
+// redirectRequest returns the request to send to follow the
+// redirection contained in the last response, "req" being the
+// request that produced it and "reqBody" its body. It returns nil if
+// the last response is not a redirection.
+func (t *TestAPI) redirectRequest(req *http.Request, reqBody []byte) *http.Request {
+	status := t.response.Code
+	switch status {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+	default:
+		return nil
+	}
+
+	location := t.response.Header().Get("Location")
+	if location == "" {
+		return nil
+	}
+	target, err := req.URL.Parse(location)
+	if err != nil {
+		return nil
+	}
+
+	method, keepBody := req.Method, true
+	if status != http.StatusTemporaryRedirect && status != http.StatusPermanentRedirect {
+		keepBody = false
+		if method != http.MethodHead {
+			method = http.MethodGet
+		}
+	}
+
+	var next *http.Request
+	if keepBody && reqBody != nil {
+		next = httptest.NewRequest(method, target.String(), bytes.NewReader(reqBody))
+	} else {
+		next = httptest.NewRequest(method, target.String(), nil)
+	}
+	if target.Host == "" {
+		next.Host = req.Host
+	}
+	next = next.WithContext(req.Context())
+
+	next.Header = make(http.Header, len(req.Header))
+	for key, values := range req.Header {
+		if !keepBody && strings.HasPrefix(key, "Content-") {
+			continue
+		}
+		next.Header[key] = append([]string(nil), values...)
+	}
+
+	// Add the cookies set by the redirection response
+	if cookies := t.response.Result().Cookies(); len(cookies) > 0 {
+		set := make(map[string]bool, len(cookies))
+		for _, cookie := range cookies {
+			set[cookie.Name] = true
+		}
+		oldCookies := next.Cookies()
+		next.Header.Del("Cookie")
+		for _, cookie := range oldCookies {
+			if !set[cookie.Name] {
+				next.AddCookie(cookie)
+			}
+		}
+		for _, cookie := range cookies {
+			if cookie.MaxAge >= 0 {
+				next.AddCookie(&http.Cookie{Name: cookie.Name, Value: cookie.Value})
+			}
+		}
+	}
+
+	return next
+}
+
+func (t *TestAPI) reportTooManyRedirects() {
+	t.t.Helper()
+
+	t.redirectFailed = true
+	t.t.RootName("Response").Code(len(t.redirects),
+		func(hops int) error {
+			return &ctxerr.Error{
+				Message: "%% is a redirection, but too many redirections occurred",
+				Summary: ctxerr.NewSummary(
+					fmt.Sprintf("stopped after %d redirections, see FollowRedirects method", hops)),
+			}
+		},
+		t.name+"redirections should be followed")
+
+	if t.autoDumpResponse {
+		t.dumpResponse()
+	}
+}
+
+// normalizeLocation resolves "location" against the URL of
+// "req". The result is reduced to its path, query and fragment if it
+// targets the same scheme and host as "req".
+func normalizeLocation(req *http.Request, location string) string {
+	if location == "" || req == nil {
+		return location
+	}
+	u, err := req.URL.Parse(location)
+	if err != nil {
+		return location
+	}
+
+	if u.Host != "" {
+		scheme := "http"
+		if req.TLS != nil {
+			scheme = "https"
+		}
+		host := req.URL.Host
+		if host == "" {
+			host = req.Host
+		}
+		if strings.EqualFold(u.Host, host) && strings.EqualFold(u.Scheme, scheme) {
+			u.Scheme, u.Host, u.User = "", "", nil
+		}
+	}
+	return u.String()
+}
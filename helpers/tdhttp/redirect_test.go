@@ -0,0 +1,158 @@
+// Copyright (c) 2021, Maxime Soulé
+// All rights reserved.
+//
+// This source code is licensed under the BSD-style license found in the
+// LICENSE file in the root directory of this source tree.
+
+package tdhttp_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/maxatome/go-testdeep/helpers/tdhttp"
+	"github.com/maxatome/go-testdeep/td"
+)
+
+func redirectServer() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", func(w http.ResponseWriter, req *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: req.FormValue("user")})
+		http.Redirect(w, req, "home", http.StatusSeeOther)
+	})
+	mux.HandleFunc("/home", func(w http.ResponseWriter, req *http.Request) {
+		session, err := req.Cookie("session")
+		if err != nil {
+			http.Error(w, "no session", http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprintf(w, "%s Welcome %s!", req.Method, session.Value)
+	})
+	mux.HandleFunc("/old/", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Location", "http://example.com/new/"+strings.TrimPrefix(req.URL.Path, "/old/"))
+		w.WriteHeader(http.StatusPermanentRedirect)
+	})
+	mux.HandleFunc("/new/", func(w http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		fmt.Fprintf(w, "%s %s %s", req.Method, req.URL.Path, body)
+	})
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, req *http.Request) {
+		http.Redirect(w, req, "/loop", http.StatusFound)
+	})
+	mux.HandleFunc("/elsewhere", func(w http.ResponseWriter, req *http.Request) {
+		http.Redirect(w, req, "https://other.example.com/x?a=1", http.StatusFound)
+	})
+	return mux
+}
+
+func TestRedirect(t *testing.T) {
+	mux := redirectServer()
+
+	t.Run("Not followed", func(t *testing.T) {
+		tb, ta := newTestAPI(t, mux)
+
+		ta.PostForm("/login", url.Values{"user": {"bob"}}).
+			CmpRedirect(http.StatusSeeOther, "/home").
+			CmpRedirect(http.StatusSeeOther, "home").
+			CmpRedirect(td.Between(301, 303), "http://example.com/home").
+			CmpRedirect(303, td.HasPrefix("/h")).
+			CmpStatus(http.StatusSeeOther)
+		td.CmpNil(t, ta.Redirects())
+
+		ta.Get("/old/foo").
+			CmpRedirect(http.StatusPermanentRedirect, "/new/foo")
+
+		ta.Get("/elsewhere").
+			CmpRedirect(http.StatusFound, "https://other.example.com/x?a=1")
+
+		td.CmpFalse(t, ta.Failed())
+		td.CmpEmpty(t, tb.Messages)
+	})
+
+	t.Run("Followed", func(t *testing.T) {
+		tb, ta := newTestAPI(t, mux)
+		ta.FollowRedirects(3)
+
+		ta.PostForm("/login", url.Values{"user": {"bob"}}).
+			CmpRedirect(http.StatusSeeOther, "/home").
+			CmpStatus(http.StatusOK).
+			CmpBody("GET Welcome bob!")
+		if td.CmpLen(t, ta.Redirects(), 1) {
+			td.Cmp(t, ta.Redirects()[0].StatusCode, http.StatusSeeOther)
+			td.Cmp(t, ta.Redirects()[0].Request.URL.Path, "/login")
+		}
+
+		// 308 keeps method and body
+		ta.Post("/old/foo", strings.NewReader("body!")).
+			CmpRedirect(http.StatusPermanentRedirect, "/new/foo").
+			CmpStatus(http.StatusOK).
+			CmpBody("POST /new/foo body!")
+
+		// Inherited by sub-tests
+		ta.Run("sub", func(ta *tdhttp.TestAPI) {
+			ta.Get("/old/bar").CmpBody("GET /new/bar ")
+		})
+
+		// No redirection
+		ta.Get("/home").CmpStatus(http.StatusUnauthorized)
+		td.CmpNil(t, ta.Redirects())
+
+		td.CmpFalse(t, ta.Failed())
+		td.Cmp(t, tb.Messages, []string{"++++ sub"})
+
+		// Disabled
+		ta.FollowRedirects(0).Get("/old/foo").CmpStatus(http.StatusPermanentRedirect)
+		td.CmpNil(t, ta.Redirects())
+	})
+
+	t.Run("Too many redirections", func(t *testing.T) {
+		tb, ta := newTestAPI(t, mux)
+		ta.FollowRedirects(3)
+
+		td.CmpTrue(t, ta.Get("/loop").Failed())
+		td.Cmp(t, tb.LastMessage(), td.All(
+			td.Contains("Failed test 'redirections should be followed'"),
+			td.Contains("Response is a redirection, but too many redirections occurred"),
+			td.Contains("stopped after 3 redirections"),
+		))
+		td.CmpLen(t, ta.Redirects(), 3)
+
+		tb.ResetMessages()
+		ta.CmpStatus(http.StatusFound)
+		td.CmpEmpty(t, tb.Messages)
+	})
+
+	t.Run("Failures", func(t *testing.T) {
+		tb, ta := newTestAPI(t, mux)
+
+		td.CmpTrue(t, ta.CmpRedirect(http.StatusFound, "/").Failed())
+		td.Cmp(t, tb.LastMessage(), td.Contains("Request not sent!"))
+
+		tb.ResetMessages()
+		td.CmpTrue(t, ta.Get("/home").CmpRedirect(http.StatusFound, "/login").Failed())
+		if td.CmpLen(t, tb.Messages, 2) {
+			td.Cmp(t, tb.Messages[0], td.All(
+				td.Contains("Failed test 'redirect status code should match'"),
+				td.Contains("Response.Status: values differ"),
+			))
+			td.Cmp(t, tb.Messages[1], td.All(
+				td.Contains("Failed test 'redirect location should match'"),
+				td.Contains(`Response.Header["Location"]: values differ`),
+				td.Contains(`"/login"`),
+			))
+		}
+
+		tb.ResetMessages()
+		td.CmpTrue(t, ta.Get("/elsewhere").CmpRedirect(http.StatusFound, "/x?a=1").Failed())
+		td.Cmp(t, tb.Messages, []string{
+			`Failed test 'redirect location should match'
+Response.Header["Location"]: values differ
+	     got: "https://other.example.com/x?a=1"
+	expected: "/x?a=1"`,
+		})
+	})
+}
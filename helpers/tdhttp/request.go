@@ -22,6 +22,13 @@ import (
 )
 
 func addHeaders(req *http.Request, headers []interface{}) *http.Request {
+	fillHeader(req.Header, headers)
+	return req
+}
+
+// fillHeader adds "headers" to "h". See NewRequest for all possible
+// formats accepted in headers.
+func fillHeader(h http.Header, headers []interface{}) {
 	headers = flat.Interfaces(headers...)
 
 	for i := 0; i < len(headers); i++ {
@@ -36,18 +43,17 @@ func addHeaders(req *http.Request, headers []interface{}) *http.Request {
 						cur, headers[i], i))
 				}
 			}
-			req.Header.Add(cur, val)
+			h.Add(cur, val)
 
 		case http.Header:
 			for k, v := range cur {
-				req.Header[k] = append(req.Header[k], v...)
+				h[k] = append(h[k], v...)
 			}
 
 		default:
 			panic(color.Bad("headers... can only contains string and http.Header, not %T (@ headers[%d])", cur, i))
 		}
 	}
-	return req
 }
 
 // NewRequest creates a new HTTP request as
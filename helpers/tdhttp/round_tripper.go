@@ -0,0 +1,311 @@
+// Copyright (c) 2021, Maxime Soulé
+// All rights reserved.
+//
+// This source code is licensed under the BSD-style license found in the
+// LICENSE file in the root directory of this source tree.
+
+package tdhttp
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/maxatome/go-testdeep/internal/ctxerr"
+	"github.com/maxatome/go-testdeep/internal/types"
+	"github.com/maxatome/go-testdeep/td"
+)
+
+var bytesType = reflect.TypeOf([]byte(nil))
+
+// SentRequest is a request recorded by a RoundTripper, in a form
+// easy to compare with TestDeep operators.
+type SentRequest struct {
+	Method string
+	URL    string
+	Header http.Header
+	Body   string
+}
+
+// RoundTripper is a http.RoundTripper recording all the requests
+// sent through it and replying from a scripted list of responses. It
+// allows to test HTTP client code without any server. See
+// NewRoundTripper function to create a new instance and get some
+// examples of use.
+type RoundTripper struct {
+	t *td.T
+
+	mu       sync.Mutex
+	requests []*http.Request
+	sent     []SentRequest
+	replies  []func(*http.Request) (*http.Response, error)
+}
+
+// NewRoundTripper creates a new RoundTripper using "tb" to report
+// failures.
+//
+//   rt := tdhttp.NewRoundTripper(t).
+//     ReplyJSON(http.StatusCreated, Person{ID: 42, Name: "Bob", Age: 26}).
+//     Reply(http.StatusNoContent, nil)
+//
+//   client := NewPersonClient("http://api.test", rt.Client()) // the tested code
+//   client.Create(Person{Name: "Bob", Age: 26})
+//   client.Delete(42)
+//
+//   rt.CmpRequest(0, "POST", "http://api.test/person", nil,
+//     td.JSON(`{"name": "Bob", "age": 26}`))
+//   rt.CmpRequest(1, "DELETE", "http://api.test/person/42", nil, nil)
+//
+// Note that "tb" can be a *testing.T as well as a *td.T.
+func NewRoundTripper(tb testing.TB) *RoundTripper {
+	return &RoundTripper{
+		t: td.NewT(tb),
+	}
+}
+
+// Client returns a *http.Client using the RoundTripper as transport.
+func (rt *RoundTripper) Client() *http.Client {
+	return &http.Client{Transport: rt}
+}
+
+// Reply appends a canned response to the script. "body" can be nil
+// (empty body), a string, a []byte or any other value that is then
+// JSON-encoded (in this last case, "Content-Type" header is
+// automatically set to "application/json"). See NewRequest for all
+// possible formats accepted in headers.
+func (rt *RoundTripper) Reply(status int, body interface{}, headers ...interface{}) *RoundTripper {
+	return rt.ReplyHandler(newResponder(status, body, headers))
+}
+
+// ReplyJSON appends a canned response to the script, "body" being
+// JSON-encoded. "Content-Type" header is automatically set to
+// "application/json". See NewRequest for all possible formats
+// accepted in headers.
+func (rt *RoundTripper) ReplyJSON(status int, body interface{}, headers ...interface{}) *RoundTripper {
+	return rt.ReplyHandler(newJSONResponder(status, body, headers))
+}
+
+// ReplyHandler appends to the script a response computed by
+// "handler". As a MockServer is a http.Handler, it can be used here.
+func (rt *RoundTripper) ReplyHandler(handler http.Handler) *RoundTripper {
+	return rt.reply(func(req *http.Request) (*http.Response, error) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		resp := w.Result()
+		resp.Request = req
+		return resp, nil
+	})
+}
+
+// ReplyError appends to the script a transport error, typically to
+// test how the client code handles network failures.
+func (rt *RoundTripper) ReplyError(err error) *RoundTripper {
+	return rt.reply(func(*http.Request) (*http.Response, error) {
+		return nil, err
+	})
+}
+
+func (rt *RoundTripper) reply(fn func(*http.Request) (*http.Response, error)) *RoundTripper {
+	rt.mu.Lock()
+	rt.replies = append(rt.replies, fn)
+	rt.mu.Unlock()
+	return rt
+}
+
+// RoundTrip implements http.RoundTripper interface. It records a
+// copy of "req" then replies using the next scripted response. As
+// required by http.RoundTripper, "req" is not modified, except its
+// body that is consumed and closed. If no scripted responses remain,
+// the test fails and an error is returned.
+func (rt *RoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		body, _ = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if body == nil {
+			body = []byte{}
+		}
+	}
+	req = cloneRequest(req, body)
+
+	rt.mu.Lock()
+	num := len(rt.requests)
+	rt.requests = append(rt.requests, req)
+	rt.sent = append(rt.sent, SentRequest{
+		Method: req.Method,
+		URL:    req.URL.String(),
+		Header: req.Header,
+		Body:   string(body),
+	})
+	var reply func(*http.Request) (*http.Response, error)
+	if num < len(rt.replies) {
+		reply = rt.replies[num]
+	}
+	rt.mu.Unlock()
+
+	if reply == nil {
+		rt.t.Errorf("RoundTripper: no scripted response for request #%d %s %s",
+			num, req.Method, req.URL)
+		return nil, errors.New("tdhttp.RoundTripper: no more scripted responses")
+	}
+	return reply(req)
+}
+
+// Requests returns a copy of all the requests recorded so far.
+func (rt *RoundTripper) Requests() []*http.Request {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	return append([]*http.Request(nil), rt.requests...)
+}
+
+// SentRequests returns a copy of all the requests recorded so far in
+// their SentRequest form.
+func (rt *RoundTripper) SentRequests() []SentRequest {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	return append([]SentRequest(nil), rt.sent...)
+}
+
+// CmpRequests tests all the requests recorded so far, as a
+// []SentRequest, against "expected". As the order of requests is not
+// always deterministic, td.Bag is often useful here:
+//
+//   rt.CmpRequests(td.Bag(
+//     td.Struct(tdhttp.SentRequest{Method: "GET", URL: "http://api.test/a"}, nil),
+//     td.Struct(tdhttp.SentRequest{Method: "GET", URL: "http://api.test/b"}, nil),
+//   ))
+//
+// It returns true if the test succeeds, false otherwise.
+func (rt *RoundTripper) CmpRequests(expected interface{}) bool {
+	defer rt.t.AnchorsPersistTemporarily()()
+
+	rt.t.Helper()
+	return rt.t.RootName("Requests").Cmp(rt.SentRequests(), expected, "requests should match")
+}
+
+// CmpRequest tests the "i"th recorded request (starting at 0). Each
+// of "method", "url", "header" and "body" can be a TestDeep operator
+// as well as the exact expected value, and is not tested if nil.
+//
+// "url" is compared against the string representation of the
+// request URL. "header" can be a http.Header or a TestDeep operator.
+//
+// If "body" is a string, a []byte or a TestDeep operator allowing to
+// match these types, the raw request body is compared. Otherwise the
+// request body is unmarshaled before the comparison, using
+// encoding/xml if the request "Content-Type" is XML, encoding/json if
+// it is JSON or if the expected type is known (typically a struct or
+// an operator like td.JSON), the raw body being compared in other
+// cases:
+//
+//   rt.CmpRequest(0, "POST", td.HasSuffix("/person"),
+//     td.ContainsKey("Authorization"),
+//     Person{
+//       Name: "Bob",
+//       Age:  rt.A(td.Between(20, 30), 0).(int),
+//     })
+//
+// It returns true if the test succeeds, false otherwise.
+func (rt *RoundTripper) CmpRequest(i int, method, url, header, body interface{}) bool {
+	defer rt.t.AnchorsPersistTemporarily()()
+
+	rt.t.Helper()
+
+	rt.mu.Lock()
+	num := len(rt.sent)
+	var sent SentRequest
+	if i >= 0 && i < num {
+		sent = rt.sent[i]
+	}
+	rt.mu.Unlock()
+
+	if !rt.t.RootName("Request").Code(i,
+		func(i int) error {
+			if i >= 0 && i < num {
+				return nil
+			}
+			return &ctxerr.Error{
+				Message: "%% not sent!",
+				Summary: ctxerr.NewSummary(fmt.Sprintf("only %d requests recorded", num)),
+			}
+		},
+		"request #%d is sent", i) {
+		return false
+	}
+
+	ok := true
+	if method != nil {
+		ok = rt.t.RootName("Request.Method").
+			CmpLax(sent.Method, method, "request #%d method should match", i) && ok
+	}
+	if url != nil {
+		ok = rt.t.RootName("Request.URL").
+			CmpLax(sent.URL, url, "request #%d URL should match", i) && ok
+	}
+	if header != nil {
+		ok = rt.t.RootName("Request.Header").
+			CmpLax(sent.Header, header, "request #%d header should match", i) && ok
+	}
+	if body != nil {
+		unmarshal := requestUnmarshaler(sent.Header, body)
+		got, err := unmarshalBody([]byte(sent.Body), unmarshal, body)
+		if !rt.t.RootName("unmarshal(Request.Body)").
+			CmpNoError(err, "request #%d body unmarshaling", i) {
+			return false
+		}
+		ok = rt.t.RootName("Request.Body").
+			Cmp(got, body, "request #%d body should match", i) && ok
+	}
+	return ok
+}
+
+// requestUnmarshaler returns the function to use to unmarshal the
+// body of a request whose header is "header", before comparing it to
+// "expected".
+func requestUnmarshaler(header http.Header, expected interface{}) func([]byte, interface{}) error {
+	typ := expectedBodyType(expected)
+	if typ == types.String || typ == bytesType {
+		return unmarshalRaw
+	}
+
+	mt, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	switch {
+	case strings.HasSuffix(mt, "xml"):
+		return xml.Unmarshal
+	case strings.HasSuffix(mt, "json"), typ != nil:
+		return json.Unmarshal
+	}
+	return unmarshalRaw
+}
+
+// Anchor returns a typed value allowing to anchor the TestDeep
+// operator "operator" in a go classic litteral like a struct, slice,
+// array or map value, to be used in CmpRequest or CmpRequests.
+//
+//   rt.CmpRequest(0, "POST", "http://api.test/person", nil,
+//     Person{
+//       Name: "Bob",
+//       Age:  rt.Anchor(td.Between(20, 30), 0).(int),
+//     })
+//
+// See (*td.T).Anchor documentation for details
+// https://pkg.go.dev/github.com/maxatome/go-testdeep/td#T.Anchor
+//
+// See A method for a shorter synonym of Anchor.
+func (rt *RoundTripper) Anchor(operator td.TestDeep, model ...interface{}) interface{} {
+	return rt.t.Anchor(operator, model...)
+}
+
+// A is a synonym for Anchor.
+func (rt *RoundTripper) A(operator td.TestDeep, model ...interface{}) interface{} {
+	return rt.Anchor(operator, model...)
+}
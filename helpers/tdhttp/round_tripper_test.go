@@ -0,0 +1,171 @@
+// Copyright (c) 2021, Maxime Soulé
+// All rights reserved.
+//
+// This source code is licensed under the BSD-style license found in the
+// LICENSE file in the root directory of this source tree.
+
+package tdhttp_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/maxatome/go-testdeep/helpers/tdhttp"
+	"github.com/maxatome/go-testdeep/internal/test"
+	"github.com/maxatome/go-testdeep/td"
+)
+
+// clientRequest makes "req", built by tdhttp to be served, usable by
+// a *http.Client.
+func clientRequest(req *http.Request) *http.Request {
+	req.RequestURI = ""
+	return req
+}
+
+func TestRoundTripper(t *testing.T) {
+	type Person struct {
+		ID   int64  `json:"id,omitempty" xml:"ID,omitempty"`
+		Name string `json:"name" xml:"Name"`
+		Age  int    `json:"age" xml:"Age"`
+	}
+
+	t.Run("OK", func(t *testing.T) {
+		tb := test.NewTestingTB(t.Name())
+		rt := tdhttp.NewRoundTripper(tb).
+			ReplyJSON(http.StatusCreated, Person{ID: 42, Name: "Bob", Age: 26}).
+			Reply(http.StatusOK, "pong", "Content-Type", "text/plain").
+			ReplyError(errors.New("network down"))
+
+		client := rt.Client()
+
+		resp, err := client.Do(clientRequest(tdhttp.PostJSON("http://api.test/person",
+			Person{Name: "Bob", Age: 26},
+			"X-Token", "secret")))
+		if td.CmpNoError(t, err) {
+			td.Cmp(t, resp.StatusCode, http.StatusCreated)
+			td.Cmp(t, resp.Header.Get("Content-Type"), "application/json")
+			b, _ := ioutil.ReadAll(resp.Body)
+			td.Cmp(t, json.RawMessage(b), td.JSON(`{"id":42,"name":"Bob","age":26}`))
+		}
+
+		resp, err = client.Post("http://api.test/ping", "text/plain",
+			strings.NewReader("ping"))
+		if td.CmpNoError(t, err) {
+			b, _ := ioutil.ReadAll(resp.Body)
+			td.Cmp(t, string(b), "pong")
+		}
+
+		_, err = client.Get("http://api.test/person/42")
+		td.CmpContains(t, err, "network down")
+
+		td.CmpTrue(t, rt.CmpRequest(0, "POST", "http://api.test/person",
+			td.SuperMapOf(http.Header{"X-Token": {"secret"}}, nil),
+			Person{Name: "Bob", Age: 26}))
+		td.CmpTrue(t, rt.CmpRequest(0, nil, nil, nil,
+			td.JSON(`{"name": "Bob", "age": Between(20, 30)}`)))
+		td.CmpTrue(t, rt.CmpRequest(0, nil, nil, nil,
+			Person{Name: "Bob", Age: rt.A(td.Between(20, 30), 0).(int)}))
+		td.CmpTrue(t, rt.CmpRequest(1, "POST", td.HasSuffix("/ping"), nil, "ping"))
+		td.CmpTrue(t, rt.CmpRequest(1, nil, nil, nil, td.HasPrefix("pi")))
+		td.CmpTrue(t, rt.CmpRequest(2, "GET", "http://api.test/person/42", nil, ""))
+
+		td.CmpTrue(t, rt.CmpRequests(td.Bag(
+			td.Struct(tdhttp.SentRequest{Method: "GET"}, nil),
+			td.Struct(tdhttp.SentRequest{Method: "POST", Body: "ping"}, nil),
+			td.Struct(tdhttp.SentRequest{Method: "POST"},
+				td.StructFields{"URL": td.HasSuffix("/person")}),
+		)))
+
+		td.Cmp(t, rt.Requests(), td.Len(3))
+		td.Cmp(t, rt.SentRequests(), td.Len(3))
+
+		td.CmpFalse(t, tb.HasFailed)
+		td.CmpEmpty(t, tb.Messages)
+	})
+
+	t.Run("Request not modified", func(t *testing.T) {
+		tb := test.NewTestingTB(t.Name())
+		rt := tdhttp.NewRoundTripper(tb).Reply(http.StatusOK, nil)
+
+		req := clientRequest(tdhttp.Post("http://api.test/ping",
+			strings.NewReader("ping"), "X-Token", "secret"))
+		body := req.Body
+
+		_, err := rt.RoundTrip(req)
+		td.CmpNoError(t, err)
+		td.CmpTrue(t, req.Body == body)
+
+		// The recorded request is a copy
+		if td.CmpLen(t, rt.Requests(), 1) {
+			recorded := rt.Requests()[0]
+			td.CmpTrue(t, recorded != req)
+			recorded.Header.Set("X-Token", "changed")
+			td.Cmp(t, req.Header.Get("X-Token"), "secret")
+		}
+		td.CmpTrue(t, rt.CmpRequest(0, "POST", nil, nil, "ping"))
+		td.CmpFalse(t, tb.HasFailed)
+	})
+
+	t.Run("XML", func(t *testing.T) {
+		tb := test.NewTestingTB(t.Name())
+		rt := tdhttp.NewRoundTripper(tb).Reply(http.StatusOK, nil)
+
+		_, err := rt.Client().Do(clientRequest(tdhttp.PostXML("http://api.test/person",
+			Person{Name: "Bob", Age: 26})))
+		td.CmpNoError(t, err)
+
+		td.CmpTrue(t, rt.CmpRequest(0, nil, nil, nil, Person{Name: "Bob", Age: 26}))
+		td.CmpFalse(t, tb.HasFailed)
+	})
+
+	t.Run("Handler", func(t *testing.T) {
+		tb := test.NewTestingTB(t.Name())
+
+		ms := tdhttp.NewMockServer(tb)
+		defer ms.Close()
+		ms.Expect("GET", "/ping").Respond(http.StatusOK, "pong")
+
+		rt := tdhttp.NewRoundTripper(tb).ReplyHandler(ms)
+
+		resp, err := rt.Client().Get("http://api.test/ping")
+		if td.CmpNoError(t, err) {
+			b, _ := ioutil.ReadAll(resp.Body)
+			td.Cmp(t, string(b), "pong")
+		}
+		td.CmpTrue(t, ms.Verify())
+		td.CmpFalse(t, tb.HasFailed)
+	})
+
+	t.Run("Failures", func(t *testing.T) {
+		tb := test.NewTestingTB(t.Name())
+		rt := tdhttp.NewRoundTripper(tb)
+
+		_, err := rt.Client().Get("http://api.test/ping")
+		td.CmpContains(t, err, "no more scripted responses")
+		td.Cmp(t, tb.LastMessage(),
+			"RoundTripper: no scripted response for request #0 GET http://api.test/ping")
+
+		tb.ResetMessages()
+		td.CmpFalse(t, rt.CmpRequest(1, "GET", nil, nil, nil))
+		td.Cmp(t, tb.LastMessage(), td.All(
+			td.Contains("Failed test 'request #1 is sent'"),
+			td.Contains("Request not sent!"),
+			td.Contains("only 1 requests recorded"),
+		))
+
+		tb.ResetMessages()
+		td.CmpFalse(t, rt.CmpRequest(0, "POST", nil, nil, nil))
+		td.Cmp(t, tb.LastMessage(), td.All(
+			td.Contains("Failed test 'request #0 method should match'"),
+			td.Contains("Request.Method: values differ"),
+		))
+
+		tb.ResetMessages()
+		td.CmpFalse(t, rt.CmpRequests(td.Len(2)))
+		td.Cmp(t, tb.LastMessage(), td.Contains("Failed test 'requests should match'"))
+	})
+}
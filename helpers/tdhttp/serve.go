@@ -0,0 +1,268 @@
+// Copyright (c) 2021, Maxime Soulé
+// All rights reserved.
+//
+// This source code is licensed under the BSD-style license found in the
+// LICENSE file in the root directory of this source tree.
+
+package tdhttp
+
+import (
+	"context"
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/maxatome/go-testdeep/internal/ctxerr"
+	"github.com/maxatome/go-testdeep/internal/types"
+	"github.com/maxatome/go-testdeep/internal/util"
+	"github.com/maxatome/go-testdeep/td"
+)
+
+// handlerPanic records a panic raised by the tested handler.
+type handlerPanic struct {
+	value interface{}
+	stack string
+}
+
+func (p *handlerPanic) String() string {
+	return "panic: " + util.ToString(p.value) + "\n\n" + p.stack
+}
+
+// handlerContext records how the request context ended, compared to
+// the handler return.
+type handlerContext struct {
+	done  bool          // context was done before the handler returned
+	delay time.Duration // between the context end and the handler return
+}
+
+// serveHTTP calls the tested handler with "req", recovering any
+// panic and watching the context of "req".
+func (t *TestAPI) serveHTTP(req *http.Request) {
+	t.handlerPanic = nil
+	t.panicFailed = false
+	t.panicReported = false
+	t.cancelFailed = false
+
+	req, cancel := t.requestContext(req)
+	defer cancel()
+	ctx := req.Context()
+
+	var doneAt chan time.Time
+	stop := make(chan struct{})
+	if done := ctx.Done(); done != nil {
+		doneAt = make(chan time.Time, 1)
+		go func() {
+			select {
+			case <-done:
+				doneAt <- time.Now()
+			case <-stop:
+			}
+		}()
+	}
+
+	t.sentAt = time.Now().Truncate(0)
+	t.handlerPanic = recoverPanic(func() { t.handler.ServeHTTP(t.response, req) })
+	t.panicFailed = t.handlerPanic != nil
+	if t.panicFailed {
+		t.panicRegister()
+	}
+	returnedAt := time.Now()
+
+	t.handlerContext = handlerContext{}
+	if ctx.Err() != nil {
+		end := <-doneAt
+		if deadline, ok := ctx.Deadline(); ok &&
+			ctx.Err() == context.DeadlineExceeded && deadline.Before(end) {
+			end = deadline
+		}
+		t.handlerContext.done = true
+		if end.Before(returnedAt) {
+			t.handlerContext.delay = returnedAt.Sub(end)
+		}
+	}
+	close(stop)
+}
+
+// requestContext returns "req" with the context set by Context and
+// Timeout methods, if any, and the function to call once the request
+// is handled.
+func (t *TestAPI) requestContext(req *http.Request) (*http.Request, context.CancelFunc) {
+	ctx, cancel := req.Context(), context.CancelFunc(func() {})
+	if t.ctx != nil {
+		ctx = t.ctx
+	}
+	if t.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, t.timeout)
+	}
+	if ctx != req.Context() {
+		req = req.WithContext(ctx)
+	}
+	return req, cancel
+}
+
+// recoverPanic returns the panic raised by "fn", nil if none.
+func recoverPanic(fn func()) (p *handlerPanic) {
+	defer func() {
+		if p == nil {
+			return
+		}
+		p.value = recover()
+
+		buf := make([]byte, 8192)
+		n := runtime.Stack(buf, false)
+		for ; n > 0; n-- {
+			if buf[n-1] != '\n' {
+				break
+			}
+		}
+		p.stack = string(buf[:n])
+	}()
+	p = &handlerPanic{}
+	fn()
+	return nil
+}
+
+// reportPanic reports the panic of the handler, if it occurred and
+// is not expected (see CmpPanic). It returns false in this case.
+func (t *TestAPI) reportPanic() bool {
+	if !t.panicFailed || t.handlerPanic == nil {
+		return true
+	}
+	if t.panicReported {
+		return false
+	}
+	t.panicReported = true
+
+	t.t.Helper()
+	return t.t.RootName("Handler").Code(t.handlerPanic,
+		func(p *handlerPanic) error {
+			return &ctxerr.Error{
+				Message:  "%% should NOT have panicked",
+				Got:      types.RawString(p.String()),
+				Expected: types.RawString("not panicking at all"),
+			}
+		},
+		t.name+"handler should not panic")
+}
+
+// Context sets the context used by all the following requests, in
+// place of the context of each request. It allows to pass values to
+// the handler or to cancel requests. A nil "ctx" restores the
+// context of each request.
+//
+//   ctx := context.WithValue(context.Background(), userKey, "Bob")
+//
+//   ta.Context(ctx).
+//     Get("/me").
+//     CmpStatus(http.StatusOK).
+//     CmpJSONBody(td.SuperMapOf(map[string]interface{}{"name": "Bob"}, nil))
+//
+// Instances derived using With or Run methods use the same context.
+//
+// See Timeout method to set a deadline to each request.
+func (t *TestAPI) Context(ctx context.Context) *TestAPI {
+	t.ctx = ctx
+	return t
+}
+
+// Timeout sets a timeout to each following request: the handler
+// receives a context, derived from the one set by Context method if
+// any, canceled after "timeout". A zero "timeout" disables it.
+//
+//   ta.Timeout(50 * time.Millisecond).
+//     Get("/slow").
+//     CmpCancelHonored(10 * time.Millisecond)
+//
+// Instances derived using With or Run methods use the same timeout.
+func (t *TestAPI) Timeout(timeout time.Duration) *TestAPI {
+	t.timeout = timeout
+	return t
+}
+
+// CmpPanic tests that the handler panicked during the last request,
+// with the "expectedPanic" parameter. "expectedPanic" can be a
+// TestDeep operator, as in:
+//
+//   ta.Get("/boom").
+//     CmpPanic(td.HasPrefix("index out of range"))
+//
+// When the handler panics, the panic is recovered and, if CmpPanic
+// is not called, it is reported along with the stack trace of the
+// handler by the first following Cmp* or NoBody call, by the next
+// request or, starting go1.14, at the end of the test. Note that
+// http.ErrAbortHandler panics are handled like any other ones.
+//
+// It fails if no request has been sent yet.
+func (t *TestAPI) CmpPanic(expectedPanic interface{}) *TestAPI {
+	defer t.t.AnchorsPersistTemporarily()()
+
+	t.t.Helper()
+
+	if !t.isRequestSent() {
+		t.panicFailed = true
+		return t
+	}
+
+	if t.handlerPanic == nil {
+		t.panicFailed = true
+		t.t.RootName("Handler").Code(false,
+			func(bool) error {
+				return &ctxerr.Error{
+					Message: "%% should have panicked",
+					Summary: ctxerr.NewSummary("did not panic"),
+				}
+			},
+			t.name+"handler should panic")
+		return t
+	}
+
+	t.panicFailed = !t.t.RootName("Handler→panic()").
+		Cmp(t.handlerPanic.value, expectedPanic, t.name+"handler panic should match")
+	if t.panicFailed {
+		t.panicReported = true
+		t.t.Logf("Handler stack trace:\n%s", t.handlerPanic.stack)
+	}
+
+	return t
+}
+
+// CmpCancelHonored tests that the context of the last request has
+// been canceled or has expired while the handler was running, and
+// that the handler returned at most "maxDelay" after. See Context and
+// Timeout methods to control this context.
+//
+//   ctx, cancel := context.WithCancel(context.Background())
+//   time.AfterFunc(20*time.Millisecond, cancel)
+//
+//   ta.Context(ctx).
+//     Get("/long-polling").
+//     CmpCancelHonored(5 * time.Millisecond)
+//
+// It fails if no request has been sent yet.
+func (t *TestAPI) CmpCancelHonored(maxDelay time.Duration) *TestAPI {
+	t.t.Helper()
+
+	if !t.checkRequestSent() {
+		t.cancelFailed = true
+		return t
+	}
+
+	if !t.handlerContext.done {
+		t.cancelFailed = true
+		t.t.RootName("Handler").Code(false,
+			func(bool) error {
+				return &ctxerr.Error{
+					Message: "%% returned before the end of the request context",
+					Summary: ctxerr.NewSummary("context not canceled nor expired, see Context and Timeout methods"),
+				}
+			},
+			t.name+"handler should honor context cancellation")
+		return t
+	}
+
+	t.cancelFailed = !t.t.RootName("Handler.ReturnDelay").
+		Cmp(t.handlerContext.delay, td.Lte(maxDelay),
+			t.name+"handler should honor context cancellation")
+
+	return t
+}
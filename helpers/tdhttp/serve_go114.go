@@ -0,0 +1,25 @@
+// Copyright (c) 2021, Maxime Soulé
+// All rights reserved.
+//
+// This source code is licensed under the BSD-style license found in the
+// LICENSE file in the root directory of this source tree.
+
+// +build go1.14
+
+package tdhttp
+
+// panicRegister arranges an unexpected handler panic to be reported
+// at the end of the test, if neither CmpPanic, nor a following Cmp*
+// method or request did it before.
+func (t *TestAPI) panicRegister() {
+	if t.panicCleanup {
+		return
+	}
+	t.panicCleanup = true
+	t.t.Cleanup(func() {
+		if t.response != nil {
+			t.t.Helper()
+			t.reportPanic()
+		}
+	})
+}
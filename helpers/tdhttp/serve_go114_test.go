@@ -0,0 +1,56 @@
+// Copyright (c) 2021, Maxime Soulé
+// All rights reserved.
+//
+// This source code is licensed under the BSD-style license found in the
+// LICENSE file in the root directory of this source tree.
+
+// +build go1.14
+
+package tdhttp_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/maxatome/go-testdeep/td"
+)
+
+func TestPanicCleanup(t *testing.T) {
+	mux := serveServer()
+
+	t.Run("Last request", func(t *testing.T) {
+		tb, ta := newTestAPI(t, mux)
+
+		ta.Get("/boom")
+		td.CmpEmpty(t, tb.Messages) // not reported yet
+
+		tb.RunCleanup()
+		td.CmpTrue(t, tb.Failed())
+		td.Cmp(t, tb.Messages, td.Len(1))
+		td.Cmp(t, tb.LastMessage(), td.All(
+			td.Contains("Failed test 'handler should not panic'"),
+			td.Contains(`panic: "boom!"`),
+		))
+	})
+
+	t.Run("Already reported", func(t *testing.T) {
+		tb, ta := newTestAPI(t, mux)
+
+		ta.Get("/boom").CmpStatus(http.StatusOK)
+		td.Cmp(t, tb.Messages, td.Len(1))
+
+		tb.RunCleanup()
+		td.Cmp(t, tb.Messages, td.Len(1))
+	})
+
+	t.Run("Expected", func(t *testing.T) {
+		tb, ta := newTestAPI(t, mux)
+
+		ta.Get("/boom").CmpPanic("boom!")
+		ta.Get("/boom").CmpPanic("boom!")
+
+		tb.RunCleanup()
+		td.CmpFalse(t, tb.Failed())
+		td.CmpEmpty(t, tb.Messages)
+	})
+}
@@ -0,0 +1,13 @@
+// Copyright (c) 2021, Maxime Soulé
+// All rights reserved.
+//
+// This source code is licensed under the BSD-style license found in the
+// LICENSE file in the root directory of this source tree.
+
+// +build !go1.14
+
+package tdhttp
+
+// panicRegister does nothing as testing.TB has no Cleanup method
+// before go1.14.
+func (t *TestAPI) panicRegister() {}
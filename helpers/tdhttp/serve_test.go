@@ -0,0 +1,179 @@
+// Copyright (c) 2021, Maxime Soulé
+// All rights reserved.
+//
+// This source code is licensed under the BSD-style license found in the
+// LICENSE file in the root directory of this source tree.
+
+package tdhttp_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/maxatome/go-testdeep/helpers/tdhttp"
+	"github.com/maxatome/go-testdeep/td"
+)
+
+type ctxKey struct{}
+
+func serveServer() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/boom", func(w http.ResponseWriter, req *http.Request) {
+		panic("boom!")
+	})
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, "value=%v", req.Context().Value(ctxKey{}))
+	})
+	mux.HandleFunc("/wait", func(w http.ResponseWriter, req *http.Request) {
+		<-req.Context().Done()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	mux.HandleFunc("/sleep", func(w http.ResponseWriter, req *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	})
+	return mux
+}
+
+func TestPanic(t *testing.T) {
+	mux := serveServer()
+
+	t.Run("Expected", func(t *testing.T) {
+		tb, ta := newTestAPI(t, mux)
+
+		td.CmpFalse(t, ta.Get("/boom").CmpPanic("boom!").Failed())
+		td.CmpFalse(t, ta.Get("/boom").CmpPanic(td.HasPrefix("boo")).Failed())
+		ta.Get("/ok").CmpStatus(http.StatusOK)
+		td.CmpEmpty(t, tb.Messages)
+	})
+
+	t.Run("Unexpected", func(t *testing.T) {
+		tb, ta := newTestAPI(t, mux)
+
+		td.CmpTrue(t, ta.Get("/boom").Failed())
+		td.CmpEmpty(t, tb.Messages) // not reported yet
+
+		ta.CmpStatus(http.StatusOK).CmpBody("")
+		td.CmpTrue(t, ta.Failed())
+		td.Cmp(t, tb.Messages, td.Len(1)) // reported only once
+		td.Cmp(t, tb.LastMessage(), td.All(
+			td.Contains("Failed test 'handler should not panic'"),
+			td.Contains("Handler should NOT have panicked"),
+			td.Contains(`panic: "boom!"`),
+			td.Contains("serve_test.go"),
+			td.Contains("not panicking at all"),
+		))
+
+		// Reported by the next request
+		tb.ResetMessages()
+		ta.Get("/boom").Get("/ok")
+		td.Cmp(t, tb.LastMessage(), td.Contains("Handler should NOT have panicked"))
+		td.CmpFalse(t, ta.CmpStatus(http.StatusOK).Failed())
+	})
+
+	t.Run("CmpPanic failures", func(t *testing.T) {
+		tb, ta := newTestAPI(t, mux)
+
+		td.CmpTrue(t, ta.CmpPanic("boom!").Failed())
+		td.Cmp(t, tb.LastMessage(), td.Contains("Request not sent!"))
+
+		tb.ResetMessages()
+		td.CmpTrue(t, ta.Get("/ok").CmpPanic("boom!").Failed())
+		td.Cmp(t, tb.LastMessage(), td.All(
+			td.Contains("Failed test 'handler should panic'"),
+			td.Contains("Handler should have panicked"),
+			td.Contains("did not panic"),
+		))
+
+		tb.ResetMessages()
+		td.CmpTrue(t, ta.Get("/boom").CmpPanic("bang!").Failed())
+		if td.Cmp(t, tb.Messages, td.Len(2)) {
+			td.Cmp(t, tb.Messages[0], td.All(
+				td.Contains("Failed test 'handler panic should match'"),
+				td.Contains("Handler→panic(): values differ"),
+				td.Contains(`"boom!"`),
+				td.Contains(`"bang!"`),
+			))
+			td.Cmp(t, tb.Messages[1], td.All(
+				td.HasPrefix("Handler stack trace:\n"),
+				td.Contains("serve_test.go"),
+			))
+		}
+
+		// Already reported
+		tb.ResetMessages()
+		ta.CmpStatus(http.StatusOK)
+		td.CmpEmpty(t, tb.Messages)
+	})
+}
+
+func TestContext(t *testing.T) {
+	mux := serveServer()
+
+	t.Run("Values", func(t *testing.T) {
+		tb, ta := newTestAPI(t, mux)
+
+		ta.Context(context.WithValue(context.Background(), ctxKey{}, "Bob")).
+			Get("/ok").
+			CmpBody("value=Bob")
+
+		ta.Run("sub", func(ta *tdhttp.TestAPI) {
+			ta.Get("/ok").CmpBody("value=Bob")
+		})
+		ta.With(tb).Get("/ok").CmpBody("value=Bob")
+
+		var noCtx context.Context
+		ta.Context(noCtx).Get("/ok").CmpBody("value=<nil>")
+
+		td.Cmp(t, tb.Messages, []string{"++++ sub"})
+	})
+
+	t.Run("Cancel honored", func(t *testing.T) {
+		tb, ta := newTestAPI(t, mux)
+
+		ta.Timeout(10 * time.Millisecond).
+			Get("/wait").
+			CmpStatus(http.StatusServiceUnavailable).
+			CmpCancelHonored(100 * time.Millisecond)
+		td.CmpFalse(t, ta.Failed())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(10*time.Millisecond, cancel)
+		ta.Timeout(0).
+			Context(ctx).
+			Get("/wait").
+			CmpCancelHonored(100 * time.Millisecond)
+		td.CmpFalse(t, ta.Failed())
+
+		td.CmpEmpty(t, tb.Messages)
+	})
+
+	t.Run("Cancel not honored", func(t *testing.T) {
+		tb, ta := newTestAPI(t, mux)
+
+		td.CmpTrue(t, ta.CmpCancelHonored(time.Second).Failed())
+		td.Cmp(t, tb.LastMessage(), td.Contains("Request not sent!"))
+
+		tb.ResetMessages()
+		td.CmpTrue(t, ta.Get("/ok").CmpCancelHonored(time.Second).Failed())
+		td.Cmp(t, tb.LastMessage(), td.All(
+			td.Contains("Failed test 'handler should honor context cancellation'"),
+			td.Contains("Handler returned before the end of the request context"),
+			td.Contains("see Context and Timeout methods"),
+		))
+
+		tb.ResetMessages()
+		td.CmpTrue(t,
+			ta.Timeout(5*time.Millisecond).
+				Get("/sleep").
+				CmpCancelHonored(50*time.Millisecond).
+				Failed())
+		td.Cmp(t, tb.LastMessage(), td.All(
+			td.Contains("Failed test 'handler should honor context cancellation'"),
+			td.Contains("Handler.ReturnDelay: values differ"),
+			td.Contains("≤ 50ms"),
+		))
+	})
+}
@@ -7,6 +7,8 @@
 package tdhttp
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"encoding/xml"
 	"fmt"
@@ -16,6 +18,7 @@ import (
 	"net/url"
 	"reflect"
 	"runtime"
+	"sort"
 	"strings"
 	"testing"
 	"time"
@@ -25,6 +28,7 @@ import (
 	"github.com/maxatome/go-testdeep/internal/color"
 	"github.com/maxatome/go-testdeep/internal/ctxerr"
 	"github.com/maxatome/go-testdeep/internal/types"
+	"github.com/maxatome/go-testdeep/internal/util"
 	"github.com/maxatome/go-testdeep/td"
 )
 
@@ -35,15 +39,41 @@ type TestAPI struct {
 	handler http.Handler
 	name    string
 
-	sentAt       time.Time
-	response     *httptest.ResponseRecorder
-	statusFailed bool
-	headerFailed bool
-	bodyFailed   bool
+	sentAt         time.Time
+	response       *httptest.ResponseRecorder
+	statusFailed   bool
+	headerFailed   bool
+	bodyFailed     bool
+	contractFailed bool
 
 	// autoDumpResponse dumps the received response when a test fails.
 	autoDumpResponse bool
 	responseDumped   bool
+
+	vars    *variables
+	har     *harRecorder
+	openAPI *OpenAPI
+
+	ctx     context.Context
+	timeout time.Duration
+
+	handlerPanic   *handlerPanic
+	panicFailed    bool // handler panicked unexpectedly or CmpPanic failed
+	panicReported  bool
+	panicCleanup   bool // end of test panic report is registered
+	handlerContext handlerContext
+	cancelFailed   bool
+
+	maxRedirects   int
+	request        *http.Request // last request sent to the handler
+	redirects      []*http.Response
+	redirectFailed bool
+
+	jsonrpc       *jsonrpcExchange
+	jsonrpcLastID int64
+
+	next       *nextRecorder // only set by NewMiddlewareTestAPI
+	nextFailed bool
 }
 
 // NewTestAPI creates a TestAPI that can be used to test routes of the
@@ -71,13 +101,14 @@ func NewTestAPI(tb testing.TB, handler http.Handler) *TestAPI {
 	return &TestAPI{
 		t:       td.NewT(tb),
 		handler: handler,
+		vars:    newVariables(),
 	}
 }
 
 // With creates a new *TestAPI instance copied from "t", but resetting
 // the testing.TB instance the tests are based on to "tb". The
 // returned instance is independent from "t", sharing only the same
-// handler.
+// handler and variables store (see SetVar and Capture).
 //
 // It is typically used when the *TestAPI instance is "reused" in
 // sub-tests, as in:
@@ -101,11 +132,21 @@ func NewTestAPI(tb testing.TB, handler http.Handler) *TestAPI {
 //
 // See Run method for another way to handle subtests.
 func (t *TestAPI) With(tb testing.TB) *TestAPI {
-	return &TestAPI{
+	nt := &TestAPI{
 		t:                td.NewT(tb),
 		handler:          t.handler,
 		autoDumpResponse: t.autoDumpResponse,
+		vars:             t.vars,
+		openAPI:          t.openAPI,
+		ctx:              t.ctx,
+		timeout:          t.timeout,
+		maxRedirects:     t.maxRedirects,
+	}
+	if t.next != nil {
+		nt.next, nt.handler = t.next.clone()
 	}
+	t.harCopy(nt)
+	return nt
 }
 
 // T returns the internal instance of *td.T.
@@ -113,10 +154,12 @@ func (t *TestAPI) T() *td.T {
 	return t.t
 }
 
-// Run runs "f" as a subtest of t called "name".
+// Run runs "f" as a subtest of t called "name". The *TestAPI
+// instance passed to "f" is created by With, so shares the same
+// handler and variables store as "t" (see SetVar and Capture).
 func (t *TestAPI) Run(name string, f func(t *TestAPI)) bool {
 	return t.t.Run(name, func(tdt *td.T) {
-		f(NewTestAPI(tdt, t.handler))
+		f(t.With(tdt))
 	})
 }
 
@@ -143,23 +186,208 @@ func (t *TestAPI) Name(args ...interface{}) *TestAPI {
 // Request sends a new HTTP request to the tested API. Any Cmp* or
 // NoBody methods can now be called.
 //
+// All {name} occurrences in the request target, headers and JSON
+// body string values are replaced by the value of the variable
+// "name", set or captured (see SetVar, Capture and CaptureHeader). In
+// a JSON body, a "{name}" string value is replaced by the JSON
+// representation of the value, so keeping its type, and object keys
+// are never replaced. Use {{name}} to send a literal {name}. If a
+// variable is unknown or its capture failed, the test fails and the
+// request is not sent.
+//
+// If enabled by FollowRedirects method, redirections are then
+// followed, all the following Cmp* methods testing the last response
+// received.
+//
+// The handler is called with the context set by Context and Timeout
+// methods, if any. If it panics, the panic is recovered and reported
+// as a test failure, unless CmpPanic is called to expect it.
+//
 // Note that Failed() status is reset just after this call.
 func (t *TestAPI) Request(req *http.Request) *TestAPI {
-	t.response = httptest.NewRecorder()
+	t.t.Helper()
+
+	// Report the previous unexpected handler panic, if not done yet
+	if t.response != nil {
+		t.reportPanic()
+	}
+	t.jsonrpc = nil
+
+	if !t.substituteVars(req) {
+		t.response = nil
+		t.request = nil
+		t.redirects = nil
+		t.redirectFailed = false
+		t.statusFailed = true
+		t.headerFailed = true
+		t.bodyFailed = true
+		t.contractFailed = false
+		t.handlerPanic = nil
+		t.panicFailed = false
+		t.cancelFailed = false
+		t.nextFailed = false
+		return t
+	}
 
 	t.statusFailed = false
 	t.headerFailed = false
 	t.bodyFailed = false
-	t.sentAt = time.Now().Truncate(0)
+	t.contractFailed = false
+	t.redirectFailed = false
+	t.nextFailed = false
 	t.responseDumped = false
+	t.redirects = nil
+
+	for {
+		reqBody := t.send(req)
+		if t.maxRedirects == 0 || t.handlerPanic != nil {
+			break
+		}
+		next := t.redirectRequest(req, reqBody)
+		if next == nil {
+			break
+		}
+		if len(t.redirects) == t.maxRedirects {
+			t.reportTooManyRedirects()
+			break
+		}
+		resp := t.response.Result()
+		resp.Request = req
+		t.redirects = append(t.redirects, resp)
+		req = next
+	}
+
+	return t
+}
+
+// substituteVars substitutes the variables in "req", reporting a
+// failure and returning false if it is not possible.
+func (t *TestAPI) substituteVars(req *http.Request) bool {
+	t.t.Helper()
+
+	missing, err := t.vars.substitute(req)
+	if err == nil && missing == "" {
+		return true
+	}
+
+	t.t.RootName("Request").Code(missing,
+		func(name string) error {
+			if err != nil {
+				return &ctxerr.Error{
+					Message: "%% variables cannot be substituted",
+					Summary: ctxerr.NewSummary(err.Error()),
+				}
+			}
+			known := "no variables known yet"
+			if names := t.vars.names(); len(names) > 0 {
+				known = "known variables: " + strings.Join(names, ", ")
+			}
+			message := "%% uses unknown variable {" + name + "}"
+			if t.vars.isFailed(name) {
+				message = "%% uses variable {" + name + "} whose capture failed"
+			}
+			return &ctxerr.Error{
+				Message: message,
+				Summary: ctxerr.NewSummary(known +
+					"\nuse {{" + name + "}} to send {" + name + "} literally"),
+			}
+		},
+		t.name+"request variables substitution")
+	return false
+}
+
+// send sends "req" to the handler, checking it against the OpenAPI
+// spec and recording it in HAR if needed. It returns the body of
+// "req" if it has been read.
+func (t *TestAPI) send(req *http.Request) []byte {
+	t.t.Helper()
 
-	t.handler.ServeHTTP(t.response, req)
+	t.response = httptest.NewRecorder()
+	t.request = req
+	if t.next != nil {
+		t.next.reset()
+	}
+
+	var (
+		reqBody    []byte
+		op         *openAPIOperation
+		violations []openAPIViolation
+	)
+	if t.har != nil || t.openAPI != nil || t.maxRedirects > 0 {
+		reqBody = readRequestBody(req)
+	}
+	if t.openAPI != nil {
+		op, violations = t.openAPI.validateRequest(req, reqBody)
+		t.cmpOpenAPI(violations, "request")
+	}
+
+	t.serveHTTP(req)
+
+	if t.har != nil {
+		t.harAdd(req, reqBody, time.Since(t.sentAt))
+	}
+	if op != nil && t.handlerPanic == nil {
+		t.cmpOpenAPI(t.openAPI.validateResponse(op, req, t.response), "response")
+	}
 
+	return reqBody
+}
+
+// OpenAPI enables the validation of all the following requests and
+// their responses against the OpenAPI 3 spec "spec". A nil "spec"
+// disables the validation.
+//
+//   spec, err := tdhttp.LoadOpenAPI("api/openapi.yaml")
+//   td.Require(t).CmpNoError(err)
+//
+//   ta := tdhttp.NewTestAPI(t, mux).OpenAPI(spec)
+//
+// For each request, the operation matching its path and method is
+// searched in the spec. Its parameters (path, query, header and
+// cookie ones) and its request body are then validated. Once the
+// response is received, its status code, its headers and its body
+// are validated against the corresponding response object of the
+// spec. Only JSON bodies are validated against their schema.
+//
+// Each violation is reported as a test failure, with the path of
+// the invalid value in the request or the response, as
+// "Response.Body/items/0/id", and the JSON pointer of the violated
+// part of the spec, as
+// "#/components/schemas/Item/properties/id/minimum".
+//
+// Instances derived using With or Run methods use the same spec.
+func (t *TestAPI) OpenAPI(spec *OpenAPI) *TestAPI {
+	t.openAPI = spec
 	return t
 }
 
+// cmpOpenAPI reports each violation of "violations", "what" being
+// "request" or "response".
+func (t *TestAPI) cmpOpenAPI(violations []openAPIViolation, what string) {
+	t.t.Helper()
+
+	for _, v := range violations {
+		t.contractFailed = true
+		t.t.RootName(v.got).Code(v,
+			func(v openAPIViolation) error {
+				return &ctxerr.Error{
+					Message: "does not conform to OpenAPI spec",
+					Summary: ctxerr.NewSummary(v.message + "\nspec: " + v.spec),
+				}
+			},
+			t.name+what+" should conform to OpenAPI spec")
+	}
+}
+
+// checkRequestSent checks a request has been sent and its handler
+// did not panic unexpectedly.
 func (t *TestAPI) checkRequestSent() bool {
 	t.t.Helper()
+	return t.isRequestSent() && t.reportPanic()
+}
+
+func (t *TestAPI) isRequestSent() bool {
+	t.t.Helper()
 
 	// If no request has been sent, display a nice error message
 	return t.t.RootName("Request").
@@ -177,9 +405,14 @@ func (t *TestAPI) checkRequestSent() bool {
 }
 
 // Failed returns true if any Cmp* or NoBody method failed since last
-// request sending.
+// request sending, if the last exchange does not conform to the
+// OpenAPI spec (see OpenAPI method), if the handler panicked
+// unexpectedly (see CmpPanic method) or if too many redirections
+// occurred (see FollowRedirects method).
 func (t *TestAPI) Failed() bool {
-	return t.statusFailed || t.headerFailed || t.bodyFailed
+	return t.statusFailed || t.headerFailed || t.bodyFailed ||
+		t.contractFailed || t.panicFailed || t.cancelFailed ||
+		t.redirectFailed || t.nextFailed
 }
 
 // Get sends a HTTP GET to the tested API. Any Cmp* or NoBody methods
@@ -599,6 +832,31 @@ func (t *TestAPI) CmpMarshaledBody(unmarshal func([]byte, interface{}) error, ex
 //     CmpStatus(http.StatusOK).
 //     CmpBody(td.Contains("OK"))
 //
+// If expectedBody is neither a []byte, nor a string, nor a TestDeep
+// operator whose type behind is unknown or one of these two types,
+// the body is automatically unmarshaled before being compared. The
+// unmarshaler is chosen depending on the response Content-Type
+// header (JSON, XML, x-www-form-urlencoded or text by default, see
+// RegisterMediaType to add new ones), after the body has been
+// decoded according to the Content-Encoding header (gzip and deflate
+// by default, see RegisterContentEncoding to add new ones):
+//
+//   ta.Get("/person/42", "Accept", "application/xml").
+//     CmpStatus(http.StatusOK).
+//     CmpBody(Person{ // decoded as XML
+//       ID:   42,
+//       Name: "Bob",
+//       Age:  26,
+//     })
+//
+//   ta.Get("/person/42", "Accept", "application/json").
+//     CmpStatus(http.StatusOK).
+//     CmpBody(td.JSON(`{"id": 42, "name": "Bob", "age": 26}`))
+//
+// In this case, the body cannot be empty and the test fails if the
+// Content-Type header is missing, not supported or if the body
+// cannot be unmarshaled as declared by this header.
+//
 // It fails if no request has been sent yet.
 func (t *TestAPI) CmpBody(expectedBody interface{}) *TestAPI {
 	t.t.Helper()
@@ -607,6 +865,16 @@ func (t *TestAPI) CmpBody(expectedBody interface{}) *TestAPI {
 		return t.NoBody()
 	}
 
+	if !isRawBodyType(expectedBodyType(expectedBody)) {
+		return t.cmpMarshaledBody(
+			false, // do not accept empty body
+			func(body []byte, target interface{}) error {
+				// cmpMarshaledBody only calls us once a request has been sent
+				return autoUnmarshal(t.response.Header())(body, target)
+			},
+			expectedBody)
+	}
+
 	return t.cmpMarshaledBody(
 		true, // accept empty body
 		func(body []byte, target interface{}) error {
@@ -727,6 +995,221 @@ func (t *TestAPI) CmpXMLBody(expectedBody interface{}) *TestAPI {
 	return t.CmpMarshaledBody(xml.Unmarshal, expectedBody)
 }
 
+// CmpJSONPointer tests that the last request response body can be
+// encoding/json.Unmarshall'ed and that the value pointed by the JSON
+// pointer "pointer" (as RFC 6901 specifies it) matches
+// expectedValue. expectedValue can be any type or a TestDeep
+// operator. It is useful to only test some values deep inside a big
+// JSON response.
+//
+//   ta := tdhttp.NewTestAPI(t, mux)
+//
+//   ta.Get("/orders").
+//     CmpStatus(http.StatusOK).
+//     CmpJSONPointer("/data/items/0/id", 42).
+//     CmpJSONPointer("/data/items/0/tags", td.Contains("urgent"))
+//
+// As with td.JSONPointer operator, the pointed value is converted
+// back to the type of expectedValue (or to the type behind it if it
+// is a TestDeep operator) if this type is a struct, a struct pointer
+// or implements encoding/json.Unmarshaler interface. In all other
+// cases, the comparison is done in Lax mode, to simplify numeric
+// tests.
+//
+// The pointed value can be captured for a next request using
+// td.Catch operator:
+//
+//   var id int64
+//   ta.PostJSON("/orders", Order{Label: "foo"}).
+//     CmpStatus(http.StatusCreated).
+//     CmpJSONPointer("/data/id", td.Catch(&id, td.NotZero()))
+//
+// It is a shortcut for CmpJSONBody(td.JSONPointer(pointer,
+// expectedValue)), except that the body is decoded according to the
+// Content-Encoding header before being unmarshaled (see
+// RegisterContentEncoding).
+//
+// It fails if no request has been sent yet.
+func (t *TestAPI) CmpJSONPointer(pointer string, expectedValue interface{}) *TestAPI {
+	t.t.Helper()
+	return t.cmpMarshaledBody(false, t.unmarshalDecodedJSON,
+		td.JSONPointer(pointer, expectedValue))
+}
+
+// CmpJSONPointers is the same as CmpJSONPointer, but tests several
+// JSON pointers at once. Each key of "expected" is a JSON pointer
+// and each value the corresponding expected value. JSON pointers
+// are tested in lexical order.
+//
+//   ta.Get("/orders").
+//     CmpStatus(http.StatusOK).
+//     CmpJSONPointers(map[string]interface{}{
+//       "/data/count":         td.Gt(0),
+//       "/data/items/0/id":    42,
+//       "/data/items/0/label": "foo",
+//     })
+//
+// It is a shortcut for CmpJSONBody(td.All(td.JSONPointer(…), …)),
+// except that the body is decoded according to the Content-Encoding
+// header before being unmarshaled.
+//
+// It fails if no request has been sent yet.
+func (t *TestAPI) CmpJSONPointers(expected map[string]interface{}) *TestAPI {
+	t.t.Helper()
+
+	pointers := make([]string, 0, len(expected))
+	for pointer := range expected {
+		pointers = append(pointers, pointer)
+	}
+	sort.Strings(pointers)
+
+	ops := make([]interface{}, len(pointers))
+	for i, pointer := range pointers {
+		ops[i] = td.JSONPointer(pointer, expected[pointer])
+	}
+	return t.cmpMarshaledBody(false, t.unmarshalDecodedJSON, td.All(ops...))
+}
+
+// unmarshalDecodedJSON decodes "body" according to the
+// Content-Encoding header of the last response, then unmarshals it
+// as JSON into "target".
+func (t *TestAPI) unmarshalDecodedJSON(body []byte, target interface{}) error {
+	body, err := decodeContentEncoding(body, t.response.Header().Get("Content-Encoding"))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, target)
+}
+
+// SetVar sets the variable "name" to "value" in the variables store,
+// so {name} can be used in next requests. See Request for details.
+//
+//   ta.SetVar("token", "secret").
+//     Get("/users/me", "Authorization", "Bearer {token}")
+func (t *TestAPI) SetVar(name string, value interface{}) *TestAPI {
+	t.vars.set(name, value)
+	return t
+}
+
+// Var returns the value of variable "name" from the variables store,
+// or nil if it is unknown.
+func (t *TestAPI) Var(name string) interface{} {
+	value, _ := t.vars.get(name)
+	return value
+}
+
+// Capture stores in the variable "name" the value pointed by the JSON
+// pointer "pointer" (as RFC 6901 specifies it) in the last request
+// response body, so {name} can be used in next requests. An empty
+// "pointer" captures the whole body. See Request for details.
+//
+//   ta.PostJSON("/users", User{Name: "Bob"}).
+//     CmpStatus(http.StatusCreated).
+//     Capture("id", "/id")
+//
+//   ta.Get("/users/{id}").
+//     CmpStatus(http.StatusOK)
+//
+//   ta.Delete("/users/{id}", nil).
+//     CmpStatus(http.StatusNoContent)
+//
+// JSON numbers are captured as json.Number, so they are substituted
+// as is. The test fails if no request has been sent yet, if the body
+// cannot be unmarshaled or if "pointer" does not exist in it.
+func (t *TestAPI) Capture(name, pointer string) *TestAPI {
+	t.t.Helper()
+
+	t.vars.fail(name) // so usage of {name} is reported if the capture fails
+
+	if t.bodyFailed = !t.checkRequestSent(); t.bodyFailed {
+		return t
+	}
+
+	var body interface{}
+	b, err := decodeContentEncoding(t.response.Body.Bytes(),
+		t.response.Header().Get("Content-Encoding"))
+	if err == nil {
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.UseNumber()
+		err = dec.Decode(&body)
+	}
+	if !t.t.RootName("unmarshal(Response.Body)").
+		CmpNoError(err, t.name+"body unmarshaling to capture {%s}", name) {
+		t.bodyFailed = true
+		t.dumpResponse() // let's show its real body contents
+		return t
+	}
+
+	value, err := util.JSONPointer(body, pointer)
+	if err != nil {
+		pErr := err.(*util.JSONPointerError)
+		if pErr.Pointer == "" {
+			pErr.Pointer = pointer
+		}
+		t.t.RootName("Response.Body"+pErr.Pointer).Code(pointer,
+			func(string) error {
+				return &ctxerr.Error{
+					Message: "cannot retrieve value via JSON pointer",
+					Summary: ctxerr.NewSummary(pErr.Type),
+				}
+			},
+			t.name+"body %s captured to {%s}", pointer, name)
+		t.bodyFailed = true
+		if t.autoDumpResponse {
+			t.dumpResponse()
+		}
+		return t
+	}
+
+	t.vars.set(name, value)
+	return t
+}
+
+// CaptureHeader stores in the variable "name" the value of the
+// header "key" of the last request response, so {name} can be used
+// in next requests. See Request for details.
+//
+//   ta.PostJSON("/login", Credentials{User: "bob", Password: "secret"}).
+//     CmpStatus(http.StatusOK).
+//     CaptureHeader("token", "X-Auth-Token")
+//
+//   ta.Get("/users/me", "Authorization", "Bearer {token}").
+//     CmpStatus(http.StatusOK)
+//
+// The test fails if no request has been sent yet or if the header
+// does not exist.
+func (t *TestAPI) CaptureHeader(name, key string) *TestAPI {
+	t.t.Helper()
+
+	t.vars.fail(name) // so usage of {name} is reported if the capture fails
+
+	if t.headerFailed = !t.checkRequestSent(); t.headerFailed {
+		return t
+	}
+
+	header := t.response.Header()
+	if !t.t.RootName("Response.Header").Code(header,
+		func(header http.Header) error {
+			if _, ok := header[http.CanonicalHeaderKey(key)]; ok {
+				return nil
+			}
+			return &ctxerr.Error{
+				Message: "%% has no " + key + " key",
+				Summary: ctxerr.NewSummary("cannot capture {" + name + "}"),
+			}
+		},
+		t.name+"header %s captured to {%s}", key, name) {
+		t.headerFailed = true
+		if t.autoDumpResponse {
+			t.dumpResponse()
+		}
+		return t
+	}
+
+	t.vars.set(name, header.Get(key))
+	return t
+}
+
 // NoBody tests that the last request response body is empty.
 //
 // It fails if no request has been sent yet.
@@ -18,6 +18,7 @@ import (
 
 	"github.com/maxatome/go-testdeep/helpers/tdhttp"
 	"github.com/maxatome/go-testdeep/helpers/tdutil"
+	"github.com/maxatome/go-testdeep/internal/test"
 	"github.com/maxatome/go-testdeep/td"
 )
 
@@ -82,6 +83,13 @@ func server() *http.ServeMux {
 	return mux
 }
 
+// newTestAPI returns a new TestAPI instance testing "handler", and
+// the test.TestingTB, named after "t", it uses to report failures.
+func newTestAPI(t *testing.T, handler http.Handler) (*test.TestingTB, *tdhttp.TestAPI) {
+	tb := test.NewTestingTB(t.Name())
+	return tb, tdhttp.NewTestAPI(tb, handler)
+}
+
 func TestNewTestAPI(t *testing.T) {
 	mux := server()
 
@@ -717,3 +725,250 @@ func TestRun(t *testing.T) {
 	})
 	td.CmpFalse(t, ok)
 }
+
+func TestCmpJSONPointer(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/orders", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"data":{"count":2,"items":[{"id":42,"label":"foo"},{"id":43,"label":"bar"}]}}`) //nolint: errcheck
+	})
+	mux.HandleFunc("/text", func(w http.ResponseWriter, req *http.Request) {
+		io.WriteString(w, "not JSON") //nolint: errcheck
+	})
+
+	type Item struct {
+		ID    int64  `json:"id"`
+		Label string `json:"label"`
+	}
+
+	t.Run("OK", func(t *testing.T) {
+		tb, ta := newTestAPI(t, mux)
+
+		var id int64
+		ta.Get("/orders").
+			CmpStatus(http.StatusOK).
+			CmpJSONPointer("/data/count", 2).
+			CmpJSONPointer("/data/items/0/id", td.Catch(&id, td.Between(40, 45))).
+			CmpJSONPointer("/data/items/1", Item{ID: 43, Label: "bar"}).
+			CmpJSONPointer("/data/items/1", td.Struct(&Item{Label: "bar"}, nil)).
+			CmpJSONPointers(map[string]interface{}{
+				"/data/items/0/label": "foo",
+				"/data/items":         td.Len(2),
+				"/data/items/1/id":    td.Gt(42),
+			})
+
+		td.CmpFalse(t, ta.Failed())
+		td.CmpEmpty(t, tb.Messages)
+		td.Cmp(t, id, int64(42))
+	})
+
+	t.Run("Failures", func(t *testing.T) {
+		tb, ta := newTestAPI(t, mux)
+
+		td.CmpTrue(t, ta.Get("/orders").
+			CmpJSONPointer("/data/items/0/id", 12).
+			Failed())
+		td.Cmp(t, tb.LastMessage(), td.All(
+			td.Contains("Failed test 'body contents is OK'"),
+			td.Contains("Response.Body.JSONPointer</data/items/0/id>: values differ"),
+		))
+
+		tb.ResetMessages()
+		td.CmpTrue(t, ta.Get("/orders").
+			CmpJSONPointer("/data/items/2/id", 12).
+			Failed())
+		td.Cmp(t, tb.LastMessage(), td.All(
+			td.Contains("Response.Body.JSONPointer</data/items/2>: cannot retrieve value via JSON pointer"),
+			td.Contains("out of array range"),
+		))
+
+		tb.ResetMessages()
+		td.CmpTrue(t, ta.Get("/orders").
+			CmpJSONPointers(map[string]interface{}{
+				"/data/count":         2,
+				"/data/items/1/label": "foo",
+			}).
+			Failed())
+		td.Cmp(t, tb.LastMessage(), td.All(
+			td.Contains("Failed test 'body contents is OK'"),
+			td.Contains("Response.Body<All#2/2>.JSONPointer</data/items/1/label>: values differ"),
+		))
+
+		tb.ResetMessages()
+		td.CmpTrue(t, ta.Get("/text").
+			CmpJSONPointer("/data", 12).
+			Failed())
+		td.Cmp(t, tb.Messages[0], td.All(
+			td.Contains("Failed test 'body unmarshaling'"),
+			td.Contains("unmarshal(Response.Body): should NOT be an error"),
+		))
+
+		td.CmpTrue(t, tdhttp.NewTestAPI(tb, mux).
+			CmpJSONPointer("/data", 12).
+			Failed())
+	})
+}
+
+func TestVariables(t *testing.T) {
+	type User struct {
+		ID   int64  `json:"id"`
+		Name string `json:"name"`
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users", func(w http.ResponseWriter, req *http.Request) {
+		var u User
+		json.NewDecoder(req.Body).Decode(&u) //nolint: errcheck
+		u.ID = 12345678901234
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Auth-Token", "tok/en")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(u) //nolint: errcheck
+	})
+	mux.HandleFunc("/users/", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{ //nolint: errcheck
+			"method": req.Method,
+			"path":   req.URL.Path,
+			"query":  req.URL.Query(),
+			"token":  req.Header.Get("Authorization"),
+		})
+	})
+	mux.HandleFunc("/echo", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.Copy(w, req.Body) //nolint: errcheck
+	})
+
+	t.Run("OK", func(t *testing.T) {
+		tb, ta := newTestAPI(t, mux)
+
+		ta.PostJSON("/users", User{Name: "Bob"}).
+			CmpStatus(http.StatusCreated).
+			Capture("id", "/id").
+			Capture("user", "").
+			CaptureHeader("token", "X-Auth-Token")
+
+		td.Cmp(t, ta.Var("id"), json.Number("12345678901234"))
+		td.Cmp(t, ta.Var("unknown"), nil)
+
+		ta.SetVar("q", "a&b").
+			Get("/users/{id}?q={q}", "Authorization", "Bearer {token}").
+			CmpStatus(http.StatusOK).
+			CmpJSONBody(td.JSON(`{
+  "method": "GET",
+  "path":   "/users/12345678901234",
+  "query":  {"q": ["a&b"]},
+  "token":  "Bearer tok/en"
+}`))
+
+		ta.Run("sub", func(ta *tdhttp.TestAPI) {
+			ta.Delete("/users/{id}", nil).
+				CmpStatus(http.StatusOK).
+				CmpJSONPointer("/method", "DELETE")
+		})
+
+		ta.PostJSON("/echo", map[string]interface{}{
+			"id":    "{id}",
+			"label": `user {id} is "{q}"`,
+			"user":  "{user}",
+		}).
+			CmpStatus(http.StatusOK).
+			CmpJSONBody(td.JSON(`{
+  "id":    12345678901234,
+  "label": "user 12345678901234 is \"a&b\"",
+  "user":  {"id": 12345678901234, "name": "Bob"}
+}`))
+
+		td.CmpFalse(t, ta.Failed())
+		td.Cmp(t, tb.Messages, []string{"++++ sub"})
+	})
+
+	t.Run("Unknown variables", func(t *testing.T) {
+		tb, ta := newTestAPI(t, mux)
+
+		td.CmpTrue(t, ta.PostJSON("/echo", map[string]string{"tpl": "{id}"}).Failed())
+		td.Cmp(t, tb.Messages[0], td.All(
+			td.Contains("Failed test 'request variables substitution'"),
+			td.Contains("Request uses unknown variable {id}"),
+			td.Contains("no variables known yet"),
+			td.Contains("use {{id}} to send {id} literally"),
+		))
+
+		tb.ResetMessages()
+		td.CmpTrue(t, ta.SetVar("id", 12).
+			Get("/users/{id}", "Authorization", "{word}").
+			Failed())
+		td.Cmp(t, tb.Messages[0], td.All(
+			td.Contains("Request uses unknown variable {word}"),
+			td.Contains("known variables: id"),
+		))
+	})
+
+	t.Run("Escaped braces and JSON keys untouched", func(t *testing.T) {
+		tb, ta := newTestAPI(t, mux)
+		ta.SetVar("id", 12)
+
+		ta.Get("/users/{id}/{{word}}?q={{word}}", "Authorization", "{{word}} {id}").
+			CmpStatus(http.StatusOK).
+			CmpJSONBody(td.JSON(`{
+  "method": "GET",
+  "path":   "/users/12/{word}",
+  "query":  {"q": ["{word}"]},
+  "token":  "{word} 12"
+}`))
+
+		ta.PostJSON("/echo", map[string]interface{}{
+			"{id}":   "{id}",
+			"{word}": "{{word}}",
+			"label":  "{id}: {{word}}",
+		}).
+			CmpStatus(http.StatusOK).
+			CmpJSONBody(td.JSON(`{
+  "{id}":   12,
+  "{word}": "{word}",
+  "label":  "12: {word}"
+}`))
+
+		td.CmpFalse(t, ta.Failed())
+		td.CmpEmpty(t, tb.Messages)
+	})
+
+	t.Run("Failures", func(t *testing.T) {
+		tb, ta := newTestAPI(t, mux)
+
+		ta.PostJSON("/users", User{Name: "Bob"}).
+			Capture("id", "/ID")
+		td.Cmp(t, tb.LastMessage(), td.All(
+			td.Contains("Failed test 'body /ID captured to {id}'"),
+			td.Contains("Response.Body/ID: cannot retrieve value via JSON pointer"),
+		))
+
+		tb.ResetMessages()
+		td.CmpTrue(t, ta.Get("/users/{id}").Failed())
+		td.Cmp(t, tb.LastMessage(), td.All(
+			td.Contains("Failed test 'request variables substitution'"),
+			td.Contains("Request uses variable {id} whose capture failed"),
+			td.Contains("no variables known yet"),
+		))
+
+		tb.ResetMessages()
+		ta.Get("/users/1").CaptureHeader("token", "X-Nope")
+		td.Cmp(t, tb.LastMessage(), td.All(
+			td.Contains("Failed test 'header X-Nope captured to {token}'"),
+			td.Contains("Response.Header has no X-Nope key"),
+		))
+
+		tb.ResetMessages()
+		ta.SetVar("a", 1).SetVar("b", 2).
+			Get("/users/1", "X-Token", "{token}").
+			CmpStatus(http.StatusOK)
+		td.Cmp(t, tb.Messages, td.All(
+			td.Len(2),
+			td.Contains(td.All(
+				td.Contains("Request uses variable {token} whose capture failed"),
+				td.Contains("known variables: a, b"),
+			)),
+			td.Contains(td.Contains("Request not sent!")),
+		))
+	})
+}
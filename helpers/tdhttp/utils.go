@@ -0,0 +1,127 @@
+// Copyright (c) 2021, Maxime Soulé
+// All rights reserved.
+//
+// This source code is licensed under the BSD-style license found in the
+// LICENSE file in the root directory of this source tree.
+
+package tdhttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+
+	"github.com/maxatome/go-testdeep/internal/color"
+	"github.com/maxatome/go-testdeep/internal/types"
+	"github.com/maxatome/go-testdeep/td"
+)
+
+// unmarshalRaw is an unmarshal function only accepting *string,
+// *[]byte or *interface{} targets, in which the raw body is copied.
+func unmarshalRaw(body []byte, target interface{}) error {
+	switch target := target.(type) {
+	case *string:
+		*target = string(body)
+	case *[]byte:
+		*target = body
+	case *interface{}:
+		*target = body
+	default:
+		return fmt.Errorf(
+			"only []byte, string or a TestDeep operator allowing to match these types are accepted as expected body, but not type %s",
+			reflect.TypeOf(target).Elem())
+	}
+	return nil
+}
+
+// expectedBodyType returns the type of "expected", or the one behind
+// "expected" if it is a TestDeep operator. It returns nil if this type
+// cannot be determined.
+func expectedBodyType(expected interface{}) reflect.Type {
+	if op, ok := expected.(td.TestDeep); ok {
+		return op.TypeBehind()
+	}
+	return reflect.TypeOf(expected)
+}
+
+// isRawBodyType returns true if "typ", as returned by
+// expectedBodyType, implies a raw body comparison.
+func isRawBodyType(typ reflect.Type) bool {
+	return typ == nil || typ == types.String || typ == bytesType
+}
+
+// readRequestBody reads the body of "req" so it can be recorded or
+// validated, then replaces it so it can still be read by the handler.
+func readRequestBody(req *http.Request) []byte {
+	if req.Body == nil {
+		return nil
+	}
+	body, _ := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return body
+}
+
+// unmarshalBody unmarshals "body" using "unmarshal" into a new value
+// whose type is the one of "expected", or the one behind "expected"
+// if it is a TestDeep operator. If this type cannot be determined,
+// "body" is unmarshaled into an interface{}.
+func unmarshalBody(body []byte, unmarshal func([]byte, interface{}) error, expected interface{}) (interface{}, error) {
+	bodyType := expectedBodyType(expected)
+	if bodyType == nil {
+		bodyType = types.Interface
+	}
+
+	bodyPtr := reflect.New(bodyType)
+	if err := unmarshal(body, bodyPtr.Interface()); err != nil {
+		return nil, err
+	}
+	return bodyPtr.Elem().Interface(), nil
+}
+
+// newResponder returns a handler always responding "status" with
+// "body" and "headers". "body" can be nil (empty body), a string, a
+// []byte or any other value that is then JSON-encoded (see
+// newJSONResponder).
+func newResponder(status int, body interface{}, headers []interface{}) http.HandlerFunc {
+	var b []byte
+	switch body := body.(type) {
+	case nil:
+	case string:
+		b = []byte(body)
+	case []byte:
+		b = body
+	default:
+		return newJSONResponder(status, body, headers)
+	}
+
+	header := http.Header{}
+	fillHeader(header, headers)
+
+	return func(w http.ResponseWriter, _ *http.Request) {
+		for k, v := range header {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(status)
+		w.Write(b) //nolint: errcheck
+	}
+}
+
+// newJSONResponder returns a handler always responding "status" with
+// "body" JSON-encoded and "headers". "Content-Type" header is
+// automatically set to "application/json".
+func newJSONResponder(status int, body interface{}, headers []interface{}) http.HandlerFunc {
+	b, err := json.Marshal(body)
+	if err != nil {
+		if opErr, ok := types.AsOperatorNotJSONMarshallableError(err); ok {
+			panic(color.Bad(opErr.Error()))
+		}
+		panic(color.Bad("JSON encoding failed: %s", err))
+	}
+	return newResponder(status, b,
+		append(headers[:len(headers):len(headers)],
+			"Content-Type", "application/json"))
+}
@@ -0,0 +1,246 @@
+// Copyright (c) 2021, Maxime Soulé
+// All rights reserved.
+//
+// This source code is licensed under the BSD-style license found in the
+// LICENSE file in the root directory of this source tree.
+
+package tdhttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	varRe     = regexp.MustCompile(`\{\{[a-zA-Z_]\w*\}\}|\{[a-zA-Z_]\w*\}`)
+	jsonVarRe = regexp.MustCompile(`^"\{([a-zA-Z_]\w*)\}"$`)
+)
+
+// variables is the variables store shared by a TestAPI and all the
+// instances derived from it using With or Run methods.
+type variables struct {
+	mu sync.Mutex
+	m  map[string]interface{}
+	// failed contains the variables whose last capture failed, so
+	// using them is reported differently from unknown variables.
+	failed map[string]bool
+}
+
+func newVariables() *variables {
+	return &variables{
+		m:      map[string]interface{}{},
+		failed: map[string]bool{},
+	}
+}
+
+// fail marks the variable "name" as not captured.
+func (v *variables) fail(name string) {
+	v.mu.Lock()
+	delete(v.m, name)
+	v.failed[name] = true
+	v.mu.Unlock()
+}
+
+func (v *variables) set(name string, value interface{}) {
+	v.mu.Lock()
+	delete(v.failed, name)
+	v.m[name] = value
+	v.mu.Unlock()
+}
+
+func (v *variables) get(name string) (interface{}, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	value, ok := v.m[name]
+	return value, ok
+}
+
+func (v *variables) isFailed(name string) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.failed[name]
+}
+
+// names returns the sorted names of all known variables.
+func (v *variables) names() []string {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	names := make([]string, 0, len(v.m))
+	for name := range v.m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// replace replaces all {name} occurrences of "s" by the value of
+// the variable "name", using "repl" to stringify it. {{name}} is an
+// escape producing the literal {name}. The name of the first unknown
+// variable (never set, or whose capture failed), if any, is returned.
+func (v *variables) replace(s string, repl func(value interface{}) string) (string, string) {
+	var missing string
+	s = varRe.ReplaceAllStringFunc(s, func(match string) string {
+		if match[1] == '{' {
+			return match[1 : len(match)-1]
+		}
+		name := match[1 : len(match)-1]
+		value, ok := v.get(name)
+		if !ok {
+			if missing == "" {
+				missing = name
+			}
+			return match
+		}
+		return repl(value)
+	})
+	return s, missing
+}
+
+// substitute replaces all {name} occurrences in "req" target and
+// headers, and in the string values of its body if it is JSON. It
+// returns the name of the first used variable that is unknown, if
+// any.
+func (v *variables) substitute(req *http.Request) (string, error) {
+	// Target
+	if req.RequestURI != "" && strings.IndexByte(req.RequestURI, '{') >= 0 {
+		target, query := req.RequestURI, ""
+		if pos := strings.IndexByte(target, '?'); pos >= 0 {
+			target, query = target[:pos], target[pos:]
+		}
+
+		var missing string
+		target, missing = v.replace(target, func(value interface{}) string {
+			return url.PathEscape(fmt.Sprint(value))
+		})
+		if missing != "" {
+			return missing, nil
+		}
+		query, missing = v.replace(query, func(value interface{}) string {
+			return url.QueryEscape(fmt.Sprint(value))
+		})
+		if missing != "" {
+			return missing, nil
+		}
+
+		u, err := url.ParseRequestURI(target + query)
+		if err != nil {
+			return "", err
+		}
+		req.RequestURI = target + query
+		req.URL = u
+		if u.Host != "" {
+			req.Host = u.Host
+		}
+	}
+
+	// Headers
+	for key, values := range req.Header {
+		for i, value := range values {
+			var missing string
+			values[i], missing = v.replace(value, func(value interface{}) string {
+				return fmt.Sprint(value)
+			})
+			if missing != "" {
+				return missing, nil
+			}
+		}
+		req.Header[key] = values
+	}
+
+	// JSON body
+	if req.Body == nil {
+		return "", nil
+	}
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json") {
+		return "", nil
+	}
+
+	body, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return "", err
+	}
+
+	newBody, missing := v.substituteJSON(body)
+
+	req.Body = ioutil.NopCloser(bytes.NewReader(newBody))
+	req.ContentLength = int64(len(newBody))
+	return missing, nil
+}
+
+// substituteJSON replaces {name} occurrences in the string values of
+// the JSON document "body", object keys being left untouched. A
+// string value only composed of "{name}" is replaced by the JSON
+// representation of the variable value, so keeping its type. The
+// document is scanned as is, so escaped braces (as \u007b) are never
+// replaced. It returns the name of the first used variable that is
+// unknown, if any.
+func (v *variables) substituteJSON(body []byte) ([]byte, string) {
+	var (
+		buf     bytes.Buffer
+		missing string
+	)
+	for i := 0; i < len(body); {
+		if body[i] != '"' {
+			buf.WriteByte(body[i])
+			i++
+			continue
+		}
+
+		// String literal, quotes included
+		end := i + 1
+		for end < len(body) && body[end] != '"' {
+			if body[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		if end >= len(body) { // invalid JSON, keep it as is
+			buf.Write(body[i:])
+			break
+		}
+		end++
+		str := string(body[i:end])
+		i = end
+
+		// Object key?
+		next := end
+		for next < len(body) && strings.IndexByte(" \t\r\n", body[next]) >= 0 {
+			next++
+		}
+		if next < len(body) && body[next] == ':' {
+			buf.WriteString(str)
+			continue
+		}
+
+		var strMissing string
+		if sub := jsonVarRe.FindStringSubmatch(str); sub != nil {
+			if value, ok := v.get(sub[1]); ok {
+				if b, err := json.Marshal(value); err == nil {
+					buf.Write(b)
+					continue
+				}
+			}
+		}
+		str, strMissing = v.replace(str, func(value interface{}) string {
+			b, _ := json.Marshal(fmt.Sprint(value)) // cannot fail
+			return string(b[1 : len(b)-1])
+		})
+		if missing == "" {
+			missing = strMissing
+		}
+		buf.WriteString(str)
+	}
+	return buf.Bytes(), missing
+}
@@ -0,0 +1,163 @@
+// Copyright (c) 2021, Maxime Soulé
+// All rights reserved.
+//
+// This source code is licensed under the BSD-style license found in the
+// LICENSE file in the root directory of this source tree.
+
+package tdsuite
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/maxatome/go-testdeep/td"
+)
+
+var bType = reflect.TypeOf((*testing.B)(nil))
+
+// RunBenchmarks runs the benchmarks of suite "suite" using "b" as
+// base benchmark, typically as in:
+//
+//   func BenchmarkSuite(b *testing.B) {
+//     tdsuite.RunBenchmarks(b, &Suite{})
+//   }
+//
+// The benchmark methods have the form:
+//
+//   func (s *MySuite) BenchmarkXxx(b *testing.B)
+//   func (s *MySuite) BenchmarkXxx(t *td.T, b *testing.B)
+//
+// where Xxx does not start with a lowercase letter, and where "t"
+// is a *td.T instance wrapping "b". As test methods, they can return
+// a bool, an error or a tuple (bool, error) to discontinue the suite
+// and/or report an error. Benchmark methods are run in lexicographic
+// order, each in a sub-benchmark (see testing.B.Run).
+//
+// The same hooks as for Run are called: Setup, then for each
+// benchmark PreTest and PostTest, BetweenTests between two
+// benchmarks, and Destroy at the end. So expensive fixtures can be
+// shared between the tests and the benchmarks of a suite. As the
+// testing package calls a benchmark function several times to
+// determine b.N, PreTest and PostTest are called around each of these
+// calls, the time they spend being excluded from the measure.
+//
+// Test methods of "suite" are ignored, as well as benchmark methods
+// passed to Run. "config" is the same as for Run.
+//
+// RunBenchmarks returns true if all the benchmarks succeeded, false
+// otherwise.
+func RunBenchmarks(b *testing.B, suite interface{}, config ...td.ContextConfig) bool {
+	t := td.NewT(b, config...)
+
+	t.Helper()
+	if suite == nil {
+		t.Fatal("RunBenchmarks(): suite parameter cannot be nil")
+		return false // only for tests
+	}
+
+	typ := reflect.TypeOf(suite)
+
+	var methods []int
+	for i, num := 0, typ.NumMethod(); i < num; i++ {
+		m := typ.Method(i)
+		if !isPrefixed(m.Name, "Benchmark") {
+			continue
+		}
+
+		mt := m.Type
+		switch {
+		case mt.NumIn() == 2 && mt.In(1) == bType:
+			// BenchmarkXxx(*testing.B)
+		case mt.NumIn() == 3 && mt.In(1) == tType && mt.In(2) == bType:
+			// BenchmarkXxx(*td.T, *testing.B)
+		default:
+			t.Logf("RunBenchmarks(): method %T.%s skipped, only (*testing.B) or (*td.T, *testing.B) parameters allowed",
+				suite, m.Name)
+			continue
+		}
+
+		if !checkOutputs(t, "RunBenchmarks", suite, m) {
+			return false // only for tests
+		}
+
+		methods = append(methods, i)
+	}
+
+	if len(methods) == 0 {
+		t.Fatalf("RunBenchmarks(): no benchmark methods found for type %T", suite)
+		return false // only for tests
+	}
+
+	runBenchmarks(t, suite, methods)
+
+	return !t.Failed()
+}
+
+func runBenchmarks(t *td.T, suite interface{}, methods []int) {
+	t.Helper()
+
+	if !setup(t, suite) {
+		return
+	}
+	defer destroy(t, suite)
+
+	preTest := emptyPrePostTest
+	if s, ok := suite.(PreTest); ok {
+		preTest = s.PreTest
+	}
+
+	postTest := emptyPrePostTest
+	if s, ok := suite.(PostTest); ok {
+		postTest = s.PostTest
+	}
+
+	between := emptyBetweenTests
+	if s, ok := suite.(BetweenTests); ok {
+		between = s.BetweenTests
+	}
+
+	vs := reflect.ValueOf(suite)
+	typ := vs.Type()
+
+	for i, method := range methods {
+		m := typ.Method(method)
+		call := vs.Method(method).Call
+
+		cont := true
+		t.Run(m.Name, func(t *td.T) {
+			b := t.TB.(*testing.B)
+
+			if err := preTest(t, m.Name); err != nil {
+				t.Errorf("%s pre-test error: %s", m.Name, err)
+				return
+			}
+			defer func() {
+				b.StopTimer()
+				if err := postTest(t, m.Name); err != nil {
+					t.Errorf("%s post-test error: %s", m.Name, err)
+				}
+			}()
+
+			args := []reflect.Value{reflect.ValueOf(b)}
+			if m.Type.NumIn() == 3 {
+				args = []reflect.Value{reflect.ValueOf(t), args[0]}
+			}
+
+			b.ResetTimer()
+			cont = shouldContinue(t, m.Name, call(args))
+		})
+
+		if !cont {
+			t.Logf("%s required discontinuing suite benchmarks", m.Name)
+			return
+		}
+
+		if i != len(methods)-1 {
+			next := typ.Method(methods[i+1]).Name
+			if err := between(t, m.Name, next); err != nil {
+				t.Errorf("%s / %s between-tests error: %s", m.Name, next, err)
+				return
+			}
+		}
+	}
+}
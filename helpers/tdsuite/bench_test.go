@@ -0,0 +1,84 @@
+// Copyright (c) 2021, Maxime Soulé
+// All rights reserved.
+//
+// This source code is licensed under the BSD-style license found in the
+// LICENSE file in the root directory of this source tree.
+
+package tdsuite_test
+
+import (
+	"flag"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/maxatome/go-testdeep/helpers/tdsuite"
+	"github.com/maxatome/go-testdeep/td"
+)
+
+// Bench has benchmarks and all possible hooks.
+type Bench struct {
+	mu    sync.Mutex
+	seen  map[string]bool
+	calls []string
+}
+
+// rec records the first occurrence of each call, as the testing
+// package calls a benchmark function several times.
+func (s *Bench) rec(call string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.seen[call] {
+		s.seen[call] = true
+		s.calls = append(s.calls, call)
+	}
+}
+
+func (s *Bench) Setup(t *td.T) error               { s.rec("Setup"); return nil }
+func (s *Bench) PreTest(t *td.T, tn string) error  { s.rec("PreTest+" + tn); return nil }
+func (s *Bench) PostTest(t *td.T, tn string) error { s.rec("PostTest+" + tn); return nil }
+func (s *Bench) BetweenTests(t *td.T, prev, next string) error {
+	s.rec("BetweenTests+" + prev + "+" + next)
+	return nil
+}
+func (s *Bench) Destroy(t *td.T) error { s.rec("Destroy"); return nil }
+
+func (s *Bench) BenchmarkA(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		strings.Repeat("x", 10)
+	}
+	s.rec("BenchmarkA")
+}
+func (s *Bench) BenchmarkB(t *td.T, b *testing.B) bool {
+	t.Cmp(t.TB, b)
+	s.rec("BenchmarkB")
+	return false // discontinues the suite
+}
+func (s *Bench) BenchmarkC(b *testing.B)   { s.rec("BenchmarkC") }
+func (s *Bench) Benchmarking(b *testing.B) {} // not a benchmark method
+func (s *Bench) Test1(t *td.T)             { s.rec("Test1") }
+
+func TestRunBenchmarks(t *testing.T) {
+	// Run each benchmark only once
+	benchtime := flag.Lookup("test.benchtime").Value
+	defer benchtime.Set(benchtime.String()) //nolint: errcheck
+	benchtime.Set("1x")                     //nolint: errcheck
+
+	suite := Bench{seen: map[string]bool{}}
+	var ok bool
+	testing.Benchmark(func(b *testing.B) {
+		ok = tdsuite.RunBenchmarks(b, &suite)
+	})
+	td.CmpTrue(t, ok)
+	td.Cmp(t, suite.calls, []string{
+		"Setup",
+		/**/ "PreTest+BenchmarkA",
+		/**/ "BenchmarkA",
+		/**/ "PostTest+BenchmarkA",
+		"BetweenTests+BenchmarkA+BenchmarkB",
+		/**/ "PreTest+BenchmarkB",
+		/**/ "BenchmarkB",
+		/**/ "PostTest+BenchmarkB",
+		"Destroy",
+	}) // BenchmarkC never called, as BenchmarkB discontinued the suite
+}
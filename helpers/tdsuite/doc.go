@@ -59,6 +59,26 @@
 //
 // Test methods are run in lexicographic order.
 //
+// A test method can also be table-driven, accepting a test case as
+// last parameter, as in:
+//
+//   func (s *MySuite) TestXxx(t *td.T, tc Case)
+//   func (s *MySuite) TestXxx(assert, require *td.T, tc Case)
+//
+// as soon as a companion method ParamsTestXxx returns all the cases:
+//
+//   func (s *MySuite) ParamsTestXxx() []Case
+//   func (s *MySuite) ParamsTestXxx() map[string]Case
+//
+// in this case, the test method is run once per case, each in its
+// own subtest of the TestXxx subtest. For a map, cases are run in
+// keys order, each subtest being named after its key. For a slice or
+// an array, a subtest is named after the Name string field of its
+// case if any, else after the String method of its case if any, else
+// after its index. PreTest and PostTest hooks are called around each
+// case, with "TestXxx/name" as test name. Returning false or a
+// non-nil error from a case discontinues the suite, as usual.
+//
 // Very simple tests suite
 //
 // Used typically to group tests and benefit from already instanciated
@@ -125,4 +145,142 @@
 //
 // See documentation below for other possible hooks: PreTest, PostTest
 // and BetweenTests.
+//
+// Benchmarks
+//
+// RunBenchmarks runs the BenchmarkXxx methods of a suite, with the
+// same hooks as for tests, so expensive fixtures set up by Setup can
+// be shared between tests and benchmarks:
+//
+//   func (s *SuiteDB) BenchmarkGetPerson(b *testing.B) {
+//     for i := 0; i < b.N; i++ {
+//       GetPerson(s.DB, "Bob")
+//     }
+//   }
+//
+//   // BenchmarkSuiteDB is the go test benchmark entry point.
+//   func BenchmarkSuiteDB(b *testing.B) {
+//     tdsuite.RunBenchmarks(b, &SuiteDB{})
+//   }
+//
+// Tests selection
+//
+// Some environment variables allow to select the test methods to
+// run, without modifying the suite:
+//
+//   TESTDEEP_SUITE_RUN='^TestDB' go test ./...
+//   TESTDEEP_SUITE_TAGS='db,!slow' go test ./...
+//   TESTDEEP_SUITE_SHUFFLE=on go test ./...
+//
+// See EnvRun, EnvTags and EnvShuffle for details. Tags are declared
+// by the suite implementing the Tags interface. Shuffling the
+// execution order allows to catch tests depending on the previous
+// ones. The seed used being logged, a failing order can then be
+// reproduced. Finally, the suite can skip tests by implementing the
+// Skip interface.
+//
+// Fixtures
+//
+// Test methods can declare additional parameters after their *td.T
+// ones, as in:
+//
+//   func (s *MySuite) TestXxx(t *td.T, db *sql.DB, ta *tdhttp.TestAPI)
+//
+// each parameter is then resolved by the suite method providing its
+// type, called a fixture provider. A FixtureXxx provider is called for
+// each test, with the *td.T instance of the test. A SharedFixtureXxx
+// provider is called only once for the whole suite, with the *td.T
+// instance of the suite, the same value being then passed to all
+// tests:
+//
+//   func (s *MySuite) SharedFixtureDB(t *td.T) (*sql.DB, error) {
+//     return sql.Open(driver, dataSourceName)
+//   }
+//
+//   func (s *MySuite) FixtureAPI(t *td.T) *tdhttp.TestAPI {
+//     return tdhttp.NewTestAPI(t, s.mux)
+//   }
+//
+// A provider returns the fixture and optionally an error. If it
+// returns a non-nil error, the test is marked as failed and is not
+// run. Starting go1.14, a fixture implementing io.Closer is
+// automatically closed at the end of the test, or at the end of the
+// suite (so after Destroy) for a shared one. A test method using a
+// parameter type without provider is skipped.
+//
+// In a table-driven test method, fixtures parameters come before the
+// case one.
+//
+// Sub-suites
+//
+// A suite can contain other suites, run after its own tests in
+// nested subtests, so that a fixture can be shared between several
+// groups of tests. An exported field whose type has test methods is
+// a sub-suite, as well as the value returned by a SuiteXxx method
+// without parameters:
+//
+//   type SuiteDB struct {
+//     DB   *sql.DB
+//     API  *SuiteAPI  // sub-suite run in "API" subtest
+//     Repo *SuiteRepo // sub-suite run in "Repo" subtest
+//   }
+//
+//   func (s *SuiteDB) Setup(t *td.T) error {
+//     db, err := sql.Open(driver, dataSourceName)
+//     s.DB = db
+//     s.API = &SuiteAPI{DB: db}
+//     s.Repo = &SuiteRepo{DB: db}
+//     return err
+//   }
+//
+//   // SuiteMigrations returns a sub-suite run in "SuiteMigrations" subtest.
+//   func (s *SuiteDB) SuiteMigrations() interface{} {
+//     return &SuiteMigrations{DB: s.DB}
+//   }
+//
+// Sub-suites are collected after the parent Setup, so they can be
+// initialized there. Each sub-suite is then run as any suite, with
+// its own hooks, Destroy being called before the parent Destroy. nil
+// sub-suites are ignored. A failure in a sub-suite is a failure of
+// its parent. A suite can only contain sub-suites, without any test
+// method.
+//
+// Parallel tests
+//
+// Tests can also be run in parallel, each on its own copy of the
+// suite. The suite type has then to implement Parallel and Clone
+// interfaces:
+//
+//   // Parallel is called for each test before the suite is run.
+//   func (s *SuiteDB) Parallel(testName string) bool {
+//     return true // all tests are run in parallel
+//   }
+//
+//   // Clone is called after Setup, once for each parallel test.
+//   func (s *SuiteDB) Clone() interface{} {
+//     return &SuiteDB{DB: s.DB}
+//   }
+//
+// PreTest and PostTest are still called around each test, but on its
+// copy of the suite. BetweenTests is not available for parallel
+// tests. See Parallel and Clone for details.
+//
+// Timeouts and leaks
+//
+// A hanging test can be reported by implementing the Timeout
+// interface, and tests leaving goroutines behind them can be detected
+// by implementing the LeakCheck one:
+//
+//   func (s *MySuite) Timeout(testName string) time.Duration {
+//     return 5 * time.Second
+//   }
+//
+//   func (s *MySuite) LeakCheck(testName string) bool {
+//     return testName != "TestBackgroundWorker"
+//   }
+//
+// In both cases, the stacks of the involved goroutines are logged
+// with the failure. A test timing out is stopped at once and its
+// report is also immediately printed on stderr, so it is not lost if
+// "go test -timeout" kills the test binary later.
 package tdsuite
@@ -0,0 +1,140 @@
+// Copyright (c) 2021, Maxime Soulé
+// All rights reserved.
+//
+// This source code is licensed under the BSD-style license found in the
+// LICENSE file in the root directory of this source tree.
+
+package tdsuite
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+
+	"github.com/maxatome/go-testdeep/internal/types"
+	"github.com/maxatome/go-testdeep/td"
+)
+
+// fixtureProvider is a FixtureXxx or SharedFixtureXxx method of a
+// suite.
+type fixtureProvider struct {
+	name   string
+	method int
+	shared bool
+}
+
+// fixtures resolves the fixtures parameters of the test methods of a
+// suite.
+type fixtures struct {
+	t         *td.T       // suite level *td.T, used by shared fixtures
+	suite     interface{} // original suite, used by shared fixtures
+	providers map[reflect.Type]fixtureProvider
+
+	mu     sync.Mutex
+	shared map[reflect.Type]reflect.Value
+}
+
+// newFixtures collects the fixture providers of "suite". It returns
+// nil after calling t.Fatalf if a provider is not valid.
+func newFixtures(t *td.T, suite interface{}) *fixtures {
+	t.Helper()
+
+	fx := fixtures{
+		t:         t,
+		suite:     suite,
+		providers: map[reflect.Type]fixtureProvider{},
+		shared:    map[reflect.Type]reflect.Value{},
+	}
+
+	typ := reflect.TypeOf(suite)
+	for i, num := 0, typ.NumMethod(); i < num; i++ {
+		m := typ.Method(i)
+
+		shared := isPrefixed(m.Name, "SharedFixture")
+		if !shared && !isPrefixed(m.Name, "Fixture") {
+			continue
+		}
+
+		mt := m.Type
+		if mt.NumIn() != 2 || mt.In(1) != tType ||
+			(mt.NumOut() != 1 && (mt.NumOut() != 2 || mt.Out(1) != types.Error)) {
+			t.Fatalf("Run(): fixture provider %T.%s should be func(*td.T) T or func(*td.T) (T, error)",
+				suite, m.Name)
+			return nil // only for tests
+		}
+
+		if other, ok := fx.providers[mt.Out(0)]; ok {
+			t.Fatalf("Run(): %T.%s and %T.%s both provide %s fixtures",
+				suite, other.name, suite, m.Name, mt.Out(0))
+			return nil // only for tests
+		}
+		fx.providers[mt.Out(0)] = fixtureProvider{
+			name:   m.Name,
+			method: i,
+			shared: shared,
+		}
+	}
+
+	return &fx
+}
+
+// missing returns the type of the first parameter of the test method
+// type "mt", between "from" and "to" (excluded), not having a
+// fixture provider, or nil if all have one.
+func (f *fixtures) missing(mt reflect.Type, from, to int) reflect.Type {
+	for i := from; i < to; i++ {
+		if _, ok := f.providers[mt.In(i)]; !ok {
+			return mt.In(i)
+		}
+	}
+	return nil
+}
+
+// get returns the fixture of type "typ" for a test of "suite" using
+// "t". A shared fixture is created once for the whole suite, using
+// the suite level *td.T. Other fixtures are created for each test.
+func (f *fixtures) get(t *td.T, suite interface{}, typ reflect.Type) (reflect.Value, error) {
+	p := f.providers[typ]
+	if !p.shared {
+		return p.provide(t, suite)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if fixture, ok := f.shared[typ]; ok {
+		return fixture, nil
+	}
+
+	fixture, err := p.provide(f.t, f.suite)
+	if err == nil {
+		f.shared[typ] = fixture
+	}
+	return fixture, err
+}
+
+// provide calls the provider method of "suite" with "t". If the
+// returned fixture implements io.Closer, it is closed at the end of
+// "t".
+func (p fixtureProvider) provide(t *td.T, suite interface{}) (reflect.Value, error) {
+	ret := reflect.ValueOf(suite).Method(p.method).Call([]reflect.Value{reflect.ValueOf(t)})
+	if len(ret) == 2 {
+		if err, _ := ret[1].Interface().(error); err != nil { // nil error fails conversion
+			return reflect.Value{}, fmt.Errorf("%s: %s", p.name, err)
+		}
+	}
+
+	fixture := ret[0]
+	if (fixture.Kind() == reflect.Ptr || fixture.Kind() == reflect.Interface) && fixture.IsNil() {
+		return fixture, nil
+	}
+	if closer, ok := fixture.Interface().(io.Closer); ok {
+		fixtureTeardown(t, func() {
+			if err := closer.Close(); err != nil {
+				t.Errorf("%s fixture teardown error: %s", p.name, err)
+			}
+		})
+	}
+	return fixture, nil
+}
@@ -0,0 +1,18 @@
+// Copyright (c) 2021, Maxime Soulé
+// All rights reserved.
+//
+// This source code is licensed under the BSD-style license found in the
+// LICENSE file in the root directory of this source tree.
+
+// +build go1.14
+
+package tdsuite
+
+import (
+	"github.com/maxatome/go-testdeep/td"
+)
+
+// fixtureTeardown arranges "teardown" to be called at the end of "t".
+func fixtureTeardown(t *td.T, teardown func()) {
+	t.Cleanup(teardown)
+}
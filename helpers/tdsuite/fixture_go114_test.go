@@ -0,0 +1,80 @@
+// Copyright (c) 2021, Maxime Soulé
+// All rights reserved.
+//
+// This source code is licensed under the BSD-style license found in the
+// LICENSE file in the root directory of this source tree.
+
+// +build go1.14
+
+package tdsuite_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/maxatome/go-testdeep/helpers/tdsuite"
+	"github.com/maxatome/go-testdeep/internal/test"
+	"github.com/maxatome/go-testdeep/td"
+)
+
+type fixCloser struct {
+	name string
+	rec  func(...string)
+	err  error
+}
+
+func (c *fixCloser) Close() error {
+	c.rec(c.name)
+	return c.err
+}
+
+type fixSharedCloser struct{ fixCloser }
+
+// FixClose has fixtures implementing io.Closer.
+type FixClose struct {
+	base
+	closeErr error
+}
+
+func (f *FixClose) FixtureTest(t *td.T) *fixCloser {
+	return &fixCloser{name: "test", rec: f.rec}
+}
+func (f *FixClose) SharedFixtureSuite(t *td.T) *fixSharedCloser {
+	return &fixSharedCloser{fixCloser{name: "suite", rec: f.rec, err: f.closeErr}}
+}
+
+func (f *FixClose) PostTest(t *td.T, tn string) error { f.rec(tn); return nil }
+func (f *FixClose) Destroy(t *td.T) error             { f.rec(); return nil }
+
+func (f *FixClose) Test1(t *td.T, c *fixCloser, s *fixSharedCloser) { f.rec() }
+func (f *FixClose) Test2(t *td.T, c *fixCloser, s *fixSharedCloser) { f.rec() }
+
+func TestRunFixturesTeardown(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		suite := FixClose{}
+		t.Run("suite", func(t *testing.T) {
+			td.CmpTrue(t, tdsuite.Run(t, &suite))
+		})
+		td.Cmp(t, suite.calls, []string{
+			"Test1",
+			"PostTest+Test1",
+			"Close+test",
+			//
+			"Test2",
+			"PostTest+Test2",
+			"Close+test",
+			//
+			"Destroy",
+			"Close+suite", // shared fixture closed once
+		})
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		suite := FixClose{closeErr: errors.New("oops")}
+		tb := test.NewTestingTB("TestFixClose")
+		td.CmpTrue(t, tdsuite.Run(tb, &suite))
+		tb.RunCleanup()
+		td.CmpTrue(t, tb.Failed())
+		td.Cmp(t, tb.LastMessage(), "SharedFixtureSuite fixture teardown error: oops")
+	})
+}
@@ -0,0 +1,17 @@
+// Copyright (c) 2021, Maxime Soulé
+// All rights reserved.
+//
+// This source code is licensed under the BSD-style license found in the
+// LICENSE file in the root directory of this source tree.
+
+// +build !go1.14
+
+package tdsuite
+
+import (
+	"github.com/maxatome/go-testdeep/td"
+)
+
+// fixtureTeardown does nothing as testing.TB has no Cleanup method
+// before go1.14. Fixtures have to be closed by the tests themselves.
+func fixtureTeardown(t *td.T, teardown func()) {}
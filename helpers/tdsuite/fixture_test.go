@@ -0,0 +1,122 @@
+// Copyright (c) 2021, Maxime Soulé
+// All rights reserved.
+//
+// This source code is licensed under the BSD-style license found in the
+// LICENSE file in the root directory of this source tree.
+
+package tdsuite_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/maxatome/go-testdeep/helpers/tdsuite"
+	"github.com/maxatome/go-testdeep/internal/test"
+	"github.com/maxatome/go-testdeep/td"
+)
+
+type fixCounter struct{ n int }
+
+type fixDB struct{ name string }
+
+// Fix has tests using fixtures.
+type Fix struct {
+	base
+	counters int
+}
+
+func (f *Fix) FixtureCounter(t *td.T) *fixCounter {
+	f.rec()
+	f.counters++
+	return &fixCounter{n: f.counters}
+}
+func (f *Fix) SharedFixtureDB(t *td.T) (*fixDB, error) {
+	f.rec()
+	return &fixDB{name: "test"}, nil
+}
+func (f *Fix) FixtureContext(t *td.T) context.Context { return context.Background() }
+
+func (f *Fix) PreTest(t *td.T, tn string) error { f.rec(tn); return nil }
+
+func (f *Fix) Test1(t *td.T, c *fixCounter, db *fixDB) {
+	f.rec(fmt.Sprint(c.n), db.name)
+}
+func (f *Fix) Test2(assert, require *td.T, db *fixDB, ctx context.Context) {
+	f.rec(db.name, fmt.Sprint(ctx != nil))
+}
+func (f *Fix) ParamsTest3() []int { return []int{10, 20} }
+func (f *Fix) Test3(t *td.T, c *fixCounter, n int) {
+	f.rec(fmt.Sprint(c.n), fmt.Sprint(n))
+}
+
+// FixErr has a fixture provider returning an error.
+type FixErr struct{ base }
+
+func (f *FixErr) FixtureInt(t *td.T) (int, error) { return 0, errors.New("boom") }
+func (f *FixErr) Test1(t *td.T, n int)            { f.rec() }
+func (f *FixErr) Test2(t *td.T)                   { f.rec() }
+
+// FixBad has an invalid fixture provider.
+type FixBad struct{}
+
+func (f FixBad) FixtureInt() int { return 0 }
+func (f FixBad) Test(t *td.T)    {}
+
+// FixDup has two fixture providers for the same type.
+type FixDup struct{}
+
+func (f FixDup) FixtureA(t *td.T) int       { return 0 }
+func (f FixDup) SharedFixtureB(t *td.T) int { return 0 }
+func (f FixDup) Test(t *td.T)               {}
+
+func TestRunFixtures(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		suite := Fix{}
+		td.CmpTrue(t, tdsuite.Run(t, &suite))
+		td.Cmp(t, suite.calls, []string{
+			"PreTest+Test1",
+			"FixtureCounter",
+			"SharedFixtureDB",
+			"Test1+1+test",
+			//
+			"PreTest+Test2",
+			"Test2+test+true",
+			//
+			"PreTest+Test3/#0",
+			"FixtureCounter",
+			"Test3+2+10",
+			"PreTest+Test3/#1",
+			"FixtureCounter",
+			"Test3+3+20",
+		})
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		suite := FixErr{}
+		tb := test.NewTestingTB("TestFixErr")
+		td.CmpFalse(t, tdsuite.Run(tb, &suite))
+		td.CmpFalse(t, tb.IsFatal)
+		td.Cmp(t, suite.calls, []string{"Test2"})
+		td.Cmp(t, tb.Messages, []string{
+			"++++ Test1",
+			"Test1 fixture error: FixtureInt: boom",
+			"++++ Test2",
+		})
+	})
+
+	t.Run("ErrBad", func(t *testing.T) {
+		tb := test.NewTestingTB("TestFixBad")
+		tdsuite.Run(tb, FixBad{})
+		td.CmpTrue(t, tb.IsFatal)
+		td.Cmp(t, tb.LastMessage(), "Run(): fixture provider tdsuite_test.FixBad.FixtureInt should be func(*td.T) T or func(*td.T) (T, error)")
+	})
+
+	t.Run("ErrDup", func(t *testing.T) {
+		tb := test.NewTestingTB("TestFixDup")
+		tdsuite.Run(tb, FixDup{})
+		td.CmpTrue(t, tb.IsFatal)
+		td.Cmp(t, tb.LastMessage(), "Run(): tdsuite_test.FixDup.FixtureA and tdsuite_test.FixDup.SharedFixtureB both provide int fixtures")
+	})
+}
@@ -0,0 +1,211 @@
+// Copyright (c) 2021, Maxime Soulé
+// All rights reserved.
+//
+// This source code is licensed under the BSD-style license found in the
+// LICENSE file in the root directory of this source tree.
+
+package tdsuite
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/maxatome/go-testdeep/td"
+)
+
+// leakGracePeriod is the time left to the goroutines started by a
+// test to end, before being reported as leaked.
+const leakGracePeriod = 500 * time.Millisecond
+
+// timeoutOutput is where timeouts are reported as soon as they occur.
+// It is a variable to be overridden in tests.
+var timeoutOutput io.Writer = os.Stderr
+
+// Timeout is an interface a tests suite can implement to limit the
+// duration of its tests. Timeout method is called before each test
+// is run, and returns the maximum duration of the test "testName", 0
+// meaning no limit.
+//
+// When a timeout is set, the test method is called in its own
+// goroutine. As soon as the timeout is elapsed, the stacks of all the
+// goroutines are printed on stderr, so they are not lost if "go test
+// -timeout" kills the test binary later, the test is marked as
+// failed, then stopped: PostTest is called as usual and the suite is
+// discontinued. As a test method cannot be stopped, it continues to
+// run in the background, but must not use its *td.T instances
+// anymore, the testing package panicking when a test logs after its
+// end.
+//
+// FailNow, SkipNow (and so Fatal, Skip, etc.) and panics occurring
+// in the test method goroutine are propagated to the test goroutine.
+//
+// Only the test method itself is concerned, the hooks and the
+// fixture providers are not.
+type Timeout interface {
+	Timeout(testName string) time.Duration
+}
+
+// LeakCheck is an interface a tests suite can implement to detect
+// goroutines leaked by its tests. LeakCheck method is called before
+// each test is run, and returns true if the goroutines started by the
+// test "testName" have to be checked.
+//
+// In this case, the goroutines are listed just before and just after
+// the test method call. The goroutines started by the test method
+// and still running 500ms after it returned are considered leaked:
+// the test is marked as failed and their stacks are logged.
+//
+// As the goroutines of the whole program are listed, the check is not
+// reliable for tests running in parallel with other ones.
+type LeakCheck interface {
+	LeakCheck(testName string) bool
+}
+
+func emptyTimeout(testName string) time.Duration { return 0 }
+func emptyLeakCheck(testName string) bool        { return false }
+
+// callTest calls the test method "call" with "args", using "t". If
+// "timeout" is positive, the test fails if the call does not return
+// before. If "checkLeaks" is true, the test fails if the call leaves
+// goroutines running. It returns false if the test required
+// discontinuing the suite or timed out.
+func callTest(t *td.T, testName string,
+	call func([]reflect.Value) []reflect.Value, args []reflect.Value,
+	timeout time.Duration, checkLeaks bool,
+) bool {
+	t.Helper()
+
+	var before map[string]string
+	if checkLeaks {
+		before = goroutines()
+	}
+
+	ret, ok := callWatched(t, testName, call, args, timeout)
+	if !ok {
+		return false
+	}
+
+	cont := shouldContinue(t, testName, ret)
+
+	if checkLeaks {
+		deadline := time.Now().Add(leakGracePeriod)
+		for {
+			leaked := leakedGoroutines(before)
+			if len(leaked) == 0 {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Errorf("%s leaked %d goroutine(s):\n\n%s",
+					testName, len(leaked), strings.Join(leaked, "\n\n"))
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	return cont
+}
+
+// callWatched calls "call" with "args". If "timeout" is positive,
+// the call is done in a new goroutine and callWatched returns false
+// as soon as "timeout" is elapsed, after having reported the timeout
+// on timeoutOutput and as a test failure. Otherwise a panic or a
+// runtime.Goexit (as done by FailNow or SkipNow) occurring during
+// the call is propagated to the current goroutine.
+func callWatched(t *td.T, testName string,
+	call func([]reflect.Value) []reflect.Value, args []reflect.Value,
+	timeout time.Duration,
+) ([]reflect.Value, bool) {
+	if timeout <= 0 {
+		return call(args), true
+	}
+
+	var (
+		ret      []reflect.Value
+		returned bool
+		panicked *interface{}
+	)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer func() {
+			if !returned {
+				if r := recover(); r != nil {
+					panicked = &r
+				}
+			}
+		}()
+		ret = call(args)
+		returned = true
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+	case <-timer.C:
+		report := fmt.Sprintf("%s timed out after %s, goroutines dump:\n\n%s",
+			testName, timeout, allStacks())
+		fmt.Fprintln(timeoutOutput, report) //nolint: errcheck
+		t.Error(report)
+		return nil, false
+	}
+
+	switch {
+	case returned:
+		return ret, true
+	case panicked != nil:
+		panic(*panicked) // re-panic in the test goroutine
+	case t.Skipped():
+		t.SkipNow()
+	default: // FailNow or runtime.Goexit
+		t.FailNow()
+	}
+	return nil, false // never reached
+}
+
+// allStacks returns the stacks of all the goroutines.
+func allStacks() []byte {
+	buf := make([]byte, 1<<16)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return buf[:n]
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+// goroutines returns the stacks of all the goroutines, indexed by
+// goroutine id.
+func goroutines() map[string]string {
+	stacks := map[string]string{}
+	for _, stack := range strings.Split(string(allStacks()), "\n\n") {
+		// goroutine 42 [running]:
+		fields := strings.Fields(stack)
+		if len(fields) > 1 && fields[0] == "goroutine" {
+			stacks[fields[1]] = strings.TrimSpace(stack)
+		}
+	}
+	return stacks
+}
+
+// leakedGoroutines returns the sorted stacks of the goroutines not
+// in "before".
+func leakedGoroutines(before map[string]string) []string {
+	var leaked []string
+	for id, stack := range goroutines() {
+		if _, ok := before[id]; !ok {
+			leaked = append(leaked, stack)
+		}
+	}
+	sort.Strings(leaked)
+	return leaked
+}
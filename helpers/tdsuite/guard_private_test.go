@@ -0,0 +1,62 @@
+// Copyright (c) 2021, Maxime Soulé
+// All rights reserved.
+//
+// This source code is licensed under the BSD-style license found in the
+// LICENSE file in the root directory of this source tree.
+
+package tdsuite
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maxatome/go-testdeep/internal/test"
+	"github.com/maxatome/go-testdeep/td"
+)
+
+func TestCallWatched(t *testing.T) {
+	origOutput := timeoutOutput
+	defer func() { timeoutOutput = origOutput }()
+
+	var buf bytes.Buffer
+	timeoutOutput = &buf
+
+	ttb := test.NewTestingTB(t.Name())
+	hang := make(chan struct{})
+	defer close(hang)
+
+	start := time.Now()
+	ret, ok := callWatched(td.NewT(ttb), "TestHang",
+		func([]reflect.Value) []reflect.Value {
+			<-hang
+			return nil
+		}, nil, 10*time.Millisecond)
+
+	test.IsFalse(t, ok)
+	test.IsTrue(t, ret == nil)
+	test.IsTrue(t, time.Since(start) < time.Second, "stopped at the deadline")
+	test.IsTrue(t, ttb.HasFailed)
+
+	// Reported on timeoutOutput as soon as the deadline is passed
+	test.IsTrue(t, strings.HasPrefix(buf.String(),
+		"TestHang timed out after 10ms, goroutines dump:\n\ngoroutine "))
+	test.IsTrue(t, strings.Contains(buf.String(), "tdsuite.TestCallWatched.func"))
+	test.EqualStr(t, ttb.LastMessage(), strings.TrimSuffix(buf.String(), "\n"))
+
+	// Returns before the deadline
+	buf.Reset()
+	ttb = test.NewTestingTB(t.Name())
+	ret, ok = callWatched(td.NewT(ttb), "TestFast",
+		func(args []reflect.Value) []reflect.Value { return args },
+		[]reflect.Value{reflect.ValueOf(42)}, time.Hour)
+
+	test.IsTrue(t, ok)
+	if test.EqualInt(t, len(ret), 1) {
+		test.EqualInt(t, int(ret[0].Int()), 42)
+	}
+	test.IsFalse(t, ttb.HasFailed)
+	test.EqualInt(t, buf.Len(), 0)
+}
@@ -0,0 +1,159 @@
+// Copyright (c) 2021, Maxime Soulé
+// All rights reserved.
+//
+// This source code is licensed under the BSD-style license found in the
+// LICENSE file in the root directory of this source tree.
+
+package tdsuite_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/maxatome/go-testdeep/helpers/tdsuite"
+	"github.com/maxatome/go-testdeep/helpers/tdutil"
+	"github.com/maxatome/go-testdeep/internal/test"
+	"github.com/maxatome/go-testdeep/td"
+)
+
+// Guard has tests that hang, panic or leak goroutines.
+type Guard struct {
+	base
+	hangTimeout time.Duration
+	hang        chan struct{}
+	leak        chan struct{}
+	panic       bool
+}
+
+func (g *Guard) Timeout(tn string) time.Duration {
+	switch tn {
+	case "TestNoTimeout":
+		return 0
+	case "TestHang":
+		if g.hangTimeout > 0 {
+			return g.hangTimeout
+		}
+	}
+	return time.Hour
+}
+func (g *Guard) LeakCheck(tn string) bool { return tn != "TestNoLeakCheck" }
+
+func (g *Guard) PostTest(t *td.T, tn string) error {
+	if tn == "TestHang" {
+		g.rec()
+	}
+	return nil
+}
+
+func (g *Guard) TestFast(t *td.T) { g.rec() }
+func (g *Guard) TestHang(assert, require *td.T) {
+	if g.panic {
+		panic("boom")
+	}
+	<-g.hang
+	g.rec()
+	assert.Log("still running")
+}
+func (g *Guard) TestLeak(t *td.T) {
+	g.rec()
+	go func() { <-g.leak }()
+}
+func (g *Guard) TestNoLeak(t *td.T) {
+	g.rec()
+	done := make(chan struct{})
+	go func() { close(done) }()
+	<-done
+}
+func (g *Guard) TestNoLeakCheck(t *td.T) {
+	g.rec()
+	go func() { <-g.leak }()
+}
+func (g *Guard) TestNoTimeout(t *td.T) { g.rec() }
+
+var (
+	_ tdsuite.Timeout   = (*Guard)(nil)
+	_ tdsuite.LeakCheck = (*Guard)(nil)
+)
+
+// GuardFatal has tests calling FailNow and Skip under a timeout.
+type GuardFatal struct{ base }
+
+func (g *GuardFatal) Timeout(tn string) time.Duration { return time.Hour }
+
+func (g *GuardFatal) Test1(assert, require *td.T) {
+	g.rec()
+	require.FailNow()
+	g.rec("after")
+}
+func (g *GuardFatal) Test2(t *td.T) {
+	g.rec()
+	t.Skip("skipped")
+	g.rec("after")
+}
+func (g *GuardFatal) Test3(t *td.T) { g.rec() }
+
+func TestRunGuard(t *testing.T) {
+	t.Run("Timeout", func(t *testing.T) {
+		tb := test.NewTestingTB("TestGuard")
+		suite := Guard{
+			hangTimeout: 10 * time.Millisecond,
+			hang:        make(chan struct{}), // hangs forever
+		}
+
+		td.CmpFalse(t, tdsuite.Run(tb, &suite))
+		// TestHang is stopped at its timeout, then PostTest is called
+		// and the suite is discontinued
+		td.Cmp(t, suite.calls, []string{"TestFast", "PostTest"})
+		if td.Cmp(t, tb.Messages, td.Len(4)) {
+			td.Cmp(t, tb.Messages[:2], []string{"++++ TestFast", "++++ TestHang"})
+			td.Cmp(t, tb.Messages[2], td.All(
+				td.HasPrefix("TestHang timed out after 10ms, goroutines dump:\n\ngoroutine "),
+				td.Contains("tdsuite_test.(*Guard).TestHang("),
+			))
+			td.Cmp(t, tb.Messages[3], "TestHang required discontinuing suite tests")
+		}
+	})
+
+	t.Run("FailNow", func(t *testing.T) {
+		suite := GuardFatal{}
+		tb := tdutil.NewTB("TestGuardFatal")
+		td.CmpFalse(t, tb.Start(func(tb *tdutil.TB) {
+			tdsuite.Run(tb, &suite)
+		}))
+		// FailNow and Skip stop the test, but not the suite
+		td.Cmp(t, suite.calls, []string{"Test1", "Test2", "Test3"})
+		td.CmpTrue(t, tb.Sub("Test1").IsFatal())
+		td.CmpEmpty(t, tb.Sub("Test1").Messages()) // no timeout reported
+		td.CmpTrue(t, tb.Sub("Test2").Skipped())
+		td.CmpFalse(t, tb.Sub("Test2").Failed())
+		td.CmpFalse(t, tb.Sub("Test3").Failed())
+	})
+
+	t.Run("Panic", func(t *testing.T) {
+		suite := Guard{panic: true}
+		tb := test.NewTestingTB("TestGuard")
+		test.CheckPanic(t, func() { tdsuite.Run(tb, &suite) }, "boom")
+	})
+
+	t.Run("Leak", func(t *testing.T) {
+		suite := Guard{
+			hang: make(chan struct{}),
+			leak: make(chan struct{}),
+		}
+		close(suite.hang) // TestHang does not hang
+		defer close(suite.leak)
+
+		tb := test.NewTestingTB("TestGuard")
+		td.CmpFalse(t, tdsuite.Run(tb, &suite))
+		td.Cmp(t, suite.calls, []string{
+			"TestFast", "TestHang", "PostTest",
+			"TestLeak", "TestNoLeak", "TestNoLeakCheck", "TestNoTimeout",
+		})
+		if td.Cmp(t, tb.Messages, td.Len(8)) {
+			td.Cmp(t, tb.Messages[4], td.All(
+				td.HasPrefix("TestLeak leaked 1 goroutine(s):\n\ngoroutine "),
+				td.Contains("tdsuite_test.(*Guard).TestLeak.func1("),
+			))
+		}
+	})
+}
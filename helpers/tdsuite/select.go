@@ -0,0 +1,148 @@
+// Copyright (c) 2021, Maxime Soulé
+// All rights reserved.
+//
+// This source code is licensed under the BSD-style license found in the
+// LICENSE file in the root directory of this source tree.
+
+package tdsuite
+
+import (
+	"math/rand"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/maxatome/go-testdeep/td"
+)
+
+const (
+	// EnvRun is the name of the environment variable containing a
+	// regexp. If set, only the test methods whose name matches it are
+	// run, as go test -run flag does for test functions.
+	EnvRun = "TESTDEEP_SUITE_RUN"
+	// EnvTags is the name of the environment variable containing a
+	// comma separated list of tags (see Tags). If set, only the test
+	// methods having at least one of these tags are run. A tag
+	// prefixed by "!" excludes the test methods having it. If the list
+	// only contains excluded tags, all other test methods are run.
+	EnvTags = "TESTDEEP_SUITE_TAGS"
+	// EnvShuffle is the name of the environment variable enabling
+	// the shuffling of the test methods execution order. If "on", the
+	// order is shuffled using a random seed. If it is an integer, the
+	// order is shuffled using it as seed, typically to reproduce a
+	// previous run. The seed is logged in both cases.
+	EnvShuffle = "TESTDEEP_SUITE_SHUFFLE"
+)
+
+// Tags is an interface a tests suite can implement to tag its test
+// methods. Tags method returns the tags of each test method, indexed
+// by method name. Tags are then used to select the tests to run
+// using EnvTags environment variable.
+type Tags interface {
+	Tags() map[string][]string
+}
+
+// Skip is an interface a tests suite can implement to skip some
+// tests. Skip method is called before each test is run, in the same
+// subtest as the test itself, before PreTest. If it returns a
+// non-empty reason, the test is skipped (see testing.T.Skip) and
+// neither PreTest, the test itself nor PostTest are called.
+type Skip interface {
+	Skip(testName string) (reason string)
+}
+
+func emptySkip(testName string) string { return "" }
+
+// selectTests returns the test methods among "methods" of "suite"
+// selected by EnvRun and EnvTags environment variables, in
+// lexicographic order, or shuffled if EnvShuffle environment
+// variable is set. It returns false after calling t.Fatalf if an
+// environment variable is not valid.
+func selectTests(t *td.T, suite interface{}, methods []int) ([]int, bool) {
+	t.Helper()
+
+	typ := reflect.TypeOf(suite)
+
+	var run *regexp.Regexp
+	if env := os.Getenv(EnvRun); env != "" {
+		var err error
+		run, err = regexp.Compile(env)
+		if err != nil {
+			t.Fatalf("Run(): invalid %s regexp: %s", EnvRun, err)
+			return nil, false // only for tests
+		}
+	}
+
+	var include, exclude map[string]bool
+	if env := os.Getenv(EnvTags); env != "" {
+		include, exclude = map[string]bool{}, map[string]bool{}
+		for _, tag := range strings.Split(env, ",") {
+			tag = strings.TrimSpace(tag)
+			if strings.HasPrefix(tag, "!") {
+				exclude[tag[1:]] = true
+			} else if tag != "" {
+				include[tag] = true
+			}
+		}
+	}
+
+	var tags map[string][]string
+	if s, ok := suite.(Tags); ok {
+		tags = s.Tags()
+	}
+
+	selected := make([]int, 0, len(methods))
+	for _, method := range methods {
+		name := typ.Method(method).Name
+		if run != nil && !run.MatchString(name) {
+			continue
+		}
+		if include != nil && !matchTags(tags[name], include, exclude) {
+			continue
+		}
+		selected = append(selected, method)
+	}
+
+	switch env := os.Getenv(EnvShuffle); env {
+	case "", "off":
+	default:
+		seed := time.Now().UnixNano()
+		if env != "on" {
+			var err error
+			seed, err = strconv.ParseInt(env, 10, 64)
+			if err != nil {
+				t.Fatalf(`Run(): invalid %s value %q, only "on", "off" or an integer seed allowed`,
+					EnvShuffle, env)
+				return nil, false // only for tests
+			}
+		}
+		t.Logf("Run(): %T tests shuffled using seed %d", suite, seed)
+
+		// Fisher-Yates shuffle
+		r := rand.New(rand.NewSource(seed))
+		for i := len(selected) - 1; i > 0; i-- {
+			j := r.Intn(i + 1)
+			selected[i], selected[j] = selected[j], selected[i]
+		}
+	}
+
+	return selected, true
+}
+
+// matchTags returns true if "tags" contains at least one tag of
+// "include", or if "include" is empty, and no tags of "exclude".
+func matchTags(tags []string, include, exclude map[string]bool) bool {
+	found := len(include) == 0
+	for _, tag := range tags {
+		if exclude[tag] {
+			return false
+		}
+		if include[tag] {
+			found = true
+		}
+	}
+	return found
+}
@@ -0,0 +1,151 @@
+// Copyright (c) 2021, Maxime Soulé
+// All rights reserved.
+//
+// This source code is licensed under the BSD-style license found in the
+// LICENSE file in the root directory of this source tree.
+
+package tdsuite_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/maxatome/go-testdeep/helpers/tdsuite"
+	"github.com/maxatome/go-testdeep/internal/test"
+	"github.com/maxatome/go-testdeep/td"
+)
+
+// Sel has tagged tests.
+type Sel struct{ base }
+
+func (s *Sel) Tags() map[string][]string {
+	return map[string][]string{
+		"TestDB":   {"db", "slow"},
+		"TestAPI":  {"api"},
+		"TestSlow": {"slow"},
+	}
+}
+func (s *Sel) Skip(tn string) string {
+	if tn == "TestSkipped" {
+		return "always skipped"
+	}
+	return ""
+}
+func (s *Sel) PreTest(t *td.T, tn string) error { s.rec(tn); return nil }
+
+func (s *Sel) TestAPI(t *td.T)                   { s.rec() }
+func (s *Sel) TestDB(t *td.T)                    { s.rec() }
+func (s *Sel) TestSkipped(assert, require *td.T) { s.rec() }
+func (s *Sel) TestSlow(t *td.T)                  { s.rec() }
+func (s *Sel) TestUntagged(t *td.T)              { s.rec() }
+
+var (
+	_ tdsuite.Tags = (*Sel)(nil)
+	_ tdsuite.Skip = (*Sel)(nil)
+)
+
+// setenv sets the environment variable "name" to "value" and returns
+// a function restoring its previous state.
+func setenv(name, value string) func() {
+	prev, set := os.LookupEnv(name)
+	os.Setenv(name, value)
+	return func() {
+		if set {
+			os.Setenv(name, prev)
+		} else {
+			os.Unsetenv(name)
+		}
+	}
+}
+
+func TestRunSelect(t *testing.T) {
+	tests := calls(t, nil)
+	td.Cmp(t, tests, []string{"TestAPI", "TestDB", "TestSlow", "TestUntagged"})
+
+	t.Run("Run", func(t *testing.T) {
+		defer setenv(tdsuite.EnvRun, "^Test(API|Slow)$")()
+		td.Cmp(t, calls(t, nil), []string{"TestAPI", "TestSlow"})
+	})
+
+	t.Run("Tags", func(t *testing.T) {
+		defer setenv(tdsuite.EnvTags, "slow")()
+		td.Cmp(t, calls(t, nil), []string{"TestDB", "TestSlow"})
+	})
+
+	t.Run("Tags & excluded tags", func(t *testing.T) {
+		defer setenv(tdsuite.EnvTags, "slow, api, !db")()
+		td.Cmp(t, calls(t, nil), []string{"TestAPI", "TestSlow"})
+	})
+
+	t.Run("Only excluded tags", func(t *testing.T) {
+		defer setenv(tdsuite.EnvTags, "!slow")()
+		td.Cmp(t, calls(t, nil), []string{"TestAPI", "TestUntagged"})
+	})
+
+	t.Run("Run & tags", func(t *testing.T) {
+		defer setenv(tdsuite.EnvRun, "DB|API")()
+		defer setenv(tdsuite.EnvTags, "slow")()
+		td.Cmp(t, calls(t, nil), []string{"TestDB"})
+	})
+
+	t.Run("Shuffle", func(t *testing.T) {
+		defer setenv(tdsuite.EnvShuffle, "42")()
+		tb := test.NewTestingTB("TestSel")
+		shuffled := calls(t, tb)
+		td.Cmp(t, shuffled, td.Bag(td.Flatten(tests)))
+		td.Cmp(t, tb.Messages[0], "Run(): *tdsuite_test.Sel tests shuffled using seed 42")
+
+		// Same seed, same order
+		td.Cmp(t, calls(t, nil), shuffled)
+
+		defer setenv(tdsuite.EnvShuffle, "on")()
+		tb = test.NewTestingTB("TestSel")
+		td.Cmp(t, calls(t, tb), td.Bag(td.Flatten(tests)))
+		td.Cmp(t, tb.Messages[0], td.Re(`^Run\(\): \*tdsuite_test\.Sel tests shuffled using seed -?\d+\z`))
+
+		defer setenv(tdsuite.EnvShuffle, "off")()
+		td.Cmp(t, calls(t, nil), tests)
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		func() {
+			defer setenv(tdsuite.EnvRun, "(")()
+			tb := test.NewTestingTB("TestSel")
+			tdsuite.Run(tb, &Sel{})
+			td.CmpTrue(t, tb.IsFatal)
+			td.Cmp(t, tb.LastMessage(), td.HasPrefix("Run(): invalid TESTDEEP_SUITE_RUN regexp: "))
+		}()
+
+		func() {
+			defer setenv(tdsuite.EnvShuffle, "yes")()
+			tb := test.NewTestingTB("TestSel")
+			tdsuite.Run(tb, &Sel{})
+			td.CmpTrue(t, tb.IsFatal)
+			td.Cmp(t, tb.LastMessage(), `Run(): invalid TESTDEEP_SUITE_SHUFFLE value "yes", only "on", "off" or an integer seed allowed`)
+		}()
+	})
+}
+
+// calls runs Sel suite using "tb", or a new *testing.T if nil, and
+// returns the tests run, checking the skipped one is not run.
+func calls(t *testing.T, tb testing.TB) []string {
+	t.Helper()
+
+	suite := Sel{}
+	if tb == nil {
+		t.Run("suite", func(t *testing.T) {
+			td.CmpTrue(t, tdsuite.Run(t, &suite))
+		})
+	} else {
+		td.CmpTrue(t, tdsuite.Run(tb, &suite))
+	}
+
+	var tests []string
+	for _, c := range suite.calls {
+		if len(c) > 8 && c[:8] == "PreTest+" {
+			tests = append(tests, c[8:])
+		}
+	}
+	td.CmpNot(t, suite.calls, td.Contains("TestSkipped"))
+	return tests
+}
@@ -9,6 +9,7 @@ package tdsuite
 import (
 	"fmt"
 	"reflect"
+	"sort"
 	"strings"
 	"testing"
 	"unicode"
@@ -68,19 +69,57 @@ type Destroy interface {
 	Destroy(t *td.T) error
 }
 
+// Parallel is an interface a tests suite can implement to run some
+// or all of its tests in parallel. Parallel method is called once
+// for each test before the suite is run, and returns true if the
+// test "testName" has to run in parallel. To run all the tests in
+// parallel, simply return true whatever "testName" is.
+//
+// A tests suite implementing Parallel must also implement Clone, as
+// each parallel test runs on its own copy of the suite. The hooks
+// PreTest and PostTest are called on this copy, wrapping the test as
+// usual. On the other hand, BetweenTests is never called before nor
+// after a parallel test, as there is no order between them.
+//
+// All sequential tests are run first, then all parallel tests are
+// run together in a "parallel" subtest, so that Destroy is called
+// only once they all ended. Discontinuing the suite is not possible
+// from a parallel test: a returned false boolean is ignored, but a
+// returned non-nil error still marks the test as failed.
+type Parallel interface {
+	Parallel(testName string) bool
+}
+
+// Clone is an interface a tests suite has to implement as soon as it
+// has parallel tests (see Parallel). Clone method is called after
+// Setup, once for each parallel test, and returns a fresh copy of the
+// suite, having the same type as the suite. This copy is then used
+// to call PreTest, the test itself and PostTest. Data shared by
+// parallel tests, as set by Setup, can be copied as is, but data
+// modified by a test should not.
+type Clone interface {
+	Clone() interface{}
+}
+
 func emptyPrePostTest(t *td.T, testName string) error    { return nil }
 func emptyBetweenTests(t *td.T, prev, next string) error { return nil }
 
 // isTest returns true if "name" is a valid test name.
-// Derived from go sources in cmd/go/internal/load/test.go.
 func isTest(name string) bool {
-	if !strings.HasPrefix(name, "Test") {
+	return isPrefixed(name, "Test")
+}
+
+// isPrefixed returns true if "name" is "prefix" optionally followed
+// by a string not starting with a lowercase letter.
+// Derived from go sources in cmd/go/internal/load/test.go.
+func isPrefixed(name, prefix string) bool {
+	if !strings.HasPrefix(name, prefix) {
 		return false
 	}
-	if len(name) == 4 { // "Test" is ok
+	if len(name) == len(prefix) { // "Test" is ok
 		return true
 	}
-	rune, _ := utf8.DecodeRuneInString(name[4:])
+	rune, _ := utf8.DecodeRuneInString(name[len(prefix):])
 	return !unicode.IsLower(rune)
 }
 
@@ -151,9 +190,55 @@ func Run(tb testing.TB, suite interface{}, config ...td.ContextConfig) bool {
 		return false // only for tests
 	}
 
+	runSuite(t, suite)
+
+	return !t.Failed()
+}
+
+// checkOutputs checks the output parameters of the test or benchmark
+// method "m" of "suite". It returns false after calling t.Fatalf if
+// they are not valid, "fn" being the name of the calling function.
+func checkOutputs(t *td.T, fn string, suite interface{}, m reflect.Method) bool {
+	t.Helper()
+
+	mt := m.Type
+	switch mt.NumOut() {
+	case 0:
+	case 1:
+		switch mt.Out(0) {
+		case types.Bool, types.Error:
+		default:
+			t.Fatalf("%s(): method %T.%s returns %s value. Only bool or error are allowed",
+				fn, suite, m.Name, mt.Out(0))
+			return false // only for tests
+		}
+	case 2:
+		if mt.Out(0) != types.Bool || mt.Out(1) != types.Error {
+			t.Fatalf("%s(): method %T.%s returns (%s, %s) values. Only (bool, error) is allowed",
+				fn, suite, m.Name, mt.Out(0), mt.Out(1))
+			return false // only for tests
+		}
+	default:
+		t.Fatalf("%s(): method %T.%s returns %d values. Only 0, 1 (bool or error) or 2 (bool, error) values are allowed",
+			fn, suite, m.Name, mt.NumOut())
+		return false // only for tests
+	}
+	return true
+}
+
+// runSuite checks then runs the tests suite "suite".
+func runSuite(t *td.T, suite interface{}) {
+	t.Helper()
+
 	typ := reflect.TypeOf(suite)
 
+	fx := newFixtures(t, suite)
+	if fx == nil {
+		return // only for tests
+	}
+
 	var methods []int
+	params := map[int]int{}
 	for i, num := 0, typ.NumMethod(); i < num; i++ {
 		m := typ.Method(i)
 
@@ -166,8 +251,36 @@ func Run(tb testing.TB, suite interface{}, config ...td.ContextConfig) bool {
 				continue
 			}
 
+			// TestXxx(…, C) with a ParamsTestXxx() cases provider
+			numIn := mt.NumIn()
+			if numIn > 2 && mt.In(numIn-1) != tType {
+				if p, ok := typ.MethodByName("Params" + m.Name); ok {
+					if !isParamsProvider(p.Type, mt.In(numIn-1)) {
+						t.Logf("Run(): method %T.%s skipped, %s method should return []%s or map[string]%s",
+							suite, m.Name, p.Name, mt.In(numIn-1), mt.In(numIn-1))
+						continue
+					}
+					params[i] = p.Index
+					numIn--
+				}
+			}
+
+			// TestXxx(*td.T[, *td.T], fixtures…)
+			if numIn > 2 && mt.In(1) == tType {
+				first := 2
+				if mt.In(2) == tType {
+					first = 3
+				}
+				if missing := fx.missing(mt, first, numIn); missing != nil {
+					t.Logf("Run(): method %T.%s skipped, no fixture provider for parameter type %s",
+						suite, m.Name, missing)
+					continue
+				}
+				numIn = first
+			}
+
 			// Check input parameters
-			switch mt.NumIn() {
+			switch numIn {
 			case 2:
 				// TestXxx(*td.T)
 				if mt.In(1) != tType {
@@ -205,61 +318,311 @@ func Run(tb testing.TB, suite interface{}, config ...td.ContextConfig) bool {
 				continue
 			}
 
-			// Check output parameters
-			switch mt.NumOut() {
-			case 0:
-			case 1:
-				switch mt.Out(0) {
-				case types.Bool, types.Error:
-				default:
-					t.Fatalf("Run(): method %T.%s returns %s value. Only bool or error are allowed",
-						suite, m.Name, mt.Out(0))
-					return false // only for tests
-				}
-			case 2:
-				if mt.Out(0) != types.Bool || mt.Out(1) != types.Error {
-					t.Fatalf("Run(): method %T.%s returns (%s, %s) values. Only (bool, error) is allowed",
-						suite, m.Name, mt.Out(0), mt.Out(1))
-					return false // only for tests
-				}
-			default:
-				t.Fatalf("Run(): method %T.%s returns %d values. Only 0, 1 (bool or error) or 2 (bool, error) values are allowed",
-					suite, m.Name, mt.NumOut())
-				return false // only for tests
+			if !checkOutputs(t, "Run", suite, m) {
+				return // only for tests
 			}
 
 			methods = append(methods, i)
 		}
 	}
 
-	if len(methods) == 0 {
+	if len(methods) == 0 && !hasSubSuites(typ) {
 		t.Fatalf("Run(): no test methods found for type %T", suite)
-		return false // only for tests
+		return // only for tests
 	}
 
-	run(t, suite, methods)
+	methods, ok := selectTests(t, suite, methods)
+	if !ok {
+		return // only for tests
+	}
 
-	return !t.Failed()
+	var parallel []int
+	if s, ok := suite.(Parallel); ok {
+		var sequential []int
+		for _, method := range methods {
+			if s.Parallel(typ.Method(method).Name) {
+				parallel = append(parallel, method)
+			} else {
+				sequential = append(sequential, method)
+			}
+		}
+		if parallel != nil {
+			if _, ok := suite.(Clone); !ok {
+				t.Fatalf("Run(): %T suite has parallel tests but does not implement Clone interface", suite)
+				return // only for tests
+			}
+		}
+		methods = sequential
+	}
+
+	run(t, suite, methods, parallel, params, fx)
 }
 
-func run(t *td.T, suite interface{}, methods []int) {
+// setup calls the Setup hook of "suite" if any. It returns false if
+// it failed.
+func setup(t *td.T, suite interface{}) bool {
 	t.Helper()
 
-	// setup
 	if s, ok := suite.(Setup); ok {
 		if err := s.Setup(t); err != nil {
 			t.Errorf("%T suite setup error: %s", suite, err)
+			return false
+		}
+	}
+	return true
+}
+
+// destroy calls the Destroy hook of "suite" if any.
+func destroy(t *td.T, suite interface{}) {
+	t.Helper()
+
+	if s, ok := suite.(Destroy); ok {
+		if err := s.Destroy(t); err != nil {
+			t.Errorf("%T suite destroy error: %s", suite, err)
+		}
+	}
+}
+
+func run(t *td.T, suite interface{}, methods, parallel []int, params map[int]int, fx *fixtures) {
+	t.Helper()
+
+	if !setup(t, suite) {
+		return
+	}
+	defer destroy(t, suite)
+
+	between := emptyBetweenTests
+	if s, ok := suite.(BetweenTests); ok {
+		between = s.BetweenTests
+	}
+
+	typ := reflect.TypeOf(suite)
+
+	for i, method := range methods {
+		name := typ.Method(method).Name
+
+		if !runTest(t, suite, method, params, fx, false) {
+			t.Logf("%s required discontinuing suite tests", name)
 			return
 		}
+
+		if i != len(methods)-1 {
+			next := typ.Method(methods[i+1]).Name
+			if err := between(t, name, next); err != nil {
+				t.Errorf("%s / %s between-tests error: %s", name, next, err)
+				return
+			}
+		}
+	}
+
+	if len(parallel) > 0 {
+		// The "parallel" subtest returns once all parallel tests ended
+		t.Run("parallel", func(t *td.T) {
+			for _, method := range parallel {
+				clone := suite.(Clone).Clone()
+				if reflect.TypeOf(clone) != typ {
+					t.Errorf("%T.Clone() returned %T instead of %T", suite, clone, suite)
+					return
+				}
+				runTest(t, clone, method, params, fx, true)
+			}
+		})
+	}
+
+	// Sub-suites are run after Setup and all tests, but before Destroy
+	for _, sub := range subSuites(suite) {
+		sub := sub
+		t.Run(sub.name, func(t *td.T) {
+			runSuite(t, sub.suite)
+		})
+	}
+}
+
+type subSuite struct {
+	name  string
+	suite interface{}
+}
+
+// isSuiteType returns true if "typ" or a pointer to "typ" has at
+// least one test method, so can be a sub-suite.
+func isSuiteType(typ reflect.Type) bool {
+	hasTest := func(typ reflect.Type) bool {
+		for i, num := 0, typ.NumMethod(); i < num; i++ {
+			if isTest(typ.Method(i).Name) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if hasTest(typ) {
+		return true
+	}
+	return typ.Kind() != reflect.Ptr && typ.Kind() != reflect.Interface &&
+		hasTest(reflect.PtrTo(typ))
+}
+
+// isSubSuiteField returns true if "field" is an exported non-embedded
+// field whose type can be a sub-suite.
+func isSubSuiteField(field reflect.StructField) bool {
+	return field.PkgPath == "" && !field.Anonymous && isSuiteType(field.Type)
+}
+
+// isSubSuiteMethod returns true if "method" is a SuiteXxx method
+// returning a value that can be a sub-suite.
+func isSubSuiteMethod(method reflect.Method) bool {
+	if !isPrefixed(method.Name, "Suite") {
+		return false
+	}
+	mt := method.Type
+	return mt.NumIn() == 1 && mt.NumOut() == 1 &&
+		(mt.Out(0).Kind() == reflect.Interface || isSuiteType(mt.Out(0)))
+}
+
+// structType returns the struct type behind "typ" or nil.
+func structType(typ reflect.Type) reflect.Type {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return nil
+	}
+	return typ
+}
+
+// hasSubSuites returns true if a suite of type "typ" can contain
+// sub-suites.
+func hasSubSuites(typ reflect.Type) bool {
+	if st := structType(typ); st != nil {
+		for i, num := 0, st.NumField(); i < num; i++ {
+			if isSubSuiteField(st.Field(i)) {
+				return true
+			}
+		}
+	}
+	for i, num := 0, typ.NumMethod(); i < num; i++ {
+		if isSubSuiteMethod(typ.Method(i)) {
+			return true
+		}
+	}
+	return false
+}
+
+// subSuites returns the sub-suites of "suite": first its exported
+// fields having test methods in their declaration order, then the
+// values returned by its SuiteXxx methods in lexicographic order. nil
+// sub-suites are ignored.
+func subSuites(suite interface{}) []subSuite {
+	var subs []subSuite
+
+	vs := reflect.ValueOf(suite)
+	if st := structType(vs.Type()); st != nil {
+		v := reflect.Indirect(vs)
+		for i, num := 0, st.NumField(); i < num; i++ {
+			field := st.Field(i)
+			if !isSubSuiteField(field) {
+				continue
+			}
+
+			fv := v.Field(i)
+			switch fv.Kind() {
+			case reflect.Ptr, reflect.Interface:
+				if fv.IsNil() {
+					continue
+				}
+			default:
+				if fv.CanAddr() {
+					fv = fv.Addr()
+				}
+			}
+			subs = append(subs, subSuite{name: field.Name, suite: fv.Interface()})
+		}
+	}
+
+	for i, num := 0, vs.NumMethod(); i < num; i++ {
+		method := vs.Type().Method(i)
+		if !isSubSuiteMethod(method) {
+			continue
+		}
+
+		sub := vs.Method(i).Call(nil)[0]
+		if (sub.Kind() == reflect.Ptr || sub.Kind() == reflect.Interface) && sub.IsNil() {
+			continue
+		}
+		subs = append(subs, subSuite{name: method.Name, suite: sub.Interface()})
+	}
+
+	return subs
+}
+
+// testMethod is a test method ready to be called.
+type testMethod struct {
+	call     func([]reflect.Value) []reflect.Value
+	numT     int            // number of *td.T parameters
+	fixtures []reflect.Type // types of fixtures parameters
+}
+
+// runTest runs the test method number "method" of "suite" in a
+// subtest, wrapped by PreTest and PostTest hooks if any. If "params"
+// contains a cases provider for this method, each case is run in its
+// own subtest of this subtest. Fixtures parameters are resolved using
+// "fx". If "parallel" is true, the subtest runs in parallel. It
+// returns false if the test required discontinuing the suite.
+func runTest(t *td.T, suite interface{}, method int, params map[int]int, fx *fixtures, parallel bool) bool {
+	t.Helper()
+
+	vs := reflect.ValueOf(suite)
+	m := vs.Type().Method(method)
+
+	numIn := m.Type.NumIn()
+	provider, hasParams := params[method]
+	if hasParams {
+		numIn--
+	}
+
+	tm := testMethod{
+		call: vs.Method(method).Call,
+		numT: 1,
 	}
-	defer func() {
-		// destroy
-		if s, ok := suite.(Destroy); ok {
-			if err := s.Destroy(t); err != nil {
-				t.Errorf("%T suite destroy error: %s", suite, err)
+	if numIn > 2 && m.Type.In(2) == tType {
+		tm.numT = 2
+	}
+	for i := 1 + tm.numT; i < numIn; i++ {
+		tm.fixtures = append(tm.fixtures, m.Type.In(i))
+	}
+
+	if !hasParams {
+		return runCase(t, suite, fx, tm, m.Name, m.Name, reflect.Value{}, parallel)
+	}
+
+	names, cases := testCases(vs.Method(provider).Call(nil)[0])
+
+	cont := true
+	t.Run(m.Name, func(t *td.T) {
+		if parallel {
+			setParallel(t)
+		}
+
+		for i, name := range names {
+			if !runCase(t, suite, fx, tm, name, m.Name+"/"+name, cases[i], false) {
+				cont = false
+				return
 			}
 		}
-	}()
+	})
+	return parallel || cont
+}
+
+// runCase runs "tm" in the subtest "name", wrapped by PreTest and
+// PostTest hooks of "suite" if any, "testName" being passed to these
+// hooks. "tm" receives its *td.T instances, then its fixtures
+// resolved using "fx", then "tcase" if it is valid. If "parallel" is
+// true, the subtest runs in parallel. It returns false if the test
+// required discontinuing the suite.
+func runCase(t *td.T, suite interface{}, fx *fixtures, tm testMethod,
+	name, testName string, tcase reflect.Value, parallel bool,
+) bool {
+	t.Helper()
 
 	preTest := emptyPrePostTest
 	if s, ok := suite.(PreTest); ok {
@@ -271,65 +634,175 @@ func run(t *td.T, suite interface{}, methods []int) {
 		postTest = s.PostTest
 	}
 
-	between := emptyBetweenTests
-	if s, ok := suite.(BetweenTests); ok {
-		between = s.BetweenTests
+	skip := emptySkip
+	if s, ok := suite.(Skip); ok {
+		skip = s.Skip
 	}
 
-	vs := reflect.ValueOf(suite)
-	typ := reflect.TypeOf(suite)
+	timeout := emptyTimeout
+	if s, ok := suite.(Timeout); ok {
+		timeout = s.Timeout
+	}
 
-	for i, method := range methods {
-		m := typ.Method(method)
-		mt := m.Type
+	leakCheck := emptyLeakCheck
+	if s, ok := suite.(LeakCheck); ok {
+		leakCheck = s.LeakCheck
+	}
+
+	args := func(ts ...*td.T) ([]reflect.Value, bool) {
+		args := make([]reflect.Value, 0, len(ts)+len(tm.fixtures)+1)
+		for _, t := range ts {
+			args = append(args, reflect.ValueOf(t))
+		}
+		for _, typ := range tm.fixtures {
+			fixture, err := fx.get(ts[0], suite, typ)
+			if err != nil {
+				ts[0].Errorf("%s fixture error: %s", testName, err)
+				return nil, false
+			}
+			args = append(args, fixture)
+		}
+		if tcase.IsValid() {
+			args = append(args, tcase)
+		}
+		return args, true
+	}
 
-		call := vs.Method(method).Call
+	cont := true
+	if tm.numT == 1 {
+		t.Run(name, func(t *td.T) {
+			if parallel {
+				setParallel(t)
+			}
 
-		cont := true
-		if mt.NumIn() == 2 {
-			t.Run(m.Name, func(t *td.T) {
-				if err := preTest(t, m.Name); err != nil {
-					t.Errorf("%s pre-test error: %s", m.Name, err)
-					return
+			if reason := skip(testName); reason != "" {
+				t.Skip(reason)
+				return
+			}
+
+			if err := preTest(t, testName); err != nil {
+				t.Errorf("%s pre-test error: %s", testName, err)
+				return
+			}
+			defer func() {
+				if err := postTest(t, testName); err != nil {
+					t.Errorf("%s post-test error: %s", testName, err)
 				}
-				defer func() {
-					if err := postTest(t, m.Name); err != nil {
-						t.Errorf("%s post-test error: %s", m.Name, err)
-					}
-				}()
-
-				cont = shouldContinue(t, m.Name, call([]reflect.Value{reflect.ValueOf(t)}))
-			})
-		} else {
-			t.RunAssertRequire(m.Name, func(assert, require *td.T) {
-				if err := preTest(assert, m.Name); err != nil {
-					assert.Errorf("%s pre-test error: %s", m.Name, err)
-					return
+			}()
+
+			if args, ok := args(t); ok {
+				cont = callTest(t, testName, tm.call, args,
+					timeout(testName), leakCheck(testName))
+			}
+		})
+	} else {
+		t.RunAssertRequire(name, func(assert, require *td.T) {
+			if parallel {
+				setParallel(assert)
+			}
+
+			if reason := skip(testName); reason != "" {
+				assert.Skip(reason)
+				return
+			}
+
+			if err := preTest(assert, testName); err != nil {
+				assert.Errorf("%s pre-test error: %s", testName, err)
+				return
+			}
+			defer func() {
+				if err := postTest(assert, testName); err != nil {
+					assert.Errorf("%s post-test error: %s", testName, err)
 				}
-				defer func() {
-					if err := postTest(assert, m.Name); err != nil {
-						assert.Errorf("%s post-test error: %s", m.Name, err)
-					}
-				}()
+			}()
 
-				cont = shouldContinue(assert, m.Name, call([]reflect.Value{
-					reflect.ValueOf(assert),
-					reflect.ValueOf(require),
-				}))
-			})
+			if args, ok := args(assert, require); ok {
+				cont = callTest(assert, testName, tm.call, args,
+					timeout(testName), leakCheck(testName))
+			}
+		})
+	}
+	return parallel || cont
+}
+
+// isParamsProvider returns true if "provider" is the type of a method
+// without parameters returning a slice, an array or a map with string
+// keys of "param" values.
+func isParamsProvider(provider, param reflect.Type) bool {
+	if provider.NumIn() != 1 || provider.NumOut() != 1 {
+		return false
+	}
+
+	out := provider.Out(0)
+	switch out.Kind() {
+	case reflect.Slice, reflect.Array:
+	case reflect.Map:
+		if out.Key().Kind() != reflect.String {
+			return false
 		}
+	default:
+		return false
+	}
+	return out.Elem().AssignableTo(param)
+}
 
-		if !cont {
-			t.Logf("%s required discontinuing suite tests", m.Name)
-			break
+// testCases returns the names and values of the cases contained in
+// "cases", as returned by a cases provider. For a map, cases are
+// sorted by key, and named after it. For a slice or an array, a case
+// is named after its Name string field if any and not empty, else
+// after its String method if any, else after its index.
+func testCases(cases reflect.Value) ([]string, []reflect.Value) {
+	if cases.Kind() == reflect.Map {
+		keys := cases.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return keys[i].String() < keys[j].String()
+		})
+
+		values := make([]reflect.Value, len(keys))
+		names := make([]string, len(keys))
+		for i, key := range keys {
+			values[i] = cases.MapIndex(key)
+			names[i] = key.String()
 		}
+		return names, values
+	}
 
-		if i != len(methods)-1 {
-			next := typ.Method(methods[i+1]).Name
-			if err := between(t, m.Name, next); err != nil {
-				t.Errorf("%s / %s between-tests error: %s", m.Name, next, err)
-				break
-			}
+	values := make([]reflect.Value, cases.Len())
+	names := make([]string, cases.Len())
+	for i := range values {
+		values[i] = cases.Index(i)
+		names[i] = caseName(values[i], i)
+	}
+	return names, values
+}
+
+// caseName returns the name of the case "tcase" found at index "i".
+func caseName(tcase reflect.Value, i int) string {
+	v := tcase
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return fmt.Sprintf("#%d", i)
 		}
+		v = v.Elem()
+	}
+
+	if v.Kind() == reflect.Struct {
+		if name := v.FieldByName("Name"); name.Kind() == reflect.String && name.String() != "" {
+			return name.String()
+		}
+	}
+
+	if s, ok := tcase.Interface().(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fmt.Sprintf("#%d", i)
+}
+
+// setParallel signals that the test behind "t" is to be run in
+// parallel, if the underlying testing.TB supports it, as *testing.T
+// does.
+func setParallel(t *td.T) {
+	if p, ok := t.TB.(interface{ Parallel() }); ok {
+		p.Parallel()
 	}
 }
@@ -8,9 +8,13 @@ package tdsuite_test
 
 import (
 	"errors"
+	"flag"
+	"fmt"
 	"runtime"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/maxatome/go-testdeep/helpers/tdsuite"
 	"github.com/maxatome/go-testdeep/internal/test"
@@ -199,8 +203,8 @@ func TestRun(t *testing.T) {
 			p + "Test1Param skipped, unrecognized parameter type int. Only *td.T allowed",
 			p + "Test2ParamsA skipped, unrecognized parameters types (int, int). Only (*td.T, *td.T) allowed",
 			p + "Test2ParamsB skipped, unrecognized first parameter type int. Only (*td.T, *td.T) allowed",
-			p + "Test2ParamsC skipped, unrecognized second parameter type int. Only (*td.T, *td.T) allowed",
-			p + "Test3Params skipped, too many parameters",
+			p + "Test2ParamsC skipped, no fixture provider for parameter type int",
+			p + "Test3Params skipped, no fixture provider for parameter type int",
 			p + "TestNoParams skipped, no input parameters",
 			p + "TestVariadic skipped, variadic parameters not supported",
 			"++++ TestOK", // (*T).Run() log as test.TestingTB has no Run() method
@@ -601,3 +605,309 @@ func TestRunErrors(t *testing.T) {
 		})
 	})
 }
+
+// Par has sequential and parallel tests.
+type Par struct {
+	shared *parShared
+	id     int
+}
+
+type parShared struct {
+	mu     sync.Mutex
+	calls  []string
+	clones int
+	meet   chan struct{}
+}
+
+func (p *Par) rec(call string) {
+	p.shared.mu.Lock()
+	defer p.shared.mu.Unlock()
+	p.shared.calls = append(p.shared.calls, fmt.Sprintf("%s#%d", call, p.id))
+}
+
+func (p *Par) Setup(t *td.T) error { p.rec("Setup"); return nil }
+func (p *Par) Parallel(tn string) bool {
+	return strings.HasPrefix(tn, "TestPar")
+}
+func (p *Par) Clone() interface{} {
+	p.shared.mu.Lock()
+	defer p.shared.mu.Unlock()
+	p.shared.clones++
+	return &Par{shared: p.shared, id: p.shared.clones}
+}
+func (p *Par) PreTest(t *td.T, tn string) error  { p.rec("PreTest+" + tn); return nil }
+func (p *Par) PostTest(t *td.T, tn string) error { p.rec("PostTest+" + tn); return nil }
+func (p *Par) BetweenTests(t *td.T, prev, next string) error {
+	p.rec("BetweenTests+" + prev + "+" + next)
+	return nil
+}
+func (p *Par) Destroy(t *td.T) error { p.rec("Destroy"); return nil }
+
+func (p *Par) TestSeq1(t *td.T) { p.rec("TestSeq1") }
+func (p *Par) TestSeq2(t *td.T) { p.rec("TestSeq2") }
+
+// TestPar1 & TestPar2 only succeed if they run concurrently, when
+// meet channel is set.
+func (p *Par) TestPar1(t *td.T) {
+	p.rec("TestPar1")
+	if p.shared.meet == nil {
+		return
+	}
+	select {
+	case p.shared.meet <- struct{}{}:
+	case <-time.After(5 * time.Second):
+		t.Error("TestPar2 not running concurrently")
+	}
+}
+func (p *Par) TestPar2(assert, require *td.T) bool {
+	p.rec("TestPar2")
+	if p.shared.meet == nil {
+		return false
+	}
+	select {
+	case <-p.shared.meet:
+	case <-time.After(5 * time.Second):
+		assert.Error("TestPar1 not running concurrently")
+	}
+	return false // ignored for parallel tests
+}
+
+var (
+	_ tdsuite.Parallel = (*Par)(nil)
+	_ tdsuite.Clone    = (*Par)(nil)
+)
+
+// ParNoClone has a parallel test but no Clone method.
+type ParNoClone struct{}
+
+func (p ParNoClone) Parallel(tn string) bool { return true }
+func (p ParNoClone) Test(t *td.T)            {}
+
+// ParBadClone has a Clone method returning a bad type.
+type ParBadClone struct{}
+
+func (p *ParBadClone) Parallel(tn string) bool { return true }
+func (p *ParBadClone) Clone() interface{}      { return ParBadClone{} }
+func (p *ParBadClone) Test(t *td.T)            {}
+
+func TestRunParallel(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		suite := Par{shared: &parShared{}}
+		// Parallel tests can only meet if they can run concurrently
+		if flag.Lookup("test.parallel").Value.(flag.Getter).Get().(int) > 1 {
+			suite.shared.meet = make(chan struct{})
+		}
+		td.CmpTrue(t, tdsuite.Run(t, &suite))
+		calls := suite.shared.calls
+		if !td.Cmp(t, calls, td.Len(15)) {
+			return
+		}
+		td.Cmp(t, calls[:8], []string{
+			"Setup#0",
+			/**/ "PreTest+TestSeq1#0",
+			/**/ "TestSeq1#0",
+			/**/ "PostTest+TestSeq1#0",
+			"BetweenTests+TestSeq1+TestSeq2#0",
+			/**/ "PreTest+TestSeq2#0",
+			/**/ "TestSeq2#0",
+			/**/ "PostTest+TestSeq2#0",
+		})
+		// Each parallel test runs on its own clone
+		td.Cmp(t, calls[8:14], td.Any(
+			td.Bag(
+				"PreTest+TestPar1#1", "TestPar1#1", "PostTest+TestPar1#1",
+				"PreTest+TestPar2#2", "TestPar2#2", "PostTest+TestPar2#2",
+			),
+			td.Bag(
+				"PreTest+TestPar1#2", "TestPar1#2", "PostTest+TestPar1#2",
+				"PreTest+TestPar2#1", "TestPar2#1", "PostTest+TestPar2#1",
+			),
+		))
+		td.Cmp(t, calls[14], "Destroy#0")
+	})
+
+	t.Run("ErrNoClone", func(t *testing.T) {
+		tb := test.NewTestingTB("TestParNoClone")
+		tdsuite.Run(tb, ParNoClone{})
+		td.CmpTrue(t, tb.IsFatal)
+		td.Cmp(t, tb.LastMessage(), "Run(): tdsuite_test.ParNoClone suite has parallel tests but does not implement Clone interface")
+	})
+
+	t.Run("ErrBadClone", func(t *testing.T) {
+		tb := test.NewTestingTB("TestParBadClone")
+		td.CmpFalse(t, tdsuite.Run(tb, &ParBadClone{}))
+		td.CmpFalse(t, tb.IsFatal)
+		td.Cmp(t, tb.Messages, []string{
+			"++++ parallel",
+			"*tdsuite_test.ParBadClone.Clone() returned tdsuite_test.ParBadClone instead of *tdsuite_test.ParBadClone",
+		})
+	})
+}
+
+// Params has table-driven tests.
+type Params struct{ base }
+
+type parseCase struct {
+	Name string
+	In   string
+}
+
+type strCase int
+
+func (s strCase) String() string { return fmt.Sprintf("str%d", int(s)) }
+
+func (p *Params) PreTest(t *td.T, tn string) error  { p.rec(tn); return nil }
+func (p *Params) PostTest(t *td.T, tn string) error { p.rec(tn); return nil }
+
+func (p *Params) ParamsTestMap() map[string]int { return map[string]int{"b": 2, "a": 1} }
+func (p *Params) TestMap(assert, require *td.T, n int) {
+	p.rec(fmt.Sprint(n))
+}
+
+func (p *Params) ParamsTestParse() []parseCase {
+	return []parseCase{{Name: "one", In: "1"}, {In: "2"}}
+}
+func (p *Params) TestParse(t *td.T, tc parseCase) { p.rec(tc.In) }
+
+func (p *Params) ParamsTestStringer() [1]strCase { return [1]strCase{4} }
+func (p *Params) TestStringer(t *td.T, tc fmt.Stringer) error {
+	p.rec(tc.String())
+	return nil
+}
+
+// ParamsSkip has table-driven tests with bad providers.
+type ParamsSkip struct{ base }
+
+func (p *ParamsSkip) ParamsTestBad() []string                 { return nil }
+func (p *ParamsSkip) TestBad(t *td.T, n int)                  {}
+func (p *ParamsSkip) ParamsTestBadMap() map[int]int           { return nil }
+func (p *ParamsSkip) TestBadMap(t *td.T, n int)               {}
+func (p *ParamsSkip) ParamsTestBadParam(n int) []int          { return nil }
+func (p *ParamsSkip) TestBadParam(t *td.T, n int)             {}
+func (p *ParamsSkip) ParamsTestTooMany() []int                { return nil }
+func (p *ParamsSkip) TestTooMany(t *td.T, n int, x int)       {}
+func (p *ParamsSkip) ParamsTestStop() []int                   { return []int{1, 2, 3} }
+func (p *ParamsSkip) TestStop(t *td.T, n int) bool            { p.rec(fmt.Sprint(n)); return n != 2 }
+func (p *ParamsSkip) TestZ(t *td.T)                           { p.rec() }
+func (p *ParamsSkip) ParamsTestEmpty() map[string]interface{} { return nil }
+func (p *ParamsSkip) TestEmpty(t *td.T, x interface{})        { p.rec() }
+
+func TestRunParams(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		suite := Params{}
+		td.CmpTrue(t, tdsuite.Run(t, &suite))
+		td.Cmp(t, suite.calls, []string{
+			"PreTest+TestMap/a", "TestMap+1", "PostTest+TestMap/a",
+			"PreTest+TestMap/b", "TestMap+2", "PostTest+TestMap/b",
+			"PreTest+TestParse/one", "TestParse+1", "PostTest+TestParse/one",
+			"PreTest+TestParse/#1", "TestParse+2", "PostTest+TestParse/#1",
+			"PreTest+TestStringer/str4", "TestStringer+str4", "PostTest+TestStringer/str4",
+		})
+	})
+
+	t.Run("Skip & stop", func(t *testing.T) {
+		suite := ParamsSkip{}
+		tb := test.NewTestingTB("TestParamsSkip")
+		td.CmpTrue(t, tdsuite.Run(tb, &suite))
+		td.CmpFalse(t, tb.IsFatal)
+		td.Cmp(t, suite.calls, []string{"TestStop+1", "TestStop+2"})
+
+		const p = "Run(): method *tdsuite_test.ParamsSkip."
+		td.Cmp(t, tb.Messages, []string{
+			p + "TestBad skipped, ParamsTestBad method should return []int or map[string]int",
+			p + "TestBadMap skipped, ParamsTestBadMap method should return []int or map[string]int",
+			p + "TestBadParam skipped, ParamsTestBadParam method should return []int or map[string]int",
+			p + "TestTooMany skipped, no fixture provider for parameter type int",
+			"++++ TestEmpty",
+			"++++ TestStop",
+			"++++ #0",
+			"++++ #1",
+			"TestStop required discontinuing suite tests",
+		})
+	})
+}
+
+// Top has tests and sub-suites.
+type Top struct {
+	base
+	API        *SubAPI
+	Repo       SubRepo
+	Nil        *SubAPI // nil, so ignored
+	unexported SubRepo // ignored
+	Data       []int   // not a sub-suite
+}
+
+func (s *Top) Setup(t *td.T) error {
+	s.rec()
+	s.API = &SubAPI{top: s}
+	s.Repo.top = s
+	return nil
+}
+func (s *Top) Destroy(t *td.T) error { s.rec(); return nil }
+func (s *Top) Test1(t *td.T)         { s.rec() }
+func (s *Top) SuiteMigrations() interface{} {
+	return SubMigrations{top: s}
+}
+func (s *Top) SuiteNil() *SubAPI { return nil }         // nil, so ignored
+func (s *Top) SuiteName() string { return "not suite" } // not a sub-suite
+
+// SubAPI is a sub-suite of Top.
+type SubAPI struct{ top *Top }
+
+func (s *SubAPI) Setup(t *td.T) error   { s.top.rec("API"); return nil }
+func (s *SubAPI) Destroy(t *td.T) error { s.top.rec("API"); return nil }
+func (s *SubAPI) Test1(t *td.T)         { s.top.rec("API") }
+func (s *SubAPI) Test2(t *td.T)         { s.top.rec("API") }
+
+// SubRepo is a sub-suite of Top, whose methods have pointer receivers.
+type SubRepo struct{ top *Top }
+
+func (s *SubRepo) Test1(t *td.T) { s.top.rec("Repo") }
+
+// SubMigrations is a sub-suite of Top, returned by a SuiteXxx method.
+type SubMigrations struct{ top *Top }
+
+func (s SubMigrations) Destroy(t *td.T) error { s.top.rec("Migrations"); return nil }
+func (s SubMigrations) Test1(t *td.T)         { s.top.rec("Migrations") }
+
+// TopOnly has no tests but only sub-suites.
+type TopOnly struct {
+	Sub SubErr
+}
+
+// SubErr is a sub-suite whose Setup fails.
+type SubErr struct{}
+
+func (s SubErr) Setup(t *td.T) error { return errors.New("Setup error") }
+func (s SubErr) Test1(t *td.T)       {}
+
+func TestRunSubSuites(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		suite := Top{}
+		td.CmpTrue(t, tdsuite.Run(t, &suite))
+		td.Cmp(t, suite.calls, []string{
+			"Setup",
+			"Test1",
+			/**/ "Setup+API",
+			/**/ "Test1+API",
+			/**/ "Test2+API",
+			/**/ "Destroy+API",
+			//
+			/**/ "Test1+Repo",
+			//
+			/**/ "Test1+Migrations",
+			/**/ "Destroy+Migrations",
+			"Destroy",
+		})
+	})
+
+	t.Run("Failure", func(t *testing.T) {
+		tb := test.NewTestingTB("TestTopOnly")
+		td.CmpFalse(t, tdsuite.Run(tb, &TopOnly{}))
+		td.CmpFalse(t, tb.IsFatal)
+		td.Cmp(t, tb.Messages, []string{
+			"++++ Sub",
+			"*tdsuite_test.SubErr suite setup error: Setup error",
+		})
+	})
+}
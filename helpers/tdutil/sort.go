@@ -25,6 +25,9 @@ func cmpRet(less, gt bool) int {
 
 func cmpFloat(a, b float64) int {
 	if math.IsNaN(a) {
+		if math.IsNaN(b) {
+			return 0
+		}
 		return -1
 	}
 	if math.IsNaN(b) {
@@ -33,6 +36,13 @@ func cmpFloat(a, b float64) int {
 	return cmpRet(a < b, a > b)
 }
 
+// CompareValues returns -1 if a < b, 1 if a > b, 0 if a == b, using
+// the same rules as SortableValues. As a and b are deeply compared,
+// cyclic references are correctly handled.
+func CompareValues(a, b reflect.Value) int {
+	return cmp(visited.NewVisited(), a, b)
+}
+
 // cmp returns -1 if a < b, 1 if a > b, 0 if a == b.
 func cmp(v visited.Visited, a, b reflect.Value) int {
 	if !a.IsValid() {
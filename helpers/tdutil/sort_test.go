@@ -95,7 +95,7 @@ func TestSortCmp(t *testing.T) {
 	checkCmp(float64(12), float64(12), 0)
 
 	checkCmp(math.NaN(), float64(12), -1)
-	checkCmp(math.NaN(), math.NaN(), -1)
+	checkCmp(math.NaN(), math.NaN(), 0)
 	checkCmp(float64(12), math.NaN(), 1)
 
 	// complex
@@ -27,6 +27,7 @@ import (
 //   - different types are sorted by their name
 //   - false is lesser than true
 //   - float and int numbers are sorted by their value
+//   - NaN is lower than any other float number
 //   - complex numbers are sorted by their real, then by their imaginary parts
 //   - strings are sorted by their value
 //   - map: shorter length is lesser, then sorted by address
@@ -35,3 +35,28 @@ func TestSortValues(t *testing.T) {
 
 	sort.Sort(tdutil.SortableValues(nil))
 }
+
+func TestCompareValues(t *testing.T) {
+	type cyclic struct {
+		Next *cyclic
+		Val  int
+	}
+	a, b := &cyclic{Val: 1}, &cyclic{Val: 2}
+	a.Next, b.Next = a, b
+
+	for _, tst := range []struct {
+		a, b     interface{}
+		expected int
+	}{
+		{a: 1, b: 2, expected: -1},
+		{a: "b", b: "a", expected: 1},
+		{a: a, b: a, expected: 0},
+		{a: a, b: b, expected: -1},
+		{a: b, b: a, expected: 1},
+	} {
+		got := tdutil.CompareValues(reflect.ValueOf(tst.a), reflect.ValueOf(tst.b))
+		if got != tst.expected {
+			t.Errorf("CompareValues(%v, %v) = %d, expected %d", tst.a, tst.b, got, tst.expected)
+		}
+	}
+}
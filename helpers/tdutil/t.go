@@ -18,6 +18,9 @@ import (
 
 // T can be used in tests, to test testing.T behavior as it overrides
 // Run() method.
+//
+// See TB for a standalone testing.TB implementation recording all
+// calls, more suitable to test helpers behavior.
 type T struct {
 	testing.T
 	name string
@@ -0,0 +1,480 @@
+// Copyright (c) 2021, Maxime Soulé
+// All rights reserved.
+//
+// This source code is licensed under the BSD-style license found in the
+// LICENSE file in the root directory of this source tree.
+
+package tdutil
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TBCall is a call to a testing.TB method recorded by TB.
+type TBCall struct {
+	// Method is the name of the called method, as "Errorf" or "Helper".
+	Method string
+	// Args contains the arguments of the call, the format string
+	// being the first one for Errorf, Fatalf, Logf and Skipf
+	// methods. Cleanup function is not recorded.
+	Args []interface{}
+	// Message is the message logged by the call, formatted as
+	// testing package does. It is empty if the method does not log
+	// anything.
+	Message string
+}
+
+// TB is a standalone implementation of testing.TB recording all the
+// calls done to its methods. It is intended to test the behavior of
+// helpers using a testing.TB (as *td.T ones), without failing the
+// real test:
+//
+//   tb := tdutil.NewTB("TestHelper")
+//   tb.Start(func(tb *tdutil.TB) {
+//     MyHelper(tb, got)
+//   })
+//
+//   td.CmpTrue(t, tb.Failed())
+//   td.Cmp(t, tb.Errors(), []string{"got is not correct"})
+//
+// As with a real test, FailNow (and so Fatal, Fatalf), SkipNow (and
+// so Skip, Skipf) stop the test by calling runtime.Goexit. So the
+// tested code has to be called from the function passed to Start or
+// Run methods, which run it in a new goroutine.
+//
+// TB is thread-safe.
+type TB struct {
+	// testing.TB is only embedded to implement its private method. It
+	// is always nil, so all testing.TB methods are implemented by TB.
+	testing.TB
+	name string
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu       sync.Mutex
+	calls    []TBCall
+	failed   bool
+	fatal    bool
+	skipped  bool
+	helpers  int
+	cleanups []func()
+	subs     []*TB
+}
+
+var _ testing.TB = (*TB)(nil)
+
+// NewTB returns a new *TB instance. "name" is the string returned by
+// method Name.
+func NewTB(name string) *TB {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &TB{
+		name:   name,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// exec calls "fn" in a new goroutine and waits for its end, returning
+// the value of the panic it raised if any. fn can be ended by
+// runtime.Goexit.
+func exec(fn func()) (panicked interface{}) {
+	done := make(chan interface{})
+	go func() {
+		normal := false
+		defer func() {
+			if !normal {
+				done <- recover()
+				return
+			}
+			done <- nil
+		}()
+		fn()
+		normal = true
+	}()
+	return <-done
+}
+
+// Start calls "f" with t in a new goroutine, then cancels the context
+// returned by Context and calls the functions registered by Cleanup,
+// as the testing package does for a top-level test. It returns false
+// if t failed. If "f" or a cleanup function panics, the panic is
+// propagated to the caller, after all cleanup functions have been
+// called.
+func (t *TB) Start(f func(t *TB)) bool {
+	panicked := exec(func() { f(t) })
+	t.cancel()
+
+	t.mu.Lock()
+	cleanups := t.cleanups
+	t.cleanups = nil
+	t.mu.Unlock()
+
+	for i := len(cleanups) - 1; i >= 0; i-- {
+		if p := exec(cleanups[i]); panicked == nil {
+			panicked = p
+		}
+	}
+
+	if panicked != nil {
+		panic(panicked)
+	}
+	return !t.Failed()
+}
+
+func (t *TB) record(method, message string, args []interface{}) {
+	t.mu.Lock()
+	t.calls = append(t.calls, TBCall{
+		Method:  method,
+		Args:    args,
+		Message: message,
+	})
+	t.mu.Unlock()
+}
+
+func (t *TB) fail(fatal bool) {
+	t.mu.Lock()
+	t.failed = true
+	if fatal {
+		t.fatal = true
+	}
+	t.mu.Unlock()
+}
+
+func sprintln(args []interface{}) string {
+	return strings.TrimSuffix(fmt.Sprintln(args...), "\n")
+}
+
+func sprintf(format string, args []interface{}) string {
+	return fmt.Sprintf(format, args...)
+}
+
+func prepend(format string, args []interface{}) []interface{} {
+	return append([]interface{}{format}, args...)
+}
+
+// ArtifactDir records the call and returns a new directory to store
+// test output files, automatically removed by a cleanup function, as
+// the testing package does when -artifacts flag is not set.
+func (t *TB) ArtifactDir() string {
+	t.record("ArtifactDir", "", nil)
+
+	dir, err := ioutil.TempDir("", "tdutil-artifacts")
+	if err != nil {
+		t.Fatalf("cannot create artifact directory: %s", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) }) //nolint: errcheck
+	return dir
+}
+
+// Attr records the call. See CallsOf method to retrieve the attributes.
+func (t *TB) Attr(key, value string) {
+	t.record("Attr", "", []interface{}{key, value})
+}
+
+// Chdir records the call and changes the current working directory
+// to "dir", the previous one being restored by a cleanup function.
+func (t *TB) Chdir(dir string) {
+	t.record("Chdir", "", []interface{}{dir})
+
+	prev, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("cannot get current working directory: %s", err)
+	}
+	if err = os.Chdir(dir); err != nil {
+		t.Fatalf("cannot change working directory: %s", err)
+	}
+	t.Cleanup(func() { os.Chdir(prev) }) //nolint: errcheck
+}
+
+// Cleanup records the call and registers "fn" to be called at the end
+// of t, in last added, first called order.
+func (t *TB) Cleanup(fn func()) {
+	t.record("Cleanup", "", nil)
+	t.mu.Lock()
+	t.cleanups = append(t.cleanups, fn)
+	t.mu.Unlock()
+}
+
+// Context returns a context canceled just before the functions
+// registered by Cleanup are called. It is not recorded.
+func (t *TB) Context() context.Context {
+	return t.ctx
+}
+
+// Error records the call and is equivalent to Log followed by Fail.
+func (t *TB) Error(args ...interface{}) {
+	t.record("Error", sprintln(args), args)
+	t.fail(false)
+}
+
+// Errorf records the call and is equivalent to Logf followed by Fail.
+func (t *TB) Errorf(format string, args ...interface{}) {
+	t.record("Errorf", sprintf(format, args), prepend(format, args))
+	t.fail(false)
+}
+
+// Fail records the call and marks t as failed.
+func (t *TB) Fail() {
+	t.record("Fail", "", nil)
+	t.fail(false)
+}
+
+// FailNow records the call, marks t as failed and stops its execution
+// by calling runtime.Goexit.
+func (t *TB) FailNow() {
+	t.record("FailNow", "", nil)
+	t.fail(true)
+	runtime.Goexit()
+}
+
+// Failed reports whether t failed. It is not recorded.
+func (t *TB) Failed() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.failed
+}
+
+// Fatal records the call and is equivalent to Log followed by FailNow.
+func (t *TB) Fatal(args ...interface{}) {
+	t.record("Fatal", sprintln(args), args)
+	t.fail(true)
+	runtime.Goexit()
+}
+
+// Fatalf records the call and is equivalent to Logf followed by FailNow.
+func (t *TB) Fatalf(format string, args ...interface{}) {
+	t.record("Fatalf", sprintf(format, args), prepend(format, args))
+	t.fail(true)
+	runtime.Goexit()
+}
+
+// Helper records the call. See HelperCalls method.
+func (t *TB) Helper() {
+	t.record("Helper", "", nil)
+	t.mu.Lock()
+	t.helpers++
+	t.mu.Unlock()
+}
+
+// Log records the call, formatting its arguments as fmt.Sprintln does.
+func (t *TB) Log(args ...interface{}) {
+	t.record("Log", sprintln(args), args)
+}
+
+// Logf records the call, formatting its arguments as fmt.Sprintf does.
+func (t *TB) Logf(format string, args ...interface{}) {
+	t.record("Logf", sprintf(format, args), prepend(format, args))
+}
+
+// Name returns the name of t, as set by NewTB, or as computed by Run
+// for a subtest. It is not recorded.
+func (t *TB) Name() string {
+	return t.name
+}
+
+// tbOutput is the io.Writer returned by TB.Output.
+type tbOutput struct {
+	t *TB
+}
+
+func (o tbOutput) Write(p []byte) (int, error) {
+	o.t.record("Output", strings.TrimSuffix(string(p), "\n"),
+		[]interface{}{string(p)})
+	return len(p), nil
+}
+
+// Output returns an io.Writer whose each Write call is recorded as an
+// Output call, its message being the written data without its
+// trailing newline. Output itself is not recorded.
+func (t *TB) Output() io.Writer {
+	return tbOutput{t: t}
+}
+
+// Run records the call and runs "f" as a subtest of t called
+// "name", in a new goroutine, then waits for its end. As with the
+// testing package, a failure of the subtest is a failure of t. It
+// reports whether "f" succeeded.
+//
+// As its signature is compatible, it is used by td.T.Run and
+// td.T.RunAssertRequire methods.
+func (t *TB) Run(name string, f func(t *TB)) bool {
+	t.record("Run", "", []interface{}{name})
+
+	sub := NewTB(t.name + "/" + name)
+	t.mu.Lock()
+	t.subs = append(t.subs, sub)
+	t.mu.Unlock()
+
+	if !sub.Start(f) {
+		t.fail(false)
+		return false
+	}
+	return true
+}
+
+// Setenv records the call and sets the environment variable "key" to
+// "value", its previous state being restored by a cleanup function.
+func (t *TB) Setenv(key, value string) {
+	t.record("Setenv", "", []interface{}{key, value})
+
+	prev, set := os.LookupEnv(key)
+	if err := os.Setenv(key, value); err != nil {
+		t.Fatalf("cannot set environment variable: %s", err)
+	}
+	t.Cleanup(func() {
+		if set {
+			os.Setenv(key, prev) //nolint: errcheck
+		} else {
+			os.Unsetenv(key) //nolint: errcheck
+		}
+	})
+}
+
+// Skip records the call and is equivalent to Log followed by SkipNow.
+func (t *TB) Skip(args ...interface{}) {
+	t.record("Skip", sprintln(args), args)
+	t.skip()
+}
+
+// SkipNow records the call, marks t as skipped and stops its
+// execution by calling runtime.Goexit.
+func (t *TB) SkipNow() {
+	t.record("SkipNow", "", nil)
+	t.skip()
+}
+
+// Skipf records the call and is equivalent to Logf followed by SkipNow.
+func (t *TB) Skipf(format string, args ...interface{}) {
+	t.record("Skipf", sprintf(format, args), prepend(format, args))
+	t.skip()
+}
+
+func (t *TB) skip() {
+	t.mu.Lock()
+	t.skipped = true
+	t.mu.Unlock()
+	runtime.Goexit()
+}
+
+// Skipped reports whether t was skipped. It is not recorded.
+func (t *TB) Skipped() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.skipped
+}
+
+// TempDir records the call and returns a new temporary directory,
+// automatically removed by a cleanup function.
+func (t *TB) TempDir() string {
+	t.record("TempDir", "", nil)
+
+	dir, err := ioutil.TempDir("", "tdutil")
+	if err != nil {
+		t.Fatalf("cannot create temporary directory: %s", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) }) //nolint: errcheck
+	return dir
+}
+
+// Calls returns a copy of all the calls recorded by t, in order.
+func (t *TB) Calls() []TBCall {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]TBCall(nil), t.calls...)
+}
+
+// CallsOf returns, in order, the calls recorded by t of any of
+// the methods named "methods".
+func (t *TB) CallsOf(methods ...string) []TBCall {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var calls []TBCall
+	for _, call := range t.calls {
+		for _, m := range methods {
+			if call.Method == m {
+				calls = append(calls, call)
+				break
+			}
+		}
+	}
+	return calls
+}
+
+// messages returns the messages of the calls of "methods".
+func (t *TB) messages(methods ...string) []string {
+	calls := t.CallsOf(methods...)
+	msgs := make([]string, len(calls))
+	for i, call := range calls {
+		msgs[i] = call.Message
+	}
+	return msgs
+}
+
+// Messages returns, in order, all the messages logged by t using Log,
+// Logf, Error, Errorf, Fatal, Fatalf, Skip and Skipf methods, or
+// written to the io.Writer returned by Output method.
+func (t *TB) Messages() []string {
+	return t.messages("Log", "Logf", "Error", "Errorf", "Fatal", "Fatalf",
+		"Skip", "Skipf", "Output")
+}
+
+// Errors returns, in order, the messages logged by t using Error,
+// Errorf, Fatal and Fatalf methods.
+func (t *TB) Errors() []string {
+	return t.messages("Error", "Errorf", "Fatal", "Fatalf")
+}
+
+// LastMessage returns the last message logged by t, or "" if none.
+func (t *TB) LastMessage() string {
+	msgs := t.Messages()
+	if len(msgs) == 0 {
+		return ""
+	}
+	return msgs[len(msgs)-1]
+}
+
+// IsFatal reports whether t has been stopped by FailNow, Fatal or
+// Fatalf methods.
+func (t *TB) IsFatal() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.fatal
+}
+
+// HelperCalls returns the number of times Helper method has been
+// called.
+func (t *TB) HelperCalls() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.helpers
+}
+
+// Subs returns the subtests run by t using Run method, in order.
+func (t *TB) Subs() []*TB {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]*TB(nil), t.subs...)
+}
+
+// Sub returns the last subtest called "name" run by t using Run
+// method, or nil if not found.
+func (t *TB) Sub(name string) *TB {
+	name = t.name + "/" + name
+	subs := t.Subs()
+	for i := len(subs) - 1; i >= 0; i-- {
+		if subs[i].name == name {
+			return subs[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,308 @@
+// Copyright (c) 2021, Maxime Soulé
+// All rights reserved.
+//
+// This source code is licensed under the BSD-style license found in the
+// LICENSE file in the root directory of this source tree.
+
+package tdutil_test
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/maxatome/go-testdeep/helpers/tdutil"
+	"github.com/maxatome/go-testdeep/td"
+)
+
+func TestTB(t *testing.T) {
+	t.Run("Calls", func(t *testing.T) {
+		tb := tdutil.NewTB("TestTB")
+		if n := tb.Name(); n != "TestTB" {
+			t.Errorf("Name() = %q, expected TestTB", n)
+		}
+
+		var after bool
+		ok := tb.Start(func(tb *tdutil.TB) {
+			tb.Helper()
+			tb.Log("log", 1, 2)
+			tb.Logf("logf %d", 3)
+			tb.Error("error")
+			tb.Errorf("errorf %s", "x")
+			tb.Fatal("fatal")
+			after = true
+		})
+		if ok || !tb.Failed() || !tb.IsFatal() || tb.Skipped() {
+			t.Errorf("bad state: ok=%t failed=%t fatal=%t skipped=%t",
+				ok, tb.Failed(), tb.IsFatal(), tb.Skipped())
+		}
+		if after {
+			t.Error("Fatal did not stop the test")
+		}
+
+		expected := []tdutil.TBCall{
+			{Method: "Helper"},
+			{Method: "Log", Args: []interface{}{"log", 1, 2}, Message: "log 1 2"},
+			{Method: "Logf", Args: []interface{}{"logf %d", 3}, Message: "logf 3"},
+			{Method: "Error", Args: []interface{}{"error"}, Message: "error"},
+			{Method: "Errorf", Args: []interface{}{"errorf %s", "x"}, Message: "errorf x"},
+			{Method: "Fatal", Args: []interface{}{"fatal"}, Message: "fatal"},
+		}
+		if calls := tb.Calls(); !reflect.DeepEqual(calls, expected) {
+			t.Errorf("Calls() = %#v\nexpected %#v", calls, expected)
+		}
+		if msgs := tb.Messages(); !reflect.DeepEqual(msgs,
+			[]string{"log 1 2", "logf 3", "error", "errorf x", "fatal"}) {
+			t.Errorf("Messages() = %q", msgs)
+		}
+		if errs := tb.Errors(); !reflect.DeepEqual(errs,
+			[]string{"error", "errorf x", "fatal"}) {
+			t.Errorf("Errors() = %q", errs)
+		}
+		if m := tb.LastMessage(); m != "fatal" {
+			t.Errorf("LastMessage() = %q", m)
+		}
+		if n := tb.HelperCalls(); n != 1 {
+			t.Errorf("HelperCalls() = %d", n)
+		}
+	})
+
+	t.Run("Skip", func(t *testing.T) {
+		for _, skip := range []func(*tdutil.TB){
+			func(tb *tdutil.TB) { tb.Skip("skipped") },
+			func(tb *tdutil.TB) { tb.Skipf("%s", "skipped") },
+			func(tb *tdutil.TB) { tb.Log("skipped"); tb.SkipNow() },
+		} {
+			tb := tdutil.NewTB("TestSkip")
+			var after bool
+			ok := tb.Start(func(tb *tdutil.TB) {
+				skip(tb)
+				after = true
+			})
+			if !ok || tb.Failed() || !tb.Skipped() || after {
+				t.Errorf("bad state: ok=%t failed=%t skipped=%t after=%t",
+					ok, tb.Failed(), tb.Skipped(), after)
+			}
+			if m := tb.LastMessage(); m != "skipped" {
+				t.Errorf("LastMessage() = %q", m)
+			}
+		}
+	})
+
+	t.Run("Cleanup", func(t *testing.T) {
+		tb := tdutil.NewTB("TestCleanup")
+
+		const env = "TDUTIL_TB_TEST"
+		os.Unsetenv(env) //nolint: errcheck
+
+		var order []int
+		var dir string
+		tb.Start(func(tb *tdutil.TB) {
+			tb.Cleanup(func() { order = append(order, 1) })
+			tb.Cleanup(func() {
+				order = append(order, 2)
+				tb.FailNow() // does not prevent other cleanups
+			})
+			tb.Cleanup(func() { order = append(order, 3) })
+
+			tb.Setenv(env, "on")
+			if v := os.Getenv(env); v != "on" {
+				t.Errorf("Setenv did not set %s: %q", env, v)
+			}
+
+			dir = tb.TempDir()
+			if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+				t.Errorf("TempDir() did not create a directory: %v", err)
+			}
+
+			if len(order) != 0 {
+				t.Error("cleanup functions called too early")
+			}
+		})
+
+		if !reflect.DeepEqual(order, []int{3, 2, 1}) {
+			t.Errorf("cleanup functions called in bad order: %v", order)
+		}
+		if !tb.Failed() {
+			t.Error("FailNow in cleanup did not fail the test")
+		}
+		if _, set := os.LookupEnv(env); set {
+			t.Errorf("%s not restored", env)
+		}
+		if _, err := os.Stat(dir); !os.IsNotExist(err) {
+			t.Errorf("TempDir() directory not removed: %v", err)
+		}
+	})
+
+	t.Run("Context", func(t *testing.T) {
+		tb := tdutil.NewTB("TestContext")
+		tb.Start(func(tb *tdutil.TB) {
+			ctx := tb.Context()
+			if ctx.Err() != nil {
+				t.Error("context canceled too early")
+			}
+			tb.Cleanup(func() {
+				if ctx.Err() == nil {
+					t.Error("context not canceled before cleanup functions")
+				}
+			})
+		})
+		if len(tb.Calls()) != 1 {
+			t.Errorf("Context call should not be recorded: %v", tb.Calls())
+		}
+	})
+
+	t.Run("Chdir, Output, Attr and ArtifactDir", func(t *testing.T) {
+		tb := tdutil.NewTB("TestOthers")
+
+		cwd, err := os.Getwd()
+		if err != nil {
+			t.Fatalf("Getwd() failed: %s", err)
+		}
+
+		var dir string
+		tb.Start(func(tb *tdutil.TB) {
+			tb.Chdir(os.TempDir())
+			if wd, _ := os.Getwd(); wd == cwd {
+				t.Error("Chdir did not change the working directory")
+			}
+
+			fmt.Fprintf(tb.Output(), "out %d\n", 1)
+			tb.Attr("key", "value")
+
+			dir = tb.ArtifactDir()
+			if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+				t.Errorf("ArtifactDir() did not create a directory: %v", err)
+			}
+		})
+
+		if wd, _ := os.Getwd(); wd != cwd {
+			t.Errorf("working directory not restored: %s", wd)
+		}
+		if _, err := os.Stat(dir); !os.IsNotExist(err) {
+			t.Errorf("ArtifactDir() directory not removed: %v", err)
+		}
+		td.Cmp(t, tb.Messages(), []string{"out 1"})
+		td.Cmp(t, tb.CallsOf("Output", "Attr"), []tdutil.TBCall{
+			{Method: "Output", Args: []interface{}{"out 1\n"}, Message: "out 1"},
+			{Method: "Attr", Args: []interface{}{"key", "value"}},
+		})
+	})
+
+	// All testing.TB methods have to be implemented by TB, as the
+	// embedded testing.TB is nil
+	t.Run("testing.TB methods", func(t *testing.T) {
+		tbType := reflect.TypeOf((*testing.TB)(nil)).Elem()
+		for i := 0; i < tbType.NumMethod(); i++ {
+			m := tbType.Method(i)
+			if m.PkgPath != "" {
+				continue
+			}
+
+			var in []reflect.Value
+			for j := 0; j < m.Type.NumIn(); j++ {
+				if m.Type.IsVariadic() && j == m.Type.NumIn()-1 {
+					break
+				}
+				arg := reflect.New(m.Type.In(j)).Elem()
+				if arg.Kind() == reflect.Func {
+					arg = reflect.MakeFunc(arg.Type(),
+						func([]reflect.Value) []reflect.Value { return nil })
+				}
+				in = append(in, arg)
+			}
+
+			func() {
+				defer func() {
+					if r := recover(); r != nil {
+						t.Errorf("%s method is not implemented: %v", m.Name, r)
+					}
+				}()
+				tdutil.NewTB("TestMethod").Start(func(tb *tdutil.TB) {
+					reflect.ValueOf(tb).MethodByName(m.Name).Call(in)
+				})
+			}()
+		}
+	})
+
+	t.Run("Panic", func(t *testing.T) {
+		tb := tdutil.NewTB("TestPanic")
+
+		var cleanup bool
+		func() {
+			defer func() {
+				if r := recover(); r != "boom" {
+					t.Errorf("panic not propagated: %v", r)
+				}
+			}()
+			tb.Start(func(tb *tdutil.TB) {
+				tb.Cleanup(func() { cleanup = true })
+				panic("boom")
+			})
+		}()
+		if !cleanup {
+			t.Error("cleanup function not called after panic")
+		}
+	})
+
+	t.Run("Run", func(t *testing.T) {
+		tb := tdutil.NewTB("TestRun")
+		ok := tb.Start(func(tb *tdutil.TB) {
+			if !tb.Run("ok", func(tb *tdutil.TB) { tb.Log("ok") }) {
+				t.Error("Run(ok) failed")
+			}
+			if tb.Failed() {
+				t.Error("parent failed too early")
+			}
+			if tb.Run("ko", func(tb *tdutil.TB) { tb.Fatal("ko") }) {
+				t.Error("Run(ko) succeeded")
+			}
+		})
+		if ok {
+			t.Error("subtest failure not propagated")
+		}
+
+		subs := tb.Subs()
+		if len(subs) != 2 ||
+			subs[0].Name() != "TestRun/ok" || subs[1].Name() != "TestRun/ko" {
+			t.Fatalf("bad subtests: %v", subs)
+		}
+		if tb.Sub("ko") != subs[1] || tb.Sub("unknown") != nil {
+			t.Error("Sub() does not find subtests")
+		}
+		if subs[0].Failed() || !subs[1].IsFatal() {
+			t.Error("bad subtests state")
+		}
+		if calls := tb.CallsOf("Run"); len(calls) != 2 || calls[1].Args[0] != "ko" {
+			t.Errorf("bad Run calls: %v", calls)
+		}
+		if msgs := tb.Messages(); len(msgs) != 0 {
+			t.Errorf("subtests messages leaked in parent: %q", msgs)
+		}
+	})
+
+	t.Run("td", func(t *testing.T) {
+		tb := tdutil.NewTB("TestTD")
+		tb.Start(func(tb *tdutil.TB) {
+			tdt := td.NewT(tb)
+			tdt.Run("sub", func(tdt *td.T) {
+				tdt.Cmp(1, 2, "one is two")
+				tdt.FailureIsFatal().Cmp(3, 4)
+				tdt.Cmp(5, 6)
+			})
+			tdt.Cmp(true, true)
+		})
+
+		td.Cmp(t, tb.Failed(), true)
+		td.CmpNot(t, tb.Sub("sub"), nil)
+		td.Cmp(t, tb.Sub("sub").Errors(), td.All(
+			td.Len(2),
+			td.ArrayEach(td.Contains("values differ")),
+		))
+		td.Cmp(t, tb.Sub("sub").Errors()[0], td.Contains("Failed test 'one is two'"))
+		td.CmpTrue(t, tb.Sub("sub").IsFatal())
+		td.CmpGt(t, tb.Sub("sub").HelperCalls(), 0)
+		td.Cmp(t, tb.CallsOf("Error", "Fatal"), td.Empty())
+	})
+}
@@ -11,14 +11,25 @@ import (
 	"fmt"
 	"os"
 	"reflect"
+	"strconv"
 	"strings"
 	"sync"
 )
 
 const (
 	// EnvColor is the name of the environment variable allowing to
-	// enable/disable coloring feature.
+	// enable/disable coloring feature. "on" forces coloring, "off"
+	// (or any other non-empty value) disables it. If empty or unset,
+	// coloring is automatically enabled or disabled, see Enabled.
 	EnvColor = "TESTDEEP_COLOR"
+	// EnvNoColor is the name of the environment variable allowing to
+	// disable coloring feature, following https://no-color.org/
+	// convention. It is ignored if TESTDEEP_COLOR is set.
+	EnvNoColor = "NO_COLOR"
+	// EnvColorTheme is the name of the environment variable
+	// containing the name of the theme giving the default color of
+	// each role. See Themes.
+	EnvColorTheme = "TESTDEEP_COLOR_THEME"
 	// EnvColorTestName is the name of the environment variable
 	// containing the color of test names in error reports.
 	EnvColorTestName = "TESTDEEP_COLOR_TEST_NAME"
@@ -31,6 +42,22 @@ const (
 	// EnvColorBad is the name of the environment variable
 	// containing the color of "got" in error reports.
 	EnvColorBad = "TESTDEEP_COLOR_BAD"
+	// EnvColorPath is the name of the environment variable
+	// containing the color of the path of the failing data in error
+	// reports.
+	EnvColorPath = "TESTDEEP_COLOR_PATH"
+	// EnvColorOperator is the name of the environment variable
+	// containing the color of operator names in error reports.
+	EnvColorOperator = "TESTDEEP_COLOR_OPERATOR"
+	// EnvColorLocation is the name of the environment variable
+	// containing the color of source locations in error reports.
+	EnvColorLocation = "TESTDEEP_COLOR_LOCATION"
+	// EnvColorDiffInsert is the name of the environment variable
+	// containing the color of extra data in error reports.
+	EnvColorDiffInsert = "TESTDEEP_COLOR_DIFF_INSERT"
+	// EnvColorDiffDelete is the name of the environment variable
+	// containing the color of missing data in error reports.
+	EnvColorDiffDelete = "TESTDEEP_COLOR_DIFF_DELETE"
 )
 
 var (
@@ -58,6 +85,40 @@ var (
 	BadOnBold string
 	// BadOff contains the ANSI color escape sequence to turn "got" color off.
 	BadOff string
+	// PathOn contains the ANSI color escape sequence to turn path color on.
+	PathOn string
+	// PathOff contains the ANSI color escape sequence to turn path color off.
+	PathOff string
+	// OperatorOn contains the ANSI color escape sequence to turn
+	// operator name color on.
+	OperatorOn string
+	// OperatorOff contains the ANSI color escape sequence to turn
+	// operator name color off.
+	OperatorOff string
+	// LocationOn contains the ANSI color escape sequence to turn
+	// location color on.
+	LocationOn string
+	// LocationOff contains the ANSI color escape sequence to turn
+	// location color off.
+	LocationOff string
+	// DiffInsertOn contains the ANSI color escape sequence to turn
+	// extra data color on.
+	DiffInsertOn string
+	// DiffInsertOnBold contains the ANSI color escape sequence to turn
+	// extra data color and bold on.
+	DiffInsertOnBold string
+	// DiffInsertOff contains the ANSI color escape sequence to turn
+	// extra data color off.
+	DiffInsertOff string
+	// DiffDeleteOn contains the ANSI color escape sequence to turn
+	// missing data color on.
+	DiffDeleteOn string
+	// DiffDeleteOnBold contains the ANSI color escape sequence to turn
+	// missing data color and bold on.
+	DiffDeleteOnBold string
+	// DiffDeleteOff contains the ANSI color escape sequence to turn
+	// missing data color off.
+	DiffDeleteOff string
 )
 
 var initOnce sync.Once
@@ -67,10 +128,18 @@ var initOnce sync.Once
 // effective.
 func Init() {
 	initOnce.Do(func() {
-		_, TestNameOn, TestNameOff = FromEnv(EnvColorTestName, "yellow")
-		_, TitleOn, TitleOff = FromEnv(EnvColorTitle, "cyan")
-		OKOn, OKOnBold, OKOff = FromEnv(EnvColorOK, "green")
-		BadOn, BadOnBold, BadOff = FromEnv(EnvColorBad, "red")
+		theme := CurrentTheme()
+		_, TestNameOn, TestNameOff = FromEnv(EnvColorTestName, theme.TestName)
+		_, TitleOn, TitleOff = FromEnv(EnvColorTitle, theme.Title)
+		OKOn, OKOnBold, OKOff = FromEnv(EnvColorOK, theme.OK)
+		BadOn, BadOnBold, BadOff = FromEnv(EnvColorBad, theme.Bad)
+		PathOn, _, PathOff = FromEnv(EnvColorPath, theme.Path)
+		OperatorOn, _, OperatorOff = FromEnv(EnvColorOperator, theme.Operator)
+		LocationOn, _, LocationOff = FromEnv(EnvColorLocation, theme.Location)
+		DiffInsertOn, DiffInsertOnBold, DiffInsertOff =
+			FromEnv(EnvColorDiffInsert, theme.DiffInsert)
+		DiffDeleteOn, DiffDeleteOnBold, DiffDeleteOff =
+			FromEnv(EnvColorDiffDelete, theme.DiffDelete)
 	})
 }
 
@@ -117,68 +186,78 @@ var colors = map[string]byte{
 // color contained in the environment variable env. defaultColor is
 // used if the environment variable does exist or is empty.
 //
-// If coloring is disabled, returns "", "", "".
+// A color is specified as "FOREGROUND[:BACKGROUND]", each part being
+// optional and either a color name (black, red, green, yellow, blue,
+// magenta, cyan, white or gray), a 256-colors palette index (0 to
+// 255) or a "#rrggbb" true color.
+//
+// If coloring is disabled, returns "", "", "". See Enabled.
 func FromEnv(env, defaultColor string) (string, string, string) {
-	var color string
-	switch os.Getenv(EnvColor) {
-	case "on", "":
-		if curColor := os.Getenv(env); curColor != "" {
-			color = curColor
-		} else {
-			color = defaultColor
-		}
-	default: // "off" or any other value
-		color = ""
+	if !Enabled() {
+		return "", "", ""
 	}
 
+	color := os.Getenv(env)
+	if color == "" {
+		color = defaultColor
+	}
 	if color == "" {
 		return "", "", ""
 	}
 
 	names := strings.SplitN(color, ":", 2)
 
-	light := [...]byte{
-		//   0    1    2    4    4    5    6
-		'\x1b', '[', '0', ';', '3', 'y', 'm', // foreground
-		//   7    8    9   10   11
-		'\x1b', '[', '4', 'z', 'm', // background
-	}
-	bold := [...]byte{
-		//   0    1    2    4    4    5    6
-		'\x1b', '[', '1', ';', '3', 'y', 'm', // foreground
-		//   7    8    9   10   11
-		'\x1b', '[', '4', 'z', 'm', // background
-	}
-
-	var start, end int
+	var light, bold string
 
 	// Foreground
 	if names[0] != "" {
-		c := colors[names[0]]
-		if c == 0 {
-			c = colors[defaultColor]
+		code, ok := colorCode(names[0], '3')
+		if !ok {
+			code, ok = colorCode(strings.SplitN(defaultColor, ":", 2)[0], '3')
+		}
+		if ok {
+			light = "\x1b[0;" + code + "m"
+			bold = "\x1b[1;" + code + "m"
 		}
-
-		light[5] = c
-		bold[5] = c
-
-		end = 7
-	} else {
-		start = 7
 	}
 
 	// Background
 	if len(names) > 1 && names[1] != "" {
-		c := colors[names[1]]
-		if c != 0 {
-			light[10] = c
-			bold[10] = c
+		if code, ok := colorCode(names[1], '4'); ok {
+			light += "\x1b[" + code + "m"
+			bold += "\x1b[" + code + "m"
+		}
+	}
+
+	if light == "" {
+		return "", "", ""
+	}
+	return light, bold, "\x1b[0m"
+}
+
+// colorCode returns the ANSI SGR parameters corresponding to the
+// color "name", "ground" being '3' for foreground or '4' for
+// background. It returns false if "name" is not a valid color.
+func colorCode(name string, ground byte) (string, bool) {
+	if c := colors[name]; c != 0 {
+		return string([]byte{ground, c}), true
+	}
+
+	// 256-colors palette
+	if n, err := strconv.ParseUint(name, 10, 8); err == nil {
+		return string(ground) + "8;5;" + strconv.FormatUint(n, 10), true
+	}
 
-			end = 12
+	// True color
+	if len(name) == 7 && name[0] == '#' {
+		rgb, err := strconv.ParseUint(name[1:], 16, 32)
+		if err == nil {
+			return fmt.Sprintf("%c8;2;%d;%d;%d",
+				ground, rgb>>16, (rgb>>8)&0xff, rgb&0xff), true
 		}
 	}
 
-	return string(light[start:end]), string(bold[start:end]), "\x1b[0m"
+	return "", false
 }
 
 // AppendTestNameOn enables test name color in b.
@@ -39,7 +39,7 @@ func TestColor(t *testing.T) {
 	defer func() {
 		color.TestNameOn, color.TestNameOff = colorTestNameOnSave, colorTestNameOffSave
 	}()
-	for _, flag := range []string{"on", ""} {
+	for _, flag := range []string{"on"} {
 		os.Setenv("TESTDEEP_COLOR", flag)
 		os.Setenv("MY_TEST_COLOR", "")
 		light, bold, off := color.FromEnv("MY_TEST_COLOR", "red")
@@ -75,6 +75,27 @@ func TestColor(t *testing.T) {
 		test.EqualStr(t, bold, "\x1b[1;31m")  // bold red
 		test.EqualStr(t, off, "\x1b[0m")
 
+		// on + 256-colors palette
+		os.Setenv("MY_TEST_COLOR", "208:17")
+		light, bold, off = color.FromEnv("MY_TEST_COLOR", "red")
+		test.EqualStr(t, light, "\x1b[0;38;5;208m\x1b[48;5;17m")
+		test.EqualStr(t, bold, "\x1b[1;38;5;208m\x1b[48;5;17m")
+		test.EqualStr(t, off, "\x1b[0m")
+
+		// on + true color
+		os.Setenv("MY_TEST_COLOR", "#ff8000:#00000a")
+		light, bold, off = color.FromEnv("MY_TEST_COLOR", "red")
+		test.EqualStr(t, light, "\x1b[0;38;2;255;128;0m\x1b[48;2;0;0;10m")
+		test.EqualStr(t, bold, "\x1b[1;38;2;255;128;0m\x1b[48;2;0;0;10m")
+		test.EqualStr(t, off, "\x1b[0m")
+
+		// on + bad extended colors, extended default
+		os.Setenv("MY_TEST_COLOR", "256:#zzzzzz")
+		light, bold, off = color.FromEnv("MY_TEST_COLOR", "42")
+		test.EqualStr(t, light, "\x1b[0;38;5;42m")
+		test.EqualStr(t, bold, "\x1b[1;38;5;42m")
+		test.EqualStr(t, off, "\x1b[0m")
+
 		// Color test name
 		_, color.TestNameOn, color.TestNameOff = color.FromEnv(color.EnvColorTitle, "yellow")
 		var b bytes.Buffer
@@ -182,3 +203,34 @@ func TestUnBad(t *testing.T) {
 	}
 	test.EqualStr(t, color.UnBad(s), mesg)
 }
+
+func TestThemes(t *testing.T) {
+	defer color.SaveState(true)()
+	defer os.Unsetenv(color.EnvColorTheme) //nolint: errcheck
+
+	for name, theme := range color.Themes {
+		os.Setenv(color.EnvColorTheme, name)
+		test.EqualStr(t, color.CurrentTheme().Title, theme.Title, name)
+
+		for _, c := range []string{
+			theme.TestName, theme.Title, theme.OK, theme.Bad, theme.Path,
+			theme.Operator, theme.Location, theme.DiffInsert, theme.DiffDelete,
+		} {
+			light, _, _ := color.FromEnv("MY_TEST_COLOR_UNSET", c)
+			if light == "" {
+				t.Errorf("theme %s: invalid color %q", name, c)
+			}
+		}
+	}
+
+	os.Setenv(color.EnvColorTheme, "unknown")
+	test.EqualStr(t, color.CurrentTheme().Title,
+		color.Themes[color.DefaultTheme].Title)
+
+	// Roles colors follow the theme
+	color.SaveState(true)
+	os.Setenv(color.EnvColorTheme, "256")
+	color.Init()
+	test.EqualStr(t, color.PathOn, "\x1b[0;38;5;252m")
+	test.EqualStr(t, color.DiffDeleteOnBold, "\x1b[1;38;5;203m")
+}
@@ -0,0 +1,133 @@
+// Copyright (c) 2021, Maxime Soulé
+// All rights reserved.
+//
+// This source code is licensed under the BSD-style license found in the
+// LICENSE file in the root directory of this source tree.
+
+package color
+
+import (
+	"os"
+)
+
+// Theme gives the default color of each role, using the
+// "FOREGROUND[:BACKGROUND]" syntax described in FromEnv. Each color
+// can be overridden by its own environment variable.
+type Theme struct {
+	TestName   string
+	Title      string
+	OK         string
+	Bad        string
+	Path       string
+	Operator   string
+	Location   string
+	DiffInsert string
+	DiffDelete string
+}
+
+// DefaultTheme is the name of the theme used when TESTDEEP_COLOR_THEME
+// environment variable is empty, unset or contains an unknown theme
+// name.
+const DefaultTheme = "dark"
+
+// Themes contains all the available themes, by name.
+var Themes = map[string]Theme{
+	"dark": {
+		TestName:   "yellow",
+		Title:      "cyan",
+		OK:         "green",
+		Bad:        "red",
+		Path:       "white",
+		Operator:   "magenta",
+		Location:   "blue",
+		DiffInsert: "green",
+		DiffDelete: "red",
+	},
+	"light": {
+		TestName:   "magenta",
+		Title:      "blue",
+		OK:         "green",
+		Bad:        "red",
+		Path:       "black",
+		Operator:   "magenta",
+		Location:   "blue",
+		DiffInsert: "green",
+		DiffDelete: "red",
+	},
+	"high-contrast": {
+		TestName:   "black:yellow",
+		Title:      "black:cyan",
+		OK:         "black:green",
+		Bad:        "white:red",
+		Path:       "black:white",
+		Operator:   "white:magenta",
+		Location:   "white:blue",
+		DiffInsert: "black:green",
+		DiffDelete: "white:red",
+	},
+	"256": {
+		TestName:   "214",
+		Title:      "75",
+		OK:         "114",
+		Bad:        "203",
+		Path:       "252",
+		Operator:   "177",
+		Location:   "110",
+		DiffInsert: "114",
+		DiffDelete: "203",
+	},
+	"truecolor": {
+		TestName:   "#ffaf00",
+		Title:      "#5fafff",
+		OK:         "#87d787",
+		Bad:        "#ff5f5f",
+		Path:       "#d0d0d0",
+		Operator:   "#d787ff",
+		Location:   "#87afd7",
+		DiffInsert: "#87d787",
+		DiffDelete: "#ff5f5f",
+	},
+}
+
+// CurrentTheme returns the theme named in TESTDEEP_COLOR_THEME
+// environment variable, or DefaultTheme one.
+func CurrentTheme() Theme {
+	if theme, ok := Themes[os.Getenv(EnvColorTheme)]; ok {
+		return theme
+	}
+	return Themes[DefaultTheme]
+}
+
+// isTerminal reports whether "f" is a terminal. It is a variable to
+// be overridden in tests.
+var isTerminal = func(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// Enabled reports whether coloring is enabled.
+//
+// If TESTDEEP_COLOR environment variable is "on", coloring is
+// enabled. If it is not empty, coloring is disabled. Otherwise
+// coloring is automatically:
+//   - disabled if NO_COLOR environment variable is not empty;
+//   - disabled if TERM environment variable is "dumb";
+//   - enabled only if the standard output is a terminal.
+//
+// Note that "go test" does not run tests with a terminal as output
+// when testing several packages, so TESTDEEP_COLOR has to be set to
+// "on" to get colors in this case.
+func Enabled() bool {
+	switch os.Getenv(EnvColor) {
+	case "on":
+		return true
+	case "":
+	default: // "off" or any other value
+		return false
+	}
+
+	if os.Getenv(EnvNoColor) != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	return isTerminal(os.Stdout)
+}
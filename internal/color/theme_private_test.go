@@ -0,0 +1,71 @@
+// Copyright (c) 2021, Maxime Soulé
+// All rights reserved.
+//
+// This source code is licensed under the BSD-style license found in the
+// LICENSE file in the root directory of this source tree.
+
+package color
+
+import (
+	"os"
+	"testing"
+)
+
+func TestEnabled(t *testing.T) {
+	saveEnv := func(envs ...string) func() {
+		restore := make([]func(), len(envs))
+		for i, env := range envs {
+			if v, set := os.LookupEnv(env); set {
+				restore[i] = func() { os.Setenv(env, v) } //nolint: errcheck
+			} else {
+				restore[i] = func() { os.Unsetenv(env) } //nolint: errcheck
+			}
+			os.Unsetenv(env) //nolint: errcheck
+		}
+		return func() {
+			for _, fn := range restore {
+				fn()
+			}
+		}
+	}
+	defer saveEnv(EnvColor, EnvNoColor, "TERM")()
+
+	origIsTerminal := isTerminal
+	defer func() { isTerminal = origIsTerminal }()
+
+	terminal := false
+	isTerminal = func(*os.File) bool { return terminal }
+
+	check := func(expected bool, name string) {
+		t.Helper()
+		if got := Enabled(); got != expected {
+			t.Errorf("%s: Enabled() = %t, expected %t", name, got, expected)
+		}
+	}
+
+	check(false, "not a terminal")
+
+	os.Setenv(EnvColor, "on")
+	check(true, "TESTDEEP_COLOR=on, not a terminal")
+	os.Unsetenv(EnvColor)
+
+	terminal = true
+	check(true, "terminal")
+
+	os.Setenv("TERM", "dumb")
+	check(false, "dumb terminal")
+	os.Unsetenv("TERM")
+
+	os.Setenv(EnvNoColor, "1")
+	check(false, "NO_COLOR")
+
+	os.Setenv(EnvColor, "on")
+	check(true, "TESTDEEP_COLOR=on overrides NO_COLOR")
+
+	os.Setenv(EnvColor, "off")
+	os.Unsetenv(EnvNoColor)
+	check(false, "TESTDEEP_COLOR=off")
+
+	os.Setenv(EnvColor, "bad")
+	check(false, "TESTDEEP_COLOR=bad")
+}
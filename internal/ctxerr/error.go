@@ -9,6 +9,7 @@ package ctxerr
 import (
 	"bytes"
 	"reflect"
+	"strconv"
 	"strings"
 
 	"github.com/maxatome/go-testdeep/internal/color"
@@ -136,10 +137,10 @@ func (e *Error) Append(buf *bytes.Buffer, prefix string) {
 	buf.WriteString(color.TitleOn)
 	if pos := strings.Index(e.Message, "%%"); pos >= 0 {
 		buf.WriteString(e.Message[:pos])
-		buf.WriteString(e.Context.Path.String())
+		e.appendPath(buf)
 		buf.WriteString(e.Message[pos+2:])
 	} else {
-		buf.WriteString(e.Context.Path.String())
+		e.appendPath(buf)
 		buf.WriteString(": ")
 		buf.WriteString(e.Message)
 	}
@@ -176,7 +177,7 @@ func (e *Error) Append(buf *bytes.Buffer, prefix string) {
 		(e.Next == nil || e.Next.Location != e.Location) {
 		writeEolPrefix()
 		buf.WriteString("[under operator ")
-		buf.WriteString(e.Location.String())
+		appendLocation(buf, e.Location)
 		buf.WriteByte(']')
 	}
 
@@ -186,6 +187,32 @@ func (e *Error) Append(buf *bytes.Buffer, prefix string) {
 	}
 }
 
+// appendPath appends the path of the Error inside its title.
+func (e *Error) appendPath(buf *bytes.Buffer) {
+	buf.WriteString(color.PathOn)
+	buf.WriteString(e.Context.Path.String())
+	if color.PathOff != "" {
+		buf.WriteString(color.PathOff)
+		buf.WriteString(color.TitleOn) // back to title color
+	}
+}
+
+// appendLocation appends "loc" as location.Location.String does,
+// coloring the operator name and its location.
+func appendLocation(buf *bytes.Buffer, loc location.Location) {
+	buf.WriteString(color.OperatorOn)
+	buf.WriteString(loc.Func)
+	buf.WriteString(color.OperatorOff)
+	buf.WriteByte(' ')
+	buf.WriteString(loc.Inside)
+	buf.WriteString("at ")
+	buf.WriteString(color.LocationOn)
+	buf.WriteString(loc.File)
+	buf.WriteByte(':')
+	buf.WriteString(strconv.Itoa(loc.Line))
+	buf.WriteString(color.LocationOff)
+}
+
 // GotString returns the string corresponding to the Got
 // field. Returns the empty string if the Error Summary field is not
 // nil.
@@ -210,6 +210,30 @@ DATA[13].Field: Error message
 		`Too many errors (use TESTDEEP_MAX_ERRORS=-1 to see all)`)
 }
 
+func TestErrorColors(t *testing.T) {
+	defer color.SaveState(true)()
+
+	err := ctxerr.Error{
+		Context: ctxerr.Context{
+			Path: ctxerr.NewPath("DATA").AddField("Field"),
+		},
+		Message:  "Value of %% differ",
+		Got:      1,
+		Expected: 2,
+		Location: location.Location{
+			File:   "file.go",
+			Func:   "Operator",
+			Inside: "inside ",
+			Line:   23,
+		},
+	}
+	test.EqualStr(t, err.Error(),
+		"\x1b[1;36mValue of \x1b[0;37mDATA.Field\x1b[0m\x1b[1;36m differ\x1b[0m\n"+
+			"\x1b[1;31m\t     got: \x1b[0;31m1\x1b[0m\n"+
+			"\x1b[1;32m\texpected: \x1b[0;32m2\x1b[0m\n"+
+			"[under operator \x1b[0;35mOperator\x1b[0m inside at \x1b[0;34mfile.go:23\x1b[0m]")
+}
+
 func TestTypeMismatch(t *testing.T) {
 	rErr := ctxerr.TypeMismatch(reflect.TypeOf(0), reflect.TypeOf(""))
 	test.EqualStr(t, rErr.Message, "type mismatch")
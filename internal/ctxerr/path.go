@@ -156,6 +156,38 @@ func (p Path) AddCustomLevel(custom string) Path {
 	})
 }
 
+// ArrayIndexAt returns the index of the array level at position
+// "pos" of "p" and true if it is an array index level, -1 and false
+// otherwise.
+func (p Path) ArrayIndexAt(pos int) (int, bool) {
+	if pos < 0 || pos >= len(p) || p[pos].Kind != levelArray {
+		return -1, false
+	}
+	index, err := strconv.Atoi(p[pos].Content)
+	if err != nil {
+		return -1, false
+	}
+	return index, true
+}
+
+// ReplaceLevels returns a new Path where the "num" levels starting
+// at position "pos" of "p" are replaced by a custom level "custom".
+// The pointers of the last replaced level are kept.
+func (p Path) ReplaceLevels(pos, num int, custom string) Path {
+	if p == nil {
+		return nil
+	}
+
+	np := make(Path, 0, len(p)-num+1)
+	np = append(np, p[:pos]...)
+	np = append(np, pathLevel{
+		Kind:     levelCustom,
+		Content:  custom,
+		Pointers: p[pos+num-1].Pointers,
+	})
+	return append(np, p[pos+num:]...)
+}
+
 func (p Path) String() string {
 	if len(p) == 0 {
 		return ""
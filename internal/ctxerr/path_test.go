@@ -197,6 +197,33 @@ func TestEqual(t *testing.T) {
 	test.IsFalse(t, path.Equal(ctxerr.NewPath("DATA").AddPtr(2).AddField("field2")))
 }
 
+func TestReplaceLevels(t *testing.T) {
+	path := ctxerr.NewPath("DATA").
+		AddCustomLevel(".JSONPath<$..x>").
+		AddArrayIndex(2).
+		AddMapKey("y")
+
+	index, ok := path.ArrayIndexAt(2)
+	test.IsTrue(t, ok)
+	test.EqualInt(t, index, 2)
+
+	_, ok = path.ArrayIndexAt(1)
+	test.IsFalse(t, ok)
+	_, ok = path.ArrayIndexAt(4)
+	test.IsFalse(t, ok)
+
+	newPath := path.ReplaceLevels(1, 2, ".JSONPath<$.a[2].x>")
+	test.EqualStr(t, newPath.String(), `DATA.JSONPath<$.a[2].x>["y"]`)
+	test.EqualStr(t, path.String(), `DATA.JSONPath<$..x>[2]["y"]`)
+
+	test.EqualStr(t,
+		ctxerr.NewPath("DATA").AddArrayIndex(1).AddPtr(1).AddField("f").
+			ReplaceLevels(0, 2, "X").String(),
+		"X.f")
+
+	test.IsTrue(t, ctxerr.Path(nil).ReplaceLevels(0, 1, "X") == nil)
+}
+
 /*
 func BenchmarkStringString(b *testing.B) {
 	path := ctxerr.NewPath("DATA").
@@ -35,20 +35,43 @@ type ErrorSummaryItem struct {
 	Label       string
 	Value       string
 	Explanation string
+	// Diff allows to render the item as extra (DiffInsert) or missing
+	// (DiffDelete) data, instead of a bad value (NoDiff).
+	Diff DiffKind
 }
 
+// DiffKind is the kind of an ErrorSummaryItem. See ErrorSummaryItem.Diff.
+type DiffKind uint8
+
+const (
+	// NoDiff is the kind of a bad value.
+	NoDiff DiffKind = iota
+	// DiffInsert is the kind of extra data.
+	DiffInsert
+	// DiffDelete is the kind of missing data.
+	DiffDelete
+)
+
 var _ ErrorSummary = ErrorSummaryItem{}
 
 // AppendSummary implements the ErrorSummary interface.
 func (s ErrorSummaryItem) AppendSummary(buf *bytes.Buffer, prefix string) {
 	color.Init()
 
+	on, onBold, off := color.BadOn, color.BadOnBold, color.BadOff
+	switch s.Diff {
+	case DiffInsert:
+		on, onBold, off = color.DiffInsertOn, color.DiffInsertOnBold, color.DiffInsertOff
+	case DiffDelete:
+		on, onBold, off = color.DiffDeleteOn, color.DiffDeleteOnBold, color.DiffDeleteOff
+	}
+
 	buf.WriteString(prefix)
-	buf.WriteString(color.BadOnBold)
+	buf.WriteString(onBold)
 	buf.WriteString(s.Label)
 	buf.WriteString(": ")
 
-	buf.WriteString(color.BadOn)
+	buf.WriteString(on)
 	util.IndentStringIn(buf, s.Value, prefix+strings.Repeat(" ", len(s.Label)+2))
 
 	if s.Explanation != "" {
@@ -57,7 +80,7 @@ func (s ErrorSummaryItem) AppendSummary(buf *bytes.Buffer, prefix string) {
 		util.IndentStringIn(buf, s.Explanation, prefix)
 	}
 
-	buf.WriteString(color.BadOff)
+	buf.WriteString(off)
 }
 
 // ErrorSummaryItems implements the ErrorSummary interface and allows
@@ -113,6 +113,30 @@ func TestErrorSummary(t *testing.T) {
 ----~               ~zap^
 ----*3rd big label: +666^`))
 
+		//
+		// ErrorSummaryItem as diff
+		summary = ctxerr.ErrorSummaryItems{
+			{
+				Label: "Missing item",
+				Value: "42",
+				Diff:  ctxerr.DiffDelete,
+			},
+			{
+				Label: "Extra item",
+				Value: "24",
+				Diff:  ctxerr.DiffInsert,
+			},
+		}
+		if colored {
+			test.EqualStr(t, errorSummaryToString(summary, "----"),
+				"----\x1b[1;31mMissing item: \x1b[0;31m42\x1b[0m\n"+
+					"----\x1b[1;32m  Extra item: \x1b[0;32m24\x1b[0m")
+		} else {
+			test.EqualStr(t, errorSummaryToString(summary, "----"), `
+----Missing item: 42
+----  Extra item: 24`[1:])
+		}
+
 		//
 		// NewSummaryReason
 		summary = ctxerr.NewSummaryReason(666, "")
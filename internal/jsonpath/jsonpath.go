@@ -0,0 +1,812 @@
+// Copyright (c) 2021, Maxime Soulé
+// All rights reserved.
+//
+// This source code is licensed under the BSD-style license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package jsonpath implements JSONPath queries, as described in
+// https://goessner.net/articles/JsonPath/, on values unmarshaled by
+// encoding/json.
+package jsonpath
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Node is a value selected by a Path.
+type Node struct {
+	// Location is the normalized path of Value, as in
+	// $.store.book[0]["first-author"].
+	Location string
+	// Value is the selected value.
+	Value interface{}
+}
+
+// Path is a compiled JSONPath.
+type Path struct {
+	segments []segment
+}
+
+type segment struct {
+	descendant bool
+	selectors  []selector
+}
+
+type selector interface {
+	// apply appends to "out" the nodes selected from "node", "root"
+	// being the document root.
+	apply(node Node, root interface{}, out []Node) []Node
+}
+
+// Error is the error returned by Parse.
+type Error struct {
+	Pos     int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s at position %d", e.Message, e.Pos)
+}
+
+// Parse compiles the JSONPath "path". "path" must start with "$".
+func Parse(path string) (p *Path, err error) {
+	ps := parser{s: path}
+
+	defer func() {
+		if r := recover(); r != nil {
+			pErr, ok := r.(*Error)
+			if !ok {
+				panic(r)
+			}
+			p, err = nil, pErr
+		}
+	}()
+
+	ps.skipSpaces()
+	if !ps.eat('$') {
+		ps.fail("path must start with $")
+	}
+	segments := ps.parseSegments()
+	ps.skipSpaces()
+	if ps.pos < len(ps.s) {
+		ps.fail("unexpected %q", ps.s[ps.pos:])
+	}
+	return &Path{segments: segments}, nil
+}
+
+// Select returns all nodes of "v" selected by "p", in document
+// order. Keys of objects are walked in lexical order.
+func (p *Path) Select(v interface{}) []Node {
+	return selectSegments(p.segments, Node{Location: "$", Value: v}, v)
+}
+
+func selectSegments(segments []segment, from Node, root interface{}) []Node {
+	nodes := []Node{from}
+	for _, seg := range segments {
+		var next []Node
+		for _, node := range nodes {
+			if seg.descendant {
+				next = seg.applyDescendant(node, next, root)
+			} else {
+				next = seg.apply(node, next, root)
+			}
+		}
+		nodes = next
+		if len(nodes) == 0 {
+			break
+		}
+	}
+	return nodes
+}
+
+func (s segment) apply(node Node, out []Node, root interface{}) []Node {
+	for _, sel := range s.selectors {
+		out = sel.apply(node, root, out)
+	}
+	return out
+}
+
+func (s segment) applyDescendant(node Node, out []Node, root interface{}) []Node {
+	out = s.apply(node, out, root)
+	for _, child := range children(node) {
+		out = s.applyDescendant(child, out, root)
+	}
+	return out
+}
+
+// children returns the children of "node", object members being
+// sorted by key.
+func children(node Node) []Node {
+	switch v := node.Value.(type) {
+	case []interface{}:
+		nodes := make([]Node, len(v))
+		for i, item := range v {
+			nodes[i] = Node{Location: indexLocation(node.Location, i), Value: item}
+		}
+		return nodes
+
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		nodes := make([]Node, len(keys))
+		for i, key := range keys {
+			nodes[i] = Node{Location: nameLocation(node.Location, key), Value: v[key]}
+		}
+		return nodes
+	}
+	return nil
+}
+
+func indexLocation(loc string, index int) string {
+	return loc + "[" + strconv.Itoa(index) + "]"
+}
+
+func nameLocation(loc, name string) string {
+	if isIdentifier(name) {
+		return loc + "." + name
+	}
+	return loc + "[" + strconv.Quote(name) + "]"
+}
+
+func isIdentifier(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		if !isNameRune(r) || (i == 0 && r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+func isNameRune(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' ||
+		r >= '0' && r <= '9' || r == '_' || r >= 0x80
+}
+
+//
+// Selectors
+//
+
+type nameSelector string
+
+func (s nameSelector) apply(node Node, root interface{}, out []Node) []Node {
+	if m, ok := node.Value.(map[string]interface{}); ok {
+		if v, exists := m[string(s)]; exists {
+			out = append(out, Node{Location: nameLocation(node.Location, string(s)), Value: v})
+		}
+	}
+	return out
+}
+
+type wildcardSelector struct{}
+
+func (wildcardSelector) apply(node Node, root interface{}, out []Node) []Node {
+	return append(out, children(node)...)
+}
+
+type indexSelector int
+
+func (s indexSelector) apply(node Node, root interface{}, out []Node) []Node {
+	if a, ok := node.Value.([]interface{}); ok {
+		i := int(s)
+		if i < 0 {
+			i += len(a)
+		}
+		if i >= 0 && i < len(a) {
+			out = append(out, Node{Location: indexLocation(node.Location, i), Value: a[i]})
+		}
+	}
+	return out
+}
+
+type sliceSelector struct {
+	start, end *int
+	step       int
+}
+
+func (s *sliceSelector) apply(node Node, root interface{}, out []Node) []Node {
+	a, ok := node.Value.([]interface{})
+	if !ok || s.step == 0 {
+		return out
+	}
+
+	normalize := func(i int) int {
+		if i < 0 {
+			return i + len(a)
+		}
+		return i
+	}
+	clamp := func(i, min, max int) int {
+		if i < min {
+			return min
+		}
+		if i > max {
+			return max
+		}
+		return i
+	}
+
+	if s.step > 0 {
+		start, end := 0, len(a)
+		if s.start != nil {
+			start = normalize(*s.start)
+		}
+		if s.end != nil {
+			end = normalize(*s.end)
+		}
+		for i := clamp(start, 0, len(a)); i < clamp(end, 0, len(a)); i += s.step {
+			out = append(out, Node{Location: indexLocation(node.Location, i), Value: a[i]})
+		}
+		return out
+	}
+
+	start, end := len(a)-1, -len(a)-1
+	if s.start != nil {
+		start = normalize(*s.start)
+	}
+	if s.end != nil {
+		end = normalize(*s.end)
+	}
+	lower := clamp(end, -1, len(a)-1)
+	for i := clamp(start, -1, len(a)-1); i > lower; i += s.step {
+		out = append(out, Node{Location: indexLocation(node.Location, i), Value: a[i]})
+	}
+	return out
+}
+
+type filterSelector struct {
+	expr expr
+}
+
+func (s filterSelector) apply(node Node, root interface{}, out []Node) []Node {
+	for _, child := range children(node) {
+		if s.expr.test(child.Value, root) {
+			out = append(out, child)
+		}
+	}
+	return out
+}
+
+//
+// Filter expressions
+//
+
+type expr interface {
+	test(current, root interface{}) bool
+}
+
+type orExpr []expr
+
+func (e orExpr) test(current, root interface{}) bool {
+	for _, sub := range e {
+		if sub.test(current, root) {
+			return true
+		}
+	}
+	return false
+}
+
+type andExpr []expr
+
+func (e andExpr) test(current, root interface{}) bool {
+	for _, sub := range e {
+		if !sub.test(current, root) {
+			return false
+		}
+	}
+	return true
+}
+
+type notExpr struct{ expr }
+
+func (e notExpr) test(current, root interface{}) bool {
+	return !e.expr.test(current, root)
+}
+
+// existExpr is true if its query selects at least one node.
+type existExpr struct{ query *queryOperand }
+
+func (e existExpr) test(current, root interface{}) bool {
+	return len(e.query.selectNodes(current, root)) > 0
+}
+
+type cmpExpr struct {
+	op          string
+	left, right operand
+	re          *regexp.Regexp
+}
+
+func (e *cmpExpr) test(current, root interface{}) bool {
+	left, lok := e.left.value(current, root)
+	if e.re != nil {
+		s, ok := left.(string)
+		return lok && ok && e.re.MatchString(s)
+	}
+	right, rok := e.right.value(current, root)
+
+	switch e.op {
+	case "==":
+		return equal(left, lok, right, rok)
+	case "!=":
+		return !equal(left, lok, right, rok)
+	case "<":
+		return less(left, lok, right, rok)
+	case "<=":
+		return less(left, lok, right, rok) || equal(left, lok, right, rok)
+	case ">":
+		return less(right, rok, left, lok)
+	default: // ">="
+		return less(right, rok, left, lok) || equal(left, lok, right, rok)
+	}
+}
+
+func equal(a interface{}, aok bool, b interface{}, bok bool) bool {
+	if !aok || !bok {
+		return aok == bok
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func less(a interface{}, aok bool, b interface{}, bok bool) bool {
+	if !aok || !bok {
+		return false
+	}
+	switch a := a.(type) {
+	case float64:
+		b, ok := b.(float64)
+		return ok && a < b
+	case string:
+		b, ok := b.(string)
+		return ok && a < b
+	}
+	return false
+}
+
+type operand interface {
+	// value returns the value of the operand. The boolean is false if
+	// the operand does not designate any value.
+	value(current, root interface{}) (interface{}, bool)
+}
+
+type literalOperand struct{ v interface{} }
+
+func (o literalOperand) value(current, root interface{}) (interface{}, bool) {
+	return o.v, true
+}
+
+type queryOperand struct {
+	relative bool
+	segments []segment
+}
+
+func (o *queryOperand) selectNodes(current, root interface{}) []Node {
+	from := root
+	if o.relative {
+		from = current
+	}
+	return selectSegments(o.segments, Node{Value: from}, root)
+}
+
+// value returns the value of the query, only if it selects exactly
+// one node.
+func (o *queryOperand) value(current, root interface{}) (interface{}, bool) {
+	nodes := o.selectNodes(current, root)
+	if len(nodes) != 1 {
+		return nil, false
+	}
+	return nodes[0].Value, true
+}
+
+//
+// Parser
+//
+
+type parser struct {
+	s   string
+	pos int
+}
+
+func (p *parser) fail(format string, args ...interface{}) {
+	panic(&Error{Pos: p.pos, Message: fmt.Sprintf(format, args...)})
+}
+
+func (p *parser) peek() byte {
+	if p.pos < len(p.s) {
+		return p.s[p.pos]
+	}
+	return 0
+}
+
+func (p *parser) eat(c byte) bool {
+	if p.peek() == c {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) eatString(s string) bool {
+	if strings.HasPrefix(p.s[p.pos:], s) {
+		p.pos += len(s)
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(c byte) {
+	p.skipSpaces()
+	if !p.eat(c) {
+		if p.pos >= len(p.s) {
+			p.fail("%q expected", c)
+		}
+		p.fail("%q expected instead of %q", c, p.s[p.pos:])
+	}
+}
+
+func (p *parser) skipSpaces() {
+	for p.pos < len(p.s) {
+		switch p.s[p.pos] {
+		case ' ', '\t', '\n', '\r':
+			p.pos++
+		default:
+			return
+		}
+	}
+}
+
+// parseSegments parses segments until something that cannot start
+// a segment is encountered.
+func (p *parser) parseSegments() []segment {
+	var segments []segment
+	for {
+		switch {
+		case p.eatString(".."):
+			seg := segment{descendant: true}
+			switch {
+			case p.peek() == '[':
+				seg.selectors = p.parseBracket()
+			case p.eat('*'):
+				seg.selectors = []selector{wildcardSelector{}}
+			default:
+				seg.selectors = []selector{nameSelector(p.parseName())}
+			}
+			segments = append(segments, seg)
+
+		case p.eat('.'):
+			if p.eat('*') {
+				segments = append(segments, segment{selectors: []selector{wildcardSelector{}}})
+			} else {
+				segments = append(segments, segment{selectors: []selector{nameSelector(p.parseName())}})
+			}
+
+		case p.peek() == '[':
+			segments = append(segments, segment{selectors: p.parseBracket()})
+
+		default:
+			return segments
+		}
+	}
+}
+
+func (p *parser) parseName() string {
+	start := p.pos
+	for p.pos < len(p.s) {
+		r, size := utf8.DecodeRuneInString(p.s[p.pos:])
+		if !isNameRune(r) {
+			break
+		}
+		p.pos += size
+	}
+	if start == p.pos {
+		p.fail("name expected")
+	}
+	return p.s[start:p.pos]
+}
+
+func (p *parser) parseBracket() []selector {
+	p.pos++ // skip [
+
+	var selectors []selector
+	for {
+		p.skipSpaces()
+		selectors = append(selectors, p.parseSelector())
+		p.skipSpaces()
+		if p.eat(']') {
+			return selectors
+		}
+		if !p.eat(',') {
+			if p.pos >= len(p.s) {
+				p.fail("']' expected")
+			}
+			p.fail("',' or ']' expected instead of %q", p.s[p.pos:])
+		}
+	}
+}
+
+func (p *parser) parseSelector() selector {
+	switch c := p.peek(); {
+	case c == '*':
+		p.pos++
+		return wildcardSelector{}
+
+	case c == '\'' || c == '"':
+		return nameSelector(p.parseString())
+
+	case c == '?':
+		p.pos++
+		return filterSelector{expr: p.parseOr()}
+
+	case c == ':' || c == '-' || c >= '0' && c <= '9':
+		var nums [3]*int
+		n := 0
+		for {
+			p.skipSpaces()
+			if c := p.peek(); c == '-' || c >= '0' && c <= '9' {
+				i := p.parseInt()
+				nums[n] = &i
+			}
+			p.skipSpaces()
+			if n == 2 || !p.eat(':') {
+				break
+			}
+			n++
+		}
+		if n == 0 {
+			if nums[0] == nil {
+				p.fail("index expected")
+			}
+			return indexSelector(*nums[0])
+		}
+		s := sliceSelector{start: nums[0], end: nums[1], step: 1}
+		if nums[2] != nil {
+			s.step = *nums[2]
+		}
+		return &s
+
+	case c == 0:
+		p.fail("selector expected")
+	}
+	p.fail("unexpected %q", p.s[p.pos:])
+	return nil
+}
+
+func (p *parser) parseInt() int {
+	start := p.pos
+	p.eat('-')
+	for c := p.peek(); c >= '0' && c <= '9'; c = p.peek() {
+		p.pos++
+	}
+	i, err := strconv.Atoi(p.s[start:p.pos])
+	if err != nil {
+		p.pos = start
+		p.fail("integer expected")
+	}
+	return i
+}
+
+func (p *parser) parseString() string {
+	quote := p.s[p.pos]
+	start := p.pos
+	p.pos++
+
+	var b []byte
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		switch {
+		case c == quote:
+			p.pos++
+			return string(b)
+
+		case c == '\\':
+			p.pos++
+			if p.pos >= len(p.s) {
+				break
+			}
+			switch e := p.s[p.pos]; e {
+			case 'b':
+				b = append(b, '\b')
+			case 'f':
+				b = append(b, '\f')
+			case 'n':
+				b = append(b, '\n')
+			case 'r':
+				b = append(b, '\r')
+			case 't':
+				b = append(b, '\t')
+			case 'u':
+				if p.pos+5 > len(p.s) {
+					p.fail("bad \\u escape")
+				}
+				r, err := strconv.ParseUint(p.s[p.pos+1:p.pos+5], 16, 16)
+				if err != nil {
+					p.fail("bad \\u escape")
+				}
+				b = append(b, string(rune(r))...)
+				p.pos += 4
+			default:
+				b = append(b, e)
+			}
+			p.pos++
+
+		default:
+			b = append(b, c)
+			p.pos++
+		}
+	}
+	p.pos = start
+	p.fail("unterminated string")
+	return ""
+}
+
+func (p *parser) parseOr() expr {
+	e := orExpr{p.parseAnd()}
+	for {
+		p.skipSpaces()
+		if !p.eatString("||") {
+			break
+		}
+		e = append(e, p.parseAnd())
+	}
+	if len(e) == 1 {
+		return e[0]
+	}
+	return e
+}
+
+func (p *parser) parseAnd() expr {
+	e := andExpr{p.parseUnary()}
+	for {
+		p.skipSpaces()
+		if !p.eatString("&&") {
+			break
+		}
+		e = append(e, p.parseUnary())
+	}
+	if len(e) == 1 {
+		return e[0]
+	}
+	return e
+}
+
+func (p *parser) parseUnary() expr {
+	p.skipSpaces()
+	if p.peek() == '!' && !strings.HasPrefix(p.s[p.pos:], "!=") {
+		p.pos++
+		return notExpr{p.parseUnary()}
+	}
+	if p.eat('(') {
+		e := p.parseOr()
+		p.expect(')')
+		return e
+	}
+	return p.parseComparison()
+}
+
+var cmpOps = []string{"==", "!=", "<=", ">=", "=~", "<", ">"}
+
+func (p *parser) parseComparison() expr {
+	start := p.pos
+	left := p.parseOperand()
+
+	p.skipSpaces()
+	var op string
+	for _, o := range cmpOps {
+		if p.eatString(o) {
+			op = o
+			break
+		}
+	}
+
+	if op == "" {
+		if q, ok := left.(*queryOperand); ok {
+			return existExpr{query: q}
+		}
+		p.pos = start
+		p.fail("a literal cannot be used alone in a filter")
+	}
+
+	e := cmpExpr{op: op, left: left}
+	p.skipSpaces()
+	if op == "=~" {
+		e.re = p.parseRegexp()
+		return &e
+	}
+	e.right = p.parseOperand()
+	return &e
+}
+
+func (p *parser) parseRegexp() *regexp.Regexp {
+	start := p.pos
+
+	var src string
+	switch p.peek() {
+	case '/':
+		p.pos++
+		var b []byte
+		for {
+			if p.pos >= len(p.s) {
+				p.pos = start
+				p.fail("unterminated regexp")
+			}
+			c := p.s[p.pos]
+			p.pos++
+			if c == '/' {
+				break
+			}
+			if c == '\\' && p.peek() == '/' {
+				c = '/'
+				p.pos++
+			}
+			b = append(b, c)
+		}
+		src = string(b)
+		if p.eat('i') {
+			src = "(?i)" + src
+		}
+
+	case '\'', '"':
+		src = p.parseString()
+
+	default:
+		p.fail("regexp expected")
+	}
+
+	re, err := regexp.Compile(src)
+	if err != nil {
+		p.pos = start
+		p.fail("bad regexp: %s", err)
+	}
+	return re
+}
+
+func (p *parser) parseOperand() operand {
+	p.skipSpaces()
+	switch c := p.peek(); {
+	case c == '@' || c == '$':
+		p.pos++
+		return &queryOperand{relative: c == '@', segments: p.parseSegments()}
+
+	case c == '\'' || c == '"':
+		return literalOperand{p.parseString()}
+
+	case c == '-' || c >= '0' && c <= '9':
+		start := p.pos
+		p.eat('-')
+		for p.pos < len(p.s) && strings.IndexByte("0123456789.eE+-", p.s[p.pos]) >= 0 {
+			p.pos++
+		}
+		f, err := strconv.ParseFloat(p.s[start:p.pos], 64)
+		if err != nil {
+			p.pos = start
+			p.fail("bad number")
+		}
+		return literalOperand{f}
+	}
+
+	switch {
+	case p.eatString("true"):
+		return literalOperand{true}
+	case p.eatString("false"):
+		return literalOperand{false}
+	case p.eatString("null"):
+		return literalOperand{nil}
+	}
+
+	if p.pos >= len(p.s) {
+		p.fail("operand expected")
+	}
+	p.fail("operand expected instead of %q", p.s[p.pos:])
+	return nil
+}
@@ -0,0 +1,186 @@
+// Copyright (c) 2021, Maxime Soulé
+// All rights reserved.
+//
+// This source code is licensed under the BSD-style license found in the
+// LICENSE file in the root directory of this source tree.
+
+package jsonpath_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/maxatome/go-testdeep/internal/jsonpath"
+	"github.com/maxatome/go-testdeep/internal/test"
+)
+
+const store = `
+{
+  "store": {
+    "book": [
+      {
+        "category": "reference",
+        "author": "Nigel Rees",
+        "title": "Sayings of the Century",
+        "price": 8.95
+      },
+      {
+        "category": "fiction",
+        "author": "Evelyn Waugh",
+        "title": "Sword of Honour",
+        "price": 12.99
+      },
+      {
+        "category": "fiction",
+        "author": "Herman Melville",
+        "title": "Moby Dick",
+        "isbn": "0-553-21311-3",
+        "price": 8.99
+      },
+      {
+        "category": "fiction",
+        "author": "J. R. R. Tolkien",
+        "title": "The Lord of the Rings",
+        "isbn": "0-395-19395-8",
+        "price": 22.99
+      }
+    ],
+    "bicycle": {
+      "color": "red",
+      "price": 19.95,
+      "sold-out": false
+    }
+  },
+  "limit": 10
+}`
+
+func TestSelect(t *testing.T) {
+	var doc interface{}
+	if err := json.Unmarshal([]byte(store), &doc); err != nil {
+		t.Fatalf("json.Unmarshal failed: %s", err)
+	}
+
+	check := func(path string, expected ...string) {
+		t.Helper()
+
+		p, err := jsonpath.Parse(path)
+		if !test.NoError(t, err, path) {
+			return
+		}
+
+		nodes := p.Select(doc)
+		got := make([]string, len(nodes))
+		for i, node := range nodes {
+			b, _ := json.Marshal(node.Value)
+			got[i] = node.Location + " = " + string(b)
+		}
+		test.EqualStr(t, strings.Join(got, "\n"), strings.Join(expected, "\n"), path)
+	}
+
+	check("$.limit", "$.limit = 10")
+	check("$['limit']", "$.limit = 10")
+	check(`$.store.bicycle["sold-out"]`, `$.store.bicycle["sold-out"] = false`)
+	check("$.store.book[*].author",
+		`$.store.book[0].author = "Nigel Rees"`,
+		`$.store.book[1].author = "Evelyn Waugh"`,
+		`$.store.book[2].author = "Herman Melville"`,
+		`$.store.book[3].author = "J. R. R. Tolkien"`)
+	check("$..price",
+		"$.store.bicycle.price = 19.95",
+		"$.store.book[0].price = 8.95",
+		"$.store.book[1].price = 12.99",
+		"$.store.book[2].price = 8.99",
+		"$.store.book[3].price = 22.99")
+	check("$.store.*",
+		`$.store.bicycle = {"color":"red","price":19.95,"sold-out":false}`,
+		`$.store.book = `+compact(t, doc, "store", "book"))
+	check("$.store.bicycle.*",
+		`$.store.bicycle.color = "red"`,
+		`$.store.bicycle.price = 19.95`,
+		`$.store.bicycle["sold-out"] = false`)
+
+	// Indexes & slices
+	check("$..book[2].title", `$.store.book[2].title = "Moby Dick"`)
+	check("$..book[-1].title", `$.store.book[3].title = "The Lord of the Rings"`)
+	check("$..book[0,1].price",
+		"$.store.book[0].price = 8.95",
+		"$.store.book[1].price = 12.99")
+	check("$..book[:2].price",
+		"$.store.book[0].price = 8.95",
+		"$.store.book[1].price = 12.99")
+	check("$..book[-2:].price",
+		"$.store.book[2].price = 8.99",
+		"$.store.book[3].price = 22.99")
+	check("$..book[::2].price",
+		"$.store.book[0].price = 8.95",
+		"$.store.book[2].price = 8.99")
+	check("$..book[::-1].price",
+		"$.store.book[3].price = 22.99",
+		"$.store.book[2].price = 8.99",
+		"$.store.book[1].price = 12.99",
+		"$.store.book[0].price = 8.95")
+	check("$..book[1:3:0].price")
+	check("$..book[10]")
+	check("$.limit[0]")
+
+	// Filters
+	check("$..book[?(@.isbn)].title",
+		`$.store.book[2].title = "Moby Dick"`,
+		`$.store.book[3].title = "The Lord of the Rings"`)
+	check("$..book[?(!@.isbn)].title",
+		`$.store.book[0].title = "Sayings of the Century"`,
+		`$.store.book[1].title = "Sword of Honour"`)
+	check("$..book[?(@.price < 10)].price",
+		"$.store.book[0].price = 8.95",
+		"$.store.book[2].price = 8.99")
+	check("$..book[?@.price >= $.limit && @.category == 'fiction'].title",
+		`$.store.book[1].title = "Sword of Honour"`,
+		`$.store.book[3].title = "The Lord of the Rings"`)
+	check(`$..book[?(@.author =~ /^j\. r/i || @.price == 8.95)].title`,
+		`$.store.book[0].title = "Sayings of the Century"`,
+		`$.store.book[3].title = "The Lord of the Rings"`)
+	check(`$..book[?(@.category != "fiction")].title`,
+		`$.store.book[0].title = "Sayings of the Century"`)
+	check(`$..book[?(@.price > 20 || (@.price <= 9 && @.title > "N"))].title`,
+		`$.store.book[0].title = "Sayings of the Century"`,
+		`$.store.book[3].title = "The Lord of the Rings"`)
+	check(`$.store.bicycle[?(@ == false)]`, `$.store.bicycle["sold-out"] = false`)
+	check(`$..[?(@.color == null)].limit`)
+}
+
+func compact(t *testing.T, doc interface{}, keys ...string) string {
+	for _, key := range keys {
+		doc = doc.(map[string]interface{})[key]
+	}
+	b, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("json.Marshal failed: %s", err)
+	}
+	return string(b)
+}
+
+func TestParseError(t *testing.T) {
+	check := func(path, expectedErr string) {
+		t.Helper()
+
+		_, err := jsonpath.Parse(path)
+		if test.Error(t, err, path) {
+			test.EqualStr(t, err.Error(), expectedErr, path)
+		}
+	}
+
+	check("", "path must start with $ at position 0")
+	check("store", "path must start with $ at position 0")
+	check("$.", "name expected at position 2")
+	check("$.a b", `unexpected "b" at position 4`)
+	check("$[", "selector expected at position 2")
+	check("$[1", "']' expected at position 3")
+	check("$[1 2]", `',' or ']' expected instead of "2]" at position 4`)
+	check("$['a", "unterminated string at position 2")
+	check("$[a]", `unexpected "a]" at position 2`)
+	check("$[?(@.a == )]", `operand expected instead of ")]" at position 11`)
+	check("$[?(@.a == 1]", `')' expected instead of "]" at position 12`)
+	check("$[?(12)]", "a literal cannot be used alone in a filter at position 4")
+	check("$[?(@.a =~ /(/)]", "bad regexp: error parsing regexp: missing closing ): `(` at position 11")
+}
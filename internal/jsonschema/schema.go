@@ -0,0 +1,722 @@
+// Copyright (c) 2021, Maxime Soulé
+// All rights reserved.
+//
+// This source code is licensed under the BSD-style license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package jsonschema validates JSON values against a subset of JSON
+// Schema draft 7, as used by OpenAPI 3.0 schema objects. Values and
+// schemas are the ones produced by encoding/json when unmarshaling
+// into an interface{}.
+//
+// Supported keywords are:
+//   - $ref, only pointing inside the schema document, and overriding
+//     all its sibling keywords;
+//   - type, enum, const and nullable (OpenAPI 3.0);
+//   - allOf, anyOf, oneOf, not and if/then/else;
+//   - multipleOf, minimum, maximum, exclusiveMinimum and
+//     exclusiveMaximum (as booleans or numbers);
+//   - minLength, maxLength and pattern;
+//   - format: date-time, date, time, email, hostname, ipv4, ipv6,
+//     uri, uri-reference, uuid and regex for strings, int32 and int64
+//     for numbers;
+//   - items (a single schema), minItems, maxItems and uniqueItems;
+//   - properties, required, additionalProperties, minProperties and
+//     maxProperties.
+//
+// Using any other validation keyword (see unsupportedKeywords) is
+// reported as a validation error. Other keywords and unchecked
+// formats (as date-time or float used by OpenAPI), as annotations,
+// are ignored.
+package jsonschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/maxatome/go-testdeep/internal/util"
+)
+
+// maxDepth is the maximum $ref nesting allowed, to avoid infinite
+// recursion with self-referencing schemas.
+const maxDepth = 256
+
+// unsupportedKeywords lists the validation keywords not supported.
+var unsupportedKeywords = []string{
+	"$dynamicRef",
+	"$recursiveRef",
+	"additionalItems",
+	"contains",
+	"dependencies",
+	"dependentRequired",
+	"dependentSchemas",
+	"maxContains",
+	"minContains",
+	"patternProperties",
+	"prefixItems",
+	"propertyNames",
+	"unevaluatedItems",
+	"unevaluatedProperties",
+}
+
+// Error is a validation error.
+type Error struct {
+	// InstancePath is the JSON pointer of the invalid value in the
+	// validated instance.
+	InstancePath string
+	// SchemaPath is the JSON pointer of the failing keyword in the
+	// schema document, prefixed by "#".
+	SchemaPath string
+	// Keyword is the failing keyword.
+	Keyword string
+	// Message describes the error.
+	Message string
+}
+
+func (e Error) Error() string {
+	return fmt.Sprintf("%s: %s (%s)", e.InstancePath, e.Message, e.SchemaPath)
+}
+
+// Schema is a JSON Schema document.
+type Schema struct {
+	root interface{}
+}
+
+// New returns a new *Schema from "root", the schema document
+// unmarshaled by encoding/json.
+func New(root interface{}) *Schema {
+	return &Schema{root: root}
+}
+
+// Validate validates "instance" against the root schema of "s".
+func (s *Schema) Validate(instance interface{}) []Error {
+	return s.ValidateAt("", instance)
+}
+
+// ValidateAt validates "instance" against the sub-schema located at
+// JSON pointer "pointer" in the document of "s". It allows to
+// validate against a schema embedded in a bigger document, as an
+// OpenAPI spec, where $ref are relative to the whole document.
+func (s *Schema) ValidateAt(pointer string, instance interface{}) []Error {
+	schema, err := util.JSONPointer(s.root, pointer)
+	if err != nil {
+		return []Error{{
+			SchemaPath: "#" + pointer,
+			Keyword:    "$ref",
+			Message:    "cannot resolve schema: " + err.Error(),
+		}}
+	}
+
+	v := validator{root: s.root}
+	v.validate(schema, "#"+pointer, normalize(instance), "", 0)
+	return v.errors
+}
+
+// Resolve follows $ref of the schema located at JSON pointer
+// "pointer" and returns the final schema and its JSON pointer.
+func (s *Schema) Resolve(pointer string) (interface{}, string) {
+	schema, _ := util.JSONPointer(s.root, pointer)
+	for i := 0; i < maxDepth; i++ {
+		m, ok := schema.(map[string]interface{})
+		if !ok {
+			break
+		}
+		ref, ok := m["$ref"].(string)
+		if !ok || !strings.HasPrefix(ref, "#") {
+			break
+		}
+		target, err := util.JSONPointer(s.root, unescapeFragment(ref[1:]))
+		if err != nil {
+			break
+		}
+		schema, pointer = target, unescapeFragment(ref[1:])
+	}
+	return schema, pointer
+}
+
+type validator struct {
+	root   interface{}
+	errors []Error
+}
+
+func (v *validator) addError(schemaPath, keyword, instPath, format string, args ...interface{}) {
+	v.errors = append(v.errors, Error{
+		InstancePath: instPath,
+		SchemaPath:   schemaPath + "/" + keyword,
+		Keyword:      keyword,
+		Message:      fmt.Sprintf(format, args...),
+	})
+}
+
+// isValid returns true if "inst" is valid against "schema", without
+// recording any error.
+func (v *validator) isValid(schema interface{}, schemaPath string, inst interface{}, depth int) bool {
+	sub := validator{root: v.root}
+	sub.validate(schema, schemaPath, inst, "", depth)
+	return len(sub.errors) == 0
+}
+
+func (v *validator) validate(schema interface{}, schemaPath string, inst interface{}, instPath string, depth int) {
+	switch schema := schema.(type) {
+	case bool:
+		if !schema {
+			v.errors = append(v.errors, Error{
+				InstancePath: instPath,
+				SchemaPath:   schemaPath,
+				Keyword:      "false",
+				Message:      "no value is allowed by false schema",
+			})
+		}
+		return
+	case map[string]interface{}:
+		v.validateObject(schema, schemaPath, inst, instPath, depth)
+	default:
+		v.errors = append(v.errors, Error{
+			InstancePath: instPath,
+			SchemaPath:   schemaPath,
+			Message:      fmt.Sprintf("invalid schema of type %T", schema),
+		})
+	}
+}
+
+func (v *validator) validateObject(schema map[string]interface{}, schemaPath string, inst interface{}, instPath string, depth int) {
+	if ref, ok := schema["$ref"].(string); ok {
+		if depth >= maxDepth {
+			v.addError(schemaPath, "$ref", instPath, "too many nested $ref")
+			return
+		}
+		if !strings.HasPrefix(ref, "#") {
+			v.addError(schemaPath, "$ref", instPath,
+				"only local $ref are supported, not %q", ref)
+			return
+		}
+		pointer := unescapeFragment(ref[1:])
+		target, err := util.JSONPointer(v.root, pointer)
+		if err != nil {
+			v.addError(schemaPath, "$ref", instPath,
+				"cannot resolve %q: %s", ref, err)
+			return
+		}
+		// $ref overrides all sibling keywords
+		v.validate(target, "#"+pointer, inst, instPath, depth+1)
+		return
+	}
+
+	unsupported := false
+	for _, kw := range unsupportedKeywords {
+		if _, ok := schema[kw]; ok {
+			v.addError(schemaPath, kw, instPath, "keyword %s is not supported", kw)
+			unsupported = true
+		}
+	}
+	if _, ok := schema["items"].([]interface{}); ok {
+		v.addError(schemaPath, "items", instPath,
+			"items as an array of schemas is not supported")
+		unsupported = true
+	}
+	if unsupported {
+		return
+	}
+
+	// OpenAPI 3.0
+	if inst == nil {
+		if nullable, _ := schema["nullable"].(bool); nullable {
+			return
+		}
+	}
+
+	if typ, ok := schema["type"]; ok {
+		if !v.checkType(typ, inst) {
+			v.addError(schemaPath, "type", instPath,
+				"%s is not of type %s", describe(inst), typeString(typ))
+		}
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, e := range enum {
+			if equal(normalize(e), inst) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			v.addError(schemaPath, "enum", instPath,
+				"%s is not one of %s", describe(inst), toJSON(enum))
+		}
+	}
+
+	if c, ok := schema["const"]; ok && !equal(normalize(c), inst) {
+		v.addError(schemaPath, "const", instPath,
+			"%s is not equal to %s", describe(inst), toJSON(c))
+	}
+
+	switch inst := inst.(type) {
+	case float64:
+		v.validateNumber(schema, schemaPath, inst, instPath)
+	case string:
+		v.validateString(schema, schemaPath, inst, instPath)
+	case []interface{}:
+		v.validateArray(schema, schemaPath, inst, instPath, depth)
+	case map[string]interface{}:
+		v.validateMap(schema, schemaPath, inst, instPath, depth)
+	}
+
+	v.validateCombinators(schema, schemaPath, inst, instPath, depth)
+}
+
+func (v *validator) validateCombinators(schema map[string]interface{}, schemaPath string, inst interface{}, instPath string, depth int) {
+	if allOf, ok := schema["allOf"].([]interface{}); ok {
+		for i, sub := range allOf {
+			v.validate(sub, schemaPath+"/allOf/"+strconv.Itoa(i), inst, instPath, depth)
+		}
+	}
+
+	if anyOf, ok := schema["anyOf"].([]interface{}); ok {
+		found := false
+		for i, sub := range anyOf {
+			if v.isValid(sub, schemaPath+"/anyOf/"+strconv.Itoa(i), inst, depth) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			v.addError(schemaPath, "anyOf", instPath,
+				"%s does not match any schema of anyOf", describe(inst))
+		}
+	}
+
+	if oneOf, ok := schema["oneOf"].([]interface{}); ok {
+		var matching []string
+		for i, sub := range oneOf {
+			if v.isValid(sub, schemaPath+"/oneOf/"+strconv.Itoa(i), inst, depth) {
+				matching = append(matching, strconv.Itoa(i))
+			}
+		}
+		switch len(matching) {
+		case 1:
+		case 0:
+			v.addError(schemaPath, "oneOf", instPath,
+				"%s does not match any schema of oneOf", describe(inst))
+		default:
+			v.addError(schemaPath, "oneOf", instPath,
+				"%s matches several schemas of oneOf (%s) instead of only one",
+				describe(inst), strings.Join(matching, ", "))
+		}
+	}
+
+	if not, ok := schema["not"]; ok &&
+		v.isValid(not, schemaPath+"/not", inst, depth) {
+		v.addError(schemaPath, "not", instPath,
+			"%s should not match schema of not", describe(inst))
+	}
+
+	if cond, ok := schema["if"]; ok {
+		if v.isValid(cond, schemaPath+"/if", inst, depth) {
+			if then, ok := schema["then"]; ok {
+				v.validate(then, schemaPath+"/then", inst, instPath, depth)
+			}
+		} else if els, ok := schema["else"]; ok {
+			v.validate(els, schemaPath+"/else", inst, instPath, depth)
+		}
+	}
+}
+
+func (v *validator) validateNumber(schema map[string]interface{}, schemaPath string, inst float64, instPath string) {
+	if multipleOf, ok := toFloat(schema["multipleOf"]); ok && multipleOf > 0 {
+		if q := inst / multipleOf; math.Abs(q-math.Floor(q+0.5)) > 1e-9 {
+			v.addError(schemaPath, "multipleOf", instPath,
+				"%s is not a multiple of %s", num(inst), num(multipleOf))
+		}
+	}
+
+	// OpenAPI 3.0 & draft 4: exclusiveMinimum/Maximum are booleans
+	exclMin, _ := schema["exclusiveMinimum"].(bool)
+	exclMax, _ := schema["exclusiveMaximum"].(bool)
+
+	if min, ok := toFloat(schema["minimum"]); ok {
+		if exclMin && inst <= min {
+			v.addError(schemaPath, "minimum", instPath,
+				"%s is less than or equal to exclusive minimum %s", num(inst), num(min))
+		} else if inst < min {
+			v.addError(schemaPath, "minimum", instPath,
+				"%s is less than minimum %s", num(inst), num(min))
+		}
+	}
+	if max, ok := toFloat(schema["maximum"]); ok {
+		if exclMax && inst >= max {
+			v.addError(schemaPath, "maximum", instPath,
+				"%s is greater than or equal to exclusive maximum %s", num(inst), num(max))
+		} else if inst > max {
+			v.addError(schemaPath, "maximum", instPath,
+				"%s is greater than maximum %s", num(inst), num(max))
+		}
+	}
+	if min, ok := toFloat(schema["exclusiveMinimum"]); ok && inst <= min {
+		v.addError(schemaPath, "exclusiveMinimum", instPath,
+			"%s is less than or equal to exclusive minimum %s", num(inst), num(min))
+	}
+	if max, ok := toFloat(schema["exclusiveMaximum"]); ok && inst >= max {
+		v.addError(schemaPath, "exclusiveMaximum", instPath,
+			"%s is greater than or equal to exclusive maximum %s", num(inst), num(max))
+	}
+
+	if format, ok := schema["format"].(string); ok {
+		switch format {
+		case "int32":
+			if inst != math.Trunc(inst) || inst < math.MinInt32 || inst > math.MaxInt32 {
+				v.addError(schemaPath, "format", instPath, "%s is not a valid int32", num(inst))
+			}
+		case "int64":
+			if inst != math.Trunc(inst) || inst < math.MinInt64 || inst > math.MaxInt64 {
+				v.addError(schemaPath, "format", instPath, "%s is not a valid int64", num(inst))
+			}
+		}
+	}
+}
+
+func (v *validator) validateString(schema map[string]interface{}, schemaPath string, inst string, instPath string) {
+	length := utf8.RuneCountInString(inst)
+	if min, ok := toFloat(schema["minLength"]); ok && float64(length) < min {
+		v.addError(schemaPath, "minLength", instPath,
+			"%q is shorter than %s characters", inst, num(min))
+	}
+	if max, ok := toFloat(schema["maxLength"]); ok && float64(length) > max {
+		v.addError(schemaPath, "maxLength", instPath,
+			"%q is longer than %s characters", inst, num(max))
+	}
+
+	if pattern, ok := schema["pattern"].(string); ok {
+		re, err := compileRegexp(pattern)
+		if err != nil {
+			v.addError(schemaPath, "pattern", instPath,
+				"invalid pattern %q: %s", pattern, err)
+		} else if !re.MatchString(inst) {
+			v.addError(schemaPath, "pattern", instPath,
+				"%q does not match pattern %q", inst, pattern)
+		}
+	}
+
+	if format, ok := schema["format"].(string); ok {
+		if check := formats[format]; check != nil && !check(inst) {
+			v.addError(schemaPath, "format", instPath,
+				"%q is not a valid %s", inst, format)
+		}
+	}
+}
+
+func (v *validator) validateArray(schema map[string]interface{}, schemaPath string, inst []interface{}, instPath string, depth int) {
+	if min, ok := toFloat(schema["minItems"]); ok && float64(len(inst)) < min {
+		v.addError(schemaPath, "minItems", instPath,
+			"array has %d items, less than minimum %s", len(inst), num(min))
+	}
+	if max, ok := toFloat(schema["maxItems"]); ok && float64(len(inst)) > max {
+		v.addError(schemaPath, "maxItems", instPath,
+			"array has %d items, more than maximum %s", len(inst), num(max))
+	}
+
+	if unique, _ := schema["uniqueItems"].(bool); unique {
+	outer:
+		for i := 1; i < len(inst); i++ {
+			for j := 0; j < i; j++ {
+				if equal(inst[i], inst[j]) {
+					v.addError(schemaPath, "uniqueItems", instPath,
+						"items #%d and #%d are equal", j, i)
+					break outer
+				}
+			}
+		}
+	}
+
+	if items, ok := schema["items"]; ok {
+		for i, item := range inst {
+			v.validate(items, schemaPath+"/items", item, instPath+"/"+strconv.Itoa(i), depth)
+		}
+	}
+
+}
+
+func (v *validator) validateMap(schema map[string]interface{}, schemaPath string, inst map[string]interface{}, instPath string, depth int) {
+	if min, ok := toFloat(schema["minProperties"]); ok && float64(len(inst)) < min {
+		v.addError(schemaPath, "minProperties", instPath,
+			"object has %d properties, less than minimum %s", len(inst), num(min))
+	}
+	if max, ok := toFloat(schema["maxProperties"]); ok && float64(len(inst)) > max {
+		v.addError(schemaPath, "maxProperties", instPath,
+			"object has %d properties, more than maximum %s", len(inst), num(max))
+	}
+
+	if required, ok := schema["required"].([]interface{}); ok {
+		for _, name := range required {
+			if name, ok := name.(string); ok {
+				if _, ok := inst[name]; !ok {
+					v.addError(schemaPath, "required", instPath,
+						"missing required property %q", name)
+				}
+			}
+		}
+	}
+
+	keys := sortedKeys(inst)
+
+	properties, _ := schema["properties"].(map[string]interface{})
+	additional, hasAdditional := schema["additionalProperties"]
+
+	for _, key := range keys {
+		value := inst[key]
+		subPath := instPath + "/" + escapePointer(key)
+
+		if sub, ok := properties[key]; ok {
+			v.validate(sub, schemaPath+"/properties/"+escapePointer(key), value, subPath, depth)
+		} else if hasAdditional {
+			if allowed, ok := additional.(bool); ok {
+				if !allowed {
+					v.addError(schemaPath, "additionalProperties", subPath,
+						"additional property %q is not allowed", key)
+				}
+			} else {
+				v.validate(additional, schemaPath+"/additionalProperties", value, subPath, depth)
+			}
+		}
+	}
+
+}
+
+func (v *validator) checkType(typ interface{}, inst interface{}) bool {
+	switch typ := typ.(type) {
+	case string:
+		return isType(typ, inst)
+	case []interface{}:
+		for _, t := range typ {
+			if t, ok := t.(string); ok && isType(t, inst) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func isType(typ string, inst interface{}) bool {
+	switch typ {
+	case "null":
+		return inst == nil
+	case "boolean":
+		_, ok := inst.(bool)
+		return ok
+	case "object":
+		_, ok := inst.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := inst.([]interface{})
+		return ok
+	case "string":
+		_, ok := inst.(string)
+		return ok
+	case "number":
+		_, ok := inst.(float64)
+		return ok
+	case "integer":
+		f, ok := inst.(float64)
+		return ok && f == math.Trunc(f) && !math.IsInf(f, 0)
+	}
+	return false
+}
+
+func typeString(typ interface{}) string {
+	if types, ok := typ.([]interface{}); ok {
+		s := make([]string, len(types))
+		for i, t := range types {
+			s[i] = fmt.Sprint(t)
+		}
+		return strings.Join(s, " or ")
+	}
+	return fmt.Sprint(typ)
+}
+
+// describe returns a short description of "inst" for error messages.
+func describe(inst interface{}) string {
+	switch inst := inst.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case float64:
+		return num(inst)
+	case string:
+		if len(inst) > 40 {
+			return strconv.Quote(inst[:37] + "...")
+		}
+		return strconv.Quote(inst)
+	}
+	return fmt.Sprint(inst)
+}
+
+// num formats "f" as encoding/json does.
+func num(f float64) string {
+	if f == math.Trunc(f) && math.Abs(f) < 1e21 {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func toJSON(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case int:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+// normalize returns a copy of "v" where json.Number and int values
+// are converted to float64, recursively.
+func normalize(v interface{}) interface{} {
+	switch tv := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(tv))
+		for k, e := range tv {
+			m[k] = normalize(e)
+		}
+		return m
+	case []interface{}:
+		a := make([]interface{}, len(tv))
+		for i, e := range tv {
+			a[i] = normalize(e)
+		}
+		return a
+	case json.Number, int:
+		f, _ := toFloat(tv)
+		return f
+	}
+	return v
+}
+
+func equal(a, b interface{}) bool {
+	return reflect.DeepEqual(a, b)
+}
+
+func sortedKeys(m interface{}) []string {
+	rm := reflect.ValueOf(m)
+	if rm.Kind() != reflect.Map {
+		return nil
+	}
+	keys := make([]string, 0, rm.Len())
+	for _, k := range rm.MapKeys() {
+		keys = append(keys, k.String())
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+var pointerEsc = strings.NewReplacer("~", "~0", "/", "~1")
+
+// escapePointer escapes "s" to be used as a JSON pointer part.
+func escapePointer(s string) string {
+	return pointerEsc.Replace(s)
+}
+
+// unescapeFragment returns the JSON pointer contained in a URI
+// fragment.
+func unescapeFragment(fragment string) string {
+	if u, err := url.PathUnescape(fragment); err == nil {
+		return u
+	}
+	return fragment
+}
+
+var (
+	regexpsMu sync.Mutex
+	regexps   = map[string]*regexp.Regexp{}
+)
+
+func compileRegexp(pattern string) (*regexp.Regexp, error) {
+	regexpsMu.Lock()
+	defer regexpsMu.Unlock()
+
+	if re := regexps[pattern]; re != nil {
+		return re, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	regexps[pattern] = re
+	return re, nil
+}
+
+var (
+	hostnameRe = regexp.MustCompile(`^(?i:[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?)(\.(?i:[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?))*\.?$`)
+	uuidRe     = regexp.MustCompile(`^(?i)[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+	timeRe     = regexp.MustCompile(`^\d\d:\d\d:\d\d(\.\d+)?(?i:z|[+-]\d\d:\d\d)$`)
+
+	// formats contains the checked string formats. Other formats are
+	// annotations and so are ignored.
+	formats = map[string]func(string) bool{
+		"date-time": func(s string) bool {
+			_, err := time.Parse(time.RFC3339Nano, strings.ToUpper(s))
+			return err == nil
+		},
+		"date": func(s string) bool {
+			_, err := time.Parse("2006-01-02", s)
+			return err == nil
+		},
+		"time": func(s string) bool {
+			if !timeRe.MatchString(s) {
+				return false
+			}
+			_, err := time.Parse(time.RFC3339Nano, "2006-01-02T"+strings.ToUpper(s))
+			return err == nil
+		},
+		"email": func(s string) bool {
+			at := strings.LastIndexByte(s, '@')
+			return at > 0 && at < len(s)-1 && !strings.ContainsAny(s, " \t\r\n") &&
+				hostnameRe.MatchString(s[at+1:])
+		},
+		"hostname": func(s string) bool {
+			return len(s) <= 253 && hostnameRe.MatchString(s)
+		},
+		"ipv4": func(s string) bool {
+			ip := net.ParseIP(s)
+			return ip != nil && ip.To4() != nil && !strings.Contains(s, ":")
+		},
+		"ipv6": func(s string) bool {
+			return net.ParseIP(s) != nil && strings.Contains(s, ":")
+		},
+		"uri": func(s string) bool {
+			u, err := url.Parse(s)
+			return err == nil && u.IsAbs()
+		},
+		"uri-reference": func(s string) bool {
+			_, err := url.Parse(s)
+			return err == nil
+		},
+		"uuid": uuidRe.MatchString,
+		"regex": func(s string) bool {
+			_, err := regexp.Compile(s)
+			return err == nil
+		},
+	}
+)
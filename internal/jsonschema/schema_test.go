@@ -0,0 +1,182 @@
+// Copyright (c) 2021, Maxime Soulé
+// All rights reserved.
+//
+// This source code is licensed under the BSD-style license found in the
+// LICENSE file in the root directory of this source tree.
+
+package jsonschema_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/maxatome/go-testdeep/internal/jsonschema"
+	"github.com/maxatome/go-testdeep/internal/test"
+)
+
+func unmarshal(t *testing.T, s string) interface{} {
+	t.Helper()
+
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatalf("json.Unmarshal(%s) failed: %s", s, err)
+	}
+	return v
+}
+
+func TestValidate(t *testing.T) {
+	check := func(schema, instance string, expected ...string) {
+		t.Helper()
+
+		errs := jsonschema.New(unmarshal(t, schema)).
+			Validate(unmarshal(t, instance))
+
+		got := make([]string, len(errs))
+		for i, err := range errs {
+			got[i] = err.Error()
+		}
+		test.EqualStr(t,
+			strings.Join(got, "\n"), strings.Join(expected, "\n"),
+			"schema: %s, instance: %s", schema, instance)
+	}
+
+	// OK
+	check(`true`, `1`)
+	check(`{}`, `{"a":[1,2]}`)
+	check(`{"properties":{"a":{"type":"string"}}}`, `{"a":"ok","b":1}`)
+	check(`{"type":"string","nullable":true}`, `null`)
+	check(`{"type":"integer"}`, `2.0`)
+	check(`{"format":"int64","title":"ignored","x-ext":1}`, `"anything"`)
+	check(`{"type":"number","format":"double"}`, `1.5`)
+	check(`{"type":"string","format":"unknown"}`, `"anything"`)
+
+	// Generic
+	check(`false`, `1`,
+		`: no value is allowed by false schema (#)`)
+	check(`{"type":"integer"}`, `1.5`,
+		`: 1.5 is not of type integer (#/type)`)
+	check(`{"type":["string","null"]}`, `1`,
+		`: 1 is not of type string or null (#/type)`)
+	check(`{"enum":[1,"a"]}`, `2`,
+		`: 2 is not one of [1,"a"] (#/enum)`)
+	check(`{"const":{"a":1}}`, `{"a":2}`,
+		`: object is not equal to {"a":1} (#/const)`)
+
+	// Numbers
+	check(`{"minimum":1,"exclusiveMaximum":10,"multipleOf":2}`, `10`,
+		`: 10 is greater than or equal to exclusive maximum 10 (#/exclusiveMaximum)`)
+	check(`{"minimum":1,"exclusiveMinimum":true}`, `1`,
+		`: 1 is less than or equal to exclusive minimum 1 (#/minimum)`)
+	check(`{"type":"integer","format":"int32"}`, `3000000000`,
+		`: 3000000000 is not a valid int32 (#/format)`)
+
+	// Strings
+	check(`{"minLength":2,"maxLength":3,"pattern":"^a"}`, `"bcde"`,
+		`: "bcde" is longer than 3 characters (#/maxLength)`,
+		`: "bcde" does not match pattern "^a" (#/pattern)`)
+	check(`{"format":"date-time"}`, `"2021-01-01T00:00:00Z"`)
+	check(`{"format":"date-time"}`, `"2021-13-01"`,
+		`: "2021-13-01" is not a valid date-time (#/format)`)
+	check(`{"format":"date"}`, `"2021-02-30"`,
+		`: "2021-02-30" is not a valid date (#/format)`)
+	check(`{"format":"time"}`, `"25:00:00Z"`,
+		`: "25:00:00Z" is not a valid time (#/format)`)
+	check(`{"format":"email"}`, `"foo"`,
+		`: "foo" is not a valid email (#/format)`)
+	check(`{"format":"hostname"}`, `"-bad-.com"`,
+		`: "-bad-.com" is not a valid hostname (#/format)`)
+	check(`{"format":"uri"}`, `"/relative"`,
+		`: "/relative" is not a valid uri (#/format)`)
+	check(`{"format":"uuid"}`, `"x"`,
+		`: "x" is not a valid uuid (#/format)`)
+	check(`{"format":"ipv4"}`, `"1.2.3"`,
+		`: "1.2.3" is not a valid ipv4 (#/format)`)
+	check(`{"format":"ipv6"}`, `"1.2.3.4"`,
+		`: "1.2.3.4" is not a valid ipv6 (#/format)`)
+
+	// Arrays
+	check(`{"items":{"type":"string"},"uniqueItems":true}`, `["a",1,"a"]`,
+		`: items #0 and #2 are equal (#/uniqueItems)`,
+		`/1: 1 is not of type string (#/items/type)`)
+
+	// Objects
+	check(`{"required":["a","b"],"properties":{"a":{"type":"string"}},"additionalProperties":false}`,
+		`{"a":1,"c":2}`,
+		`: missing required property "b" (#/required)`,
+		`/a: 1 is not of type string (#/properties/a/type)`,
+		`/c: additional property "c" is not allowed (#/additionalProperties)`)
+	check(`{"minProperties":3}`, `{"a":1}`,
+		`: object has 1 properties, less than minimum 3 (#/minProperties)`)
+
+	// Combinations
+	check(`{"anyOf":[{"type":"string"},{"type":"integer"}]}`, `true`,
+		`: true does not match any schema of anyOf (#/anyOf)`)
+	check(`{"oneOf":[{"type":"number"},{"type":"integer"}]}`, `1`,
+		`: 1 matches several schemas of oneOf (0, 1) instead of only one (#/oneOf)`)
+	check(`{"not":{"type":"null"}}`, `null`,
+		`: null should not match schema of not (#/not)`)
+	check(`{"if":{"properties":{"a":{"const":1}}},"then":{"required":["b"]},"else":{"required":["c"]}}`,
+		`{"a":1}`,
+		`: missing required property "b" (#/then/required)`)
+	check(`{"if":{"properties":{"a":{"const":1}}},"then":{"required":["b"]},"else":{"required":["c"]}}`,
+		`{"a":2}`,
+		`: missing required property "c" (#/else/required)`)
+
+	// $ref
+	check(`{"$ref":"#/definitions/pos","definitions":{"pos":{"type":"integer","minimum":0}}}`,
+		`-1`,
+		`: -1 is less than minimum 0 (#/definitions/pos/minimum)`)
+	check(`{"$ref":"#/definitions/x","definitions":{}}`, `1`,
+		`: cannot resolve "#/definitions/x": key not found @/definitions/x (#/$ref)`)
+	check(`{"$ref":"#/definitions/a","definitions":{"a":{"$ref":"#/definitions/a"}}}`, `1`,
+		`: too many nested $ref (#/definitions/a/$ref)`)
+	check(`{"$ref":"#/definitions/a","type":"string","definitions":{"a":{}}}`, `1`)
+
+	// Unsupported
+	check(`{"$ref":"other.json#/definitions/a"}`, `1`,
+		`: only local $ref are supported, not "other.json#/definitions/a" (#/$ref)`)
+	check(`{"items":[{"type":"string"}],"patternProperties":{}}`, `[1]`,
+		`: keyword patternProperties is not supported (#/patternProperties)`,
+		`: items as an array of schemas is not supported (#/items)`)
+}
+
+func TestValidateAt(t *testing.T) {
+	s := jsonschema.New(unmarshal(t, `{
+  "components": {
+    "schemas": {
+      "Pet": {
+        "type": "object",
+        "properties": {"id": {"$ref": "#/components/schemas/ID"}}
+      },
+      "ID": {"type": "integer", "minimum": 1}
+    }
+  }
+}`))
+
+	errs := s.ValidateAt("/components/schemas/Pet", unmarshal(t, `{"id":0}`))
+	if test.EqualInt(t, len(errs), 1) {
+		test.EqualStr(t, errs[0].InstancePath, "/id")
+		test.EqualStr(t, errs[0].SchemaPath, "#/components/schemas/ID/minimum")
+		test.EqualStr(t, errs[0].Keyword, "minimum")
+		test.EqualStr(t, errs[0].Message, "0 is less than minimum 1")
+	}
+
+	errs = s.ValidateAt("/components/schemas/Dog", 1)
+	if test.EqualInt(t, len(errs), 1) {
+		test.EqualStr(t, errs[0].SchemaPath, "#/components/schemas/Dog")
+		test.EqualStr(t, errs[0].Keyword, "$ref")
+	}
+
+	// json.Number are accepted
+	test.EqualInt(t,
+		len(s.ValidateAt("/components/schemas/ID", json.Number("12"))), 0)
+
+	schema, pointer := jsonschema.New(unmarshal(t, `{
+  "a": {"$ref": "#/b"},
+  "b": {"$ref": "#/c"},
+  "c": {"type": "string"}
+}`)).Resolve("/a")
+	test.EqualStr(t, pointer, "/c")
+	test.IsTrue(t, schema.(map[string]interface{})["type"] == "string")
+}
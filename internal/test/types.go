@@ -81,7 +81,20 @@ func (t *TestingTB) Cleanup(fn func()) {
 		}
 		fn()
 	}
-	runtime.SetFinalizer(t, func(t *TestingTB) { t.cleanup() })
+	if old == nil {
+		runtime.SetFinalizer(t, func(t *TestingTB) { t.cleanup() })
+	}
+}
+
+// RunCleanup runs the functions registered by Cleanup, as the end of
+// a real test would do.
+func (t *TestingTB) RunCleanup() {
+	if t.cleanup != nil {
+		runtime.SetFinalizer(t, nil)
+		cleanup := t.cleanup
+		t.cleanup = nil
+		cleanup()
+	}
 }
 
 // Fatal mocks testing.T Error method.
@@ -0,0 +1,688 @@
+// Copyright (c) 2021, Maxime Soulé
+// All rights reserved.
+//
+// This source code is licensed under the BSD-style license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package yaml is a minimal YAML parser, sufficient to load
+// documents like OpenAPI specs or JSON schemas without any external
+// dependency.
+//
+// The following subset of YAML is supported:
+//   - block mappings and block sequences, indented with spaces;
+//   - flow mappings and flow sequences, as {a: 1} or [a, b], possibly
+//     spanning several lines;
+//   - plain, single-quoted and double-quoted scalars, possibly
+//     spanning several lines;
+//   - literal (|) and folded (>) block scalars, with their chomping
+//     indicator (- or +);
+//   - comments, a leading "---" and a trailing "..." document markers.
+//
+// Plain scalars are resolved following the YAML 1.2 JSON schema:
+// null, booleans and decimal numbers, other ones being strings.
+//
+// Any other construct leads to an explicit error: anchors, aliases,
+// tags, complex keys, explicit indentation indicators of block
+// scalars and multiple documents.
+//
+// Values are returned as encoding/json does when unmarshaling into
+// an interface{}: map[string]interface{}, []interface{}, string,
+// float64, bool and nil.
+package yaml
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type line struct {
+	num    int // starting at 1
+	indent int
+	text   string // without indentation nor comment
+}
+
+// Error is a YAML parsing error.
+type Error struct {
+	Line    int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("yaml: line %d: %s", e.Line, e.Message)
+}
+
+type parser struct {
+	lines []line
+	pos   int
+	raw   []string
+}
+
+// Unmarshal parses the YAML document "b".
+func Unmarshal(b []byte) (v interface{}, err error) {
+	p := parser{raw: strings.Split(strings.Replace(string(b), "\r\n", "\n", -1), "\n")}
+
+	defer func() {
+		if r := recover(); r != nil {
+			yErr, ok := r.(*Error)
+			if !ok {
+				panic(r)
+			}
+			err = yErr
+		}
+	}()
+
+	p.split()
+	if len(p.lines) == 0 {
+		return nil, nil
+	}
+
+	v = p.parseNode(p.lines[0].indent)
+	if p.pos < len(p.lines) {
+		p.fail(p.lines[p.pos].num, "unexpected content %q", p.lines[p.pos].text)
+	}
+	return v, nil
+}
+
+func (p *parser) fail(num int, format string, args ...interface{}) {
+	panic(&Error{Line: num, Message: fmt.Sprintf(format, args...)})
+}
+
+// split splits raw lines into significant lines.
+func (p *parser) split() {
+	for i, raw := range p.raw {
+		num := i + 1
+
+		text := strings.TrimLeft(raw, " ")
+		indent := len(raw) - len(text)
+
+		text = strings.TrimRight(stripComment(text), " \t")
+		if text == "" {
+			continue
+		}
+		if indent == 0 {
+			switch {
+			case text == "---":
+				if len(p.lines) > 0 {
+					p.fail(num, "multiple documents are not supported")
+				}
+				continue
+			case text == "...":
+				return
+			case strings.HasPrefix(text, "%"):
+				continue // directive
+			}
+		}
+		p.lines = append(p.lines, line{num: num, indent: indent, text: text})
+	}
+}
+
+// stripComment removes the comment of "s", if any.
+func stripComment(s string) string {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				if quote == '\'' && i+1 < len(s) && s[i+1] == '\'' {
+					i++
+				} else {
+					quote = 0
+				}
+			} else if c == '\\' && quote == '"' {
+				i++
+			}
+		case c == '"' || c == '\'':
+			if i == 0 || strings.IndexByte(" \t[{,:-?", s[i-1]) >= 0 {
+				quote = c
+			}
+		case c == '#':
+			if i == 0 || s[i-1] == ' ' || s[i-1] == '\t' {
+				return s[:i]
+			}
+		}
+	}
+	return s
+}
+
+// checkTab fails if "l" is indented using tabs. It is not done when
+// splitting lines, as tabs are allowed in block scalars content.
+func (p *parser) checkTab(l line) {
+	if strings.HasPrefix(l.text, "\t") {
+		p.fail(l.num, "tabs are not allowed for indentation")
+	}
+}
+
+func isSeqItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// parseNode parses the block node starting at current line, whose
+// indentation is "indent".
+func (p *parser) parseNode(indent int) interface{} {
+	l := p.lines[p.pos]
+	p.checkTab(l)
+	if isSeqItem(l.text) {
+		return p.parseSequence(indent)
+	}
+	if l.text == "?" || strings.HasPrefix(l.text, "? ") {
+		p.fail(l.num, "complex keys are not supported")
+	}
+	if _, _, ok := splitKeyValue(l.text); ok {
+		return p.parseMapping(indent)
+	}
+	p.pos++
+	return p.parseInlineValue(l, l.text, indent-1)
+}
+
+func (p *parser) parseSequence(indent int) []interface{} {
+	seq := []interface{}{}
+	for p.pos < len(p.lines) {
+		l := p.lines[p.pos]
+		if l.indent < indent {
+			break
+		}
+		p.checkTab(l)
+		if l.indent > indent {
+			p.fail(l.num, "bad indentation of a sequence entry")
+		}
+		if !isSeqItem(l.text) {
+			break
+		}
+
+		rest := strings.TrimLeft(l.text[1:], " ")
+		if rest == "" {
+			p.pos++
+			seq = append(seq, p.parseChild(indent, false))
+			continue
+		}
+
+		// "- content": re-parse content as a node at its own column
+		p.lines[p.pos] = line{
+			num:    l.num,
+			indent: l.indent + len(l.text) - len(rest),
+			text:   rest,
+		}
+		seq = append(seq, p.parseNode(p.lines[p.pos].indent))
+	}
+	return seq
+}
+
+func (p *parser) parseMapping(indent int) map[string]interface{} {
+	m := map[string]interface{}{}
+	for p.pos < len(p.lines) {
+		l := p.lines[p.pos]
+		if l.indent < indent {
+			break
+		}
+		p.checkTab(l)
+		if l.indent > indent {
+			p.fail(l.num, "bad indentation of a mapping entry")
+		}
+
+		key, value, ok := splitKeyValue(l.text)
+		if !ok {
+			if isSeqItem(l.text) {
+				break
+			}
+			p.fail(l.num, "mapping entry expected, not %q", l.text)
+		}
+		key = p.parseKey(l, key)
+		if _, exists := m[key]; exists {
+			p.fail(l.num, "duplicate key %q", key)
+		}
+		p.pos++
+
+		if value == "" {
+			m[key] = p.parseChild(indent, true)
+		} else {
+			m[key] = p.parseInlineValue(l, value, indent)
+		}
+	}
+	return m
+}
+
+// parseChild parses the block node following a "key:" or a "-"
+// without inline value. "inMapping" is true for the former, allowing
+// a sequence at the same indentation.
+func (p *parser) parseChild(indent int, inMapping bool) interface{} {
+	if p.pos >= len(p.lines) {
+		return nil
+	}
+	next := p.lines[p.pos]
+	if next.indent > indent ||
+		(inMapping && next.indent == indent && isSeqItem(next.text)) {
+		return p.parseNode(next.indent)
+	}
+	return nil
+}
+
+func (p *parser) parseKey(l line, key string) string {
+	switch {
+	case key == "":
+		p.fail(l.num, "empty key")
+	case key[0] == '"' || key[0] == '\'':
+		s, rest := p.parseQuoted(l.num, key)
+		if strings.TrimSpace(rest) != "" {
+			p.fail(l.num, "unexpected %q after quoted key", rest)
+		}
+		return s
+	case key[0] == '?' || key[0] == '[' || key[0] == '{':
+		p.fail(l.num, "complex keys are not supported")
+	case key[0] == '&' || key[0] == '*' || key[0] == '!':
+		p.fail(l.num, "anchors, aliases and tags are not supported")
+	}
+	return key
+}
+
+// parseInlineValue parses "value" found on line "l", whose parent
+// has indentation "indent". It can consume following lines for
+// block scalars, multi-lines flow collections or plain scalars.
+func (p *parser) parseInlineValue(l line, value string, indent int) interface{} {
+	switch value[0] {
+	case '&', '*', '!':
+		p.fail(l.num, "anchors, aliases and tags are not supported")
+	case '|', '>':
+		return p.parseBlockScalar(l, value, indent)
+	case '[', '{':
+		text := value
+		for !flowComplete(text) {
+			if p.pos >= len(p.lines) {
+				p.fail(l.num, "unterminated flow collection")
+			}
+			text += " " + p.lines[p.pos].text
+			p.pos++
+		}
+		f := flowParser{p: p, num: l.num, s: text}
+		v := f.parseValue()
+		f.skipSpaces()
+		if f.i < len(f.s) {
+			p.fail(l.num, "unexpected %q after flow collection", f.s[f.i:])
+		}
+		return v
+	case '"', '\'':
+		text := value
+		s, rest := p.parseQuotedMulti(l, &text)
+		if strings.TrimSpace(rest) != "" {
+			p.fail(l.num, "unexpected %q after quoted scalar", rest)
+		}
+		return s
+	}
+
+	// Plain scalar, possibly continued on next more indented lines
+	for p.pos < len(p.lines) && p.lines[p.pos].indent > indent &&
+		!isSeqItem(p.lines[p.pos].text) {
+		if _, _, ok := splitKeyValue(p.lines[p.pos].text); ok {
+			break
+		}
+		value += " " + p.lines[p.pos].text
+		p.pos++
+	}
+	return resolvePlain(value)
+}
+
+// parseQuotedMulti parses a quoted scalar that can span several lines.
+func (p *parser) parseQuotedMulti(l line, text *string) (string, string) {
+	for {
+		if s, rest, ok := tryParseQuoted(*text); ok {
+			return s, rest
+		}
+		if p.pos >= len(p.lines) {
+			p.fail(l.num, "unterminated quoted scalar")
+		}
+		*text += " " + p.lines[p.pos].text
+		p.pos++
+	}
+}
+
+func (p *parser) parseQuoted(num int, text string) (string, string) {
+	s, rest, ok := tryParseQuoted(text)
+	if !ok {
+		p.fail(num, "unterminated quoted scalar")
+	}
+	return s, rest
+}
+
+func (p *parser) parseBlockScalar(l line, header string, indent int) string {
+	literal := header[0] == '|'
+	chomp := byte(0)
+	for _, c := range header[1:] {
+		switch c {
+		case '-', '+':
+			chomp = byte(c)
+		case ' ':
+		case '1', '2', '3', '4', '5', '6', '7', '8', '9':
+			p.fail(l.num, "explicit indentation indicators are not supported")
+		default:
+			p.fail(l.num, "bad block scalar header %q", header)
+		}
+	}
+
+	// Block scalar lines are taken from raw lines, as empty lines and
+	// comments are significant
+	start := l.num // index of the next raw line
+	end := start
+	blockIndent := -1
+	for end < len(p.raw) {
+		raw := p.raw[end]
+		text := strings.TrimLeft(raw, " ")
+		if text != "" {
+			ind := len(raw) - len(text)
+			if ind <= indent {
+				break
+			}
+			if blockIndent < 0 {
+				blockIndent = ind
+			}
+			if ind < blockIndent {
+				break
+			}
+		}
+		end++
+	}
+
+	// Skip significant lines consumed by the block scalar
+	for p.pos < len(p.lines) && p.lines[p.pos].num <= end {
+		p.pos++
+	}
+
+	var lines []string
+	for _, raw := range p.raw[start:end] {
+		if len(raw) >= blockIndent && blockIndent >= 0 {
+			lines = append(lines, raw[blockIndent:])
+		} else {
+			lines = append(lines, "")
+		}
+	}
+
+	// Trailing empty lines
+	trailing := 0
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+		trailing++
+	}
+
+	var s string
+	if literal {
+		s = strings.Join(lines, "\n")
+	} else {
+		var buf bytes.Buffer
+		for i, ln := range lines {
+			if strings.TrimSpace(ln) == "" {
+				ln = ""
+			}
+			switch {
+			case i == 0:
+			case ln == "":
+				buf.WriteByte('\n')
+			case lines[i-1] == "":
+			case strings.HasPrefix(ln, " ") || strings.HasPrefix(lines[i-1], " "):
+				buf.WriteByte('\n')
+			default:
+				buf.WriteByte(' ')
+			}
+			buf.WriteString(ln)
+		}
+		s = buf.String()
+	}
+
+	switch chomp {
+	case '-':
+	case '+':
+		s += strings.Repeat("\n", trailing+1)
+	default:
+		if len(lines) > 0 {
+			s += "\n"
+		}
+	}
+	return s
+}
+
+// splitKeyValue splits "text" as "key: value". ok is false if "text"
+// is not a mapping entry.
+func splitKeyValue(text string) (key, value string, ok bool) {
+	var quote byte
+	depth := 0
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				if quote == '\'' && i+1 < len(text) && text[i+1] == '\'' {
+					i++
+				} else {
+					quote = 0
+				}
+			} else if c == '\\' && quote == '"' {
+				i++
+			}
+		case (c == '"' || c == '\'') && i == 0:
+			quote = c
+		case c == '[' || c == '{':
+			if i == 0 {
+				return "", "", false // flow collection
+			}
+			depth++
+		case c == ']' || c == '}':
+			depth--
+		case c == ':' && depth <= 0:
+			if i+1 == len(text) || text[i+1] == ' ' || text[i+1] == '\t' {
+				return strings.TrimRight(text[:i], " \t"),
+					strings.TrimSpace(text[i+1:]), true
+			}
+		}
+	}
+	return "", "", false
+}
+
+// tryParseQuoted parses the quoted scalar at the beginning of "s",
+// returning the unquoted string and the remaining text.
+func tryParseQuoted(s string) (string, string, bool) {
+	quote := s[0]
+	var buf bytes.Buffer
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		if quote == '\'' {
+			if c == '\'' {
+				if i+1 < len(s) && s[i+1] == '\'' {
+					buf.WriteByte('\'')
+					i++
+					continue
+				}
+				return buf.String(), s[i+1:], true
+			}
+			buf.WriteByte(c)
+			continue
+		}
+
+		switch c {
+		case '"':
+			return buf.String(), s[i+1:], true
+		case '\\':
+			if i+1 >= len(s) {
+				return "", "", false
+			}
+			i++
+			switch e := s[i]; e {
+			case 'n':
+				buf.WriteByte('\n')
+			case 't':
+				buf.WriteByte('\t')
+			case 'r':
+				buf.WriteByte('\r')
+			case '0':
+				buf.WriteByte(0)
+			case ' ', '/', '\\', '"':
+				buf.WriteByte(e)
+			case 'u', 'U', 'x':
+				size := map[byte]int{'x': 2, 'u': 4, 'U': 8}[e]
+				if i+size >= len(s) {
+					return "", "", false
+				}
+				r, err := strconv.ParseUint(s[i+1:i+1+size], 16, 32)
+				if err != nil {
+					buf.WriteByte(e)
+					continue
+				}
+				buf.WriteRune(rune(r))
+				i += size
+			default:
+				buf.WriteByte('\\')
+				buf.WriteByte(e)
+			}
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	return "", "", false
+}
+
+// flowComplete returns true if all brackets opened in "s" are closed.
+func flowComplete(s string) bool {
+	var quote byte
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else if c == '\\' && quote == '"' {
+				i++
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			depth--
+		}
+	}
+	return depth <= 0
+}
+
+type flowParser struct {
+	p   *parser
+	num int
+	s   string
+	i   int
+}
+
+func (f *flowParser) skipSpaces() {
+	for f.i < len(f.s) && (f.s[f.i] == ' ' || f.s[f.i] == '\t') {
+		f.i++
+	}
+}
+
+// separator skips the ',' following an item of a flow collection
+// ending with "end".
+func (f *flowParser) separator(end byte) {
+	f.skipSpaces()
+	if f.i < len(f.s) {
+		switch f.s[f.i] {
+		case ',':
+			f.i++
+			return
+		case end:
+			return
+		}
+	}
+	f.p.fail(f.num, "',' or '%c' expected in flow collection", end)
+}
+
+func (f *flowParser) parseValue() interface{} {
+	f.skipSpaces()
+	if f.i >= len(f.s) {
+		f.p.fail(f.num, "unexpected end of flow collection")
+	}
+	switch f.s[f.i] {
+	case '[':
+		f.i++
+		seq := []interface{}{}
+		for {
+			f.skipSpaces()
+			if f.i < len(f.s) && f.s[f.i] == ']' {
+				f.i++
+				return seq
+			}
+			seq = append(seq, f.parseValue())
+			f.separator(']')
+		}
+	case '{':
+		f.i++
+		m := map[string]interface{}{}
+		for {
+			f.skipSpaces()
+			if f.i < len(f.s) && f.s[f.i] == '}' {
+				f.i++
+				return m
+			}
+			key := f.parseScalar(true)
+			f.skipSpaces()
+			if f.i >= len(f.s) || f.s[f.i] != ':' {
+				f.p.fail(f.num, "':' expected in flow mapping")
+			}
+			f.i++
+			m[fmt.Sprint(key)] = f.parseValue()
+			f.separator('}')
+		}
+	}
+	return f.parseScalar(false)
+}
+
+func (f *flowParser) parseScalar(isKey bool) interface{} {
+	f.skipSpaces()
+	if f.i >= len(f.s) {
+		f.p.fail(f.num, "unexpected end of flow collection")
+	}
+	switch c := f.s[f.i]; c {
+	case '"', '\'':
+		s, rest, ok := tryParseQuoted(f.s[f.i:])
+		if !ok {
+			f.p.fail(f.num, "unterminated quoted scalar")
+		}
+		f.i = len(f.s) - len(rest)
+		return s
+	case '&', '*', '!':
+		f.p.fail(f.num, "anchors, aliases and tags are not supported")
+	}
+
+	start := f.i
+	for f.i < len(f.s) {
+		c := f.s[f.i]
+		if c == ',' || c == ']' || c == '}' ||
+			(c == ':' && (isKey || f.i+1 == len(f.s) || f.s[f.i+1] == ' ')) {
+			break
+		}
+		f.i++
+	}
+	text := strings.TrimSpace(f.s[start:f.i])
+	if isKey {
+		return text
+	}
+	return resolvePlain(text)
+}
+
+var numberRe = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]*)?([eE][-+]?[0-9]+)?$`)
+
+// resolvePlain returns the value of the plain scalar "s".
+func resolvePlain(s string) interface{} {
+	switch s {
+	case "", "~", "null", "Null", "NULL":
+		return nil
+	case "true", "True", "TRUE":
+		return true
+	case "false", "False", "FALSE":
+		return false
+	}
+
+	if numberRe.MatchString(s) {
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f
+		}
+	}
+	return s
+}
@@ -0,0 +1,151 @@
+// Copyright (c) 2021, Maxime Soulé
+// All rights reserved.
+//
+// This source code is licensed under the BSD-style license found in the
+// LICENSE file in the root directory of this source tree.
+
+package yaml_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/maxatome/go-testdeep/internal/test"
+	"github.com/maxatome/go-testdeep/internal/yaml"
+)
+
+func TestUnmarshal(t *testing.T) {
+	check := func(doc, expectedJSON string) {
+		t.Helper()
+
+		v, err := yaml.Unmarshal([]byte(doc))
+		if !test.NoError(t, err, doc) {
+			return
+		}
+		b, err := json.Marshal(v)
+		test.NoError(t, err)
+		test.EqualStr(t, string(b), expectedJSON, doc)
+	}
+
+	// Scalars
+	check("", `null`)
+	check("~", `null`)
+	check("null", `null`)
+	check("true", `true`)
+	check("False", `false`)
+	check("12", `12`)
+	check("-1.5e3", `-1500`)
+	check("0x1f", `"0x1f"`)
+	check(".inf", `".inf"`)
+	check("1_000", `"1_000"`)
+	check("foo bar", `"foo bar"`)
+	check("'it''s'", `"it's"`)
+	check(`"a\tbé\n"`, `"a\tbé\n"`)
+	check("---\nfoo\n...\n", `"foo"`)
+	check("3.0.3", `"3.0.3"`)
+
+	// Mappings
+	check(`
+# comment
+a: 1   # comment
+b:
+  c: x:y
+  "d e": 'f # g'
+empty:
+`, `{"a":1,"b":{"c":"x:y","d e":"f # g"},"empty":null}`)
+
+	// Sequences
+	check(`
+- a
+- - b
+  - c
+- d: 1
+  e: 2
+-
+  f: 3
+`, `["a",["b","c"],{"d":1,"e":2},{"f":3}]`)
+	check(`
+list:
+- 1
+- 2
+other: {}
+`, `{"list":[1,2],"other":{}}`)
+
+	// Flow collections
+	check(`
+a: []
+b: { }
+c:
+  - []
+`, `{"a":[],"b":{},"c":[[]]}`)
+	check(`{a: 1, b: [x, "y", {c: null}], d: []}`,
+		`{"a":1,"b":["x","y",{"c":null}],"d":[]}`)
+	check(`
+required: [id, name]
+enum: [a, 'b c', 3]
+a: [1,
+  2]
+`, `{"a":[1,2],"enum":["a","b c",3],"required":["id","name"]}`)
+
+	// Block scalars
+	check(`
+a: |
+  line 1
+    line 2
+  # not a comment
+  	indented with a tab
+
+b: >
+  folded
+  text
+
+  next
+c: |-
+  stripped
+d: |+
+  kept
+
+e: end
+f:
+  - |
+    in sequence
+`, `{"a":"line 1\n  line 2\n# not a comment\n\tindented with a tab\n","b":"folded text\nnext\n","c":"stripped","d":"kept\n\n","e":"end","f":["in sequence\n"]}`)
+
+	// Multi-line scalars
+	check(`
+a: one
+  two
+  three
+b: "foo
+  bar"
+`, `{"a":"one two three","b":"foo bar"}`)
+}
+
+func TestUnmarshalError(t *testing.T) {
+	check := func(doc, expectedErr string) {
+		t.Helper()
+
+		_, err := yaml.Unmarshal([]byte(doc))
+		if test.Error(t, err, doc) {
+			test.EqualStr(t, err.Error(), expectedErr, doc)
+		}
+	}
+
+	check("a: 1\na: 2", `yaml: line 2: duplicate key "a"`)
+	check("a: 1\n\tb: 2", "yaml: line 2: tabs are not allowed for indentation")
+	check("a: &x 1", "yaml: line 1: anchors, aliases and tags are not supported")
+	check("a: *x", "yaml: line 1: anchors, aliases and tags are not supported")
+	check("a: !!str 1", "yaml: line 1: anchors, aliases and tags are not supported")
+	check("? a\n: b", "yaml: line 1: complex keys are not supported")
+	check("a: 1\n---\nb: 2", "yaml: line 2: multiple documents are not supported")
+	check("a: [1, 2", "yaml: line 1: unterminated flow collection")
+	check("a: [1, 2}", "yaml: line 1: ',' or ']' expected in flow collection")
+	check("a: [1] x", `yaml: line 1: unexpected "x" after flow collection`)
+	check("{a: 1, b}", "yaml: line 1: ':' expected in flow mapping")
+	check("a: |2\n  text", "yaml: line 1: explicit indentation indicators are not supported")
+	check("a: |x\n  text", `yaml: line 1: bad block scalar header "|x"`)
+	check("a: \"foo\n  bar", "yaml: line 1: unterminated quoted scalar")
+	check(`a: "foo`, "yaml: line 1: unterminated quoted scalar")
+	check("a: 1\nfoo", `yaml: line 2: mapping entry expected, not "foo"`)
+	check("- a\nb: 1", `yaml: line 2: unexpected content "b: 1"`)
+}
@@ -147,7 +147,8 @@ func formatError(t TestingT, isFatal bool, err *ctxerr.Error, args ...interface{
 
 		nl := ""
 		for _, level := range s {
-			fmt.Fprintf(&buf, "%s\t%-*s %s", nl, fnMaxLen, level.Func+"()", level.FileLine)
+			fmt.Fprintf(&buf, "%s\t%-*s %s%s%s", nl, fnMaxLen, level.Func+"()",
+				color.LocationOn, level.FileLine, color.LocationOff)
 			nl = "\n"
 		}
 	}
@@ -12,7 +12,7 @@ import (
 	"time"
 )
 
-// allOperators lists the 61 operators.
+// allOperators lists the 63 operators.
 // nil means not usable in JSON().
 var allOperators = map[string]interface{}{
 	"All":         All,
@@ -35,7 +35,9 @@ var allOperators = map[string]interface{}{
 	"Ignore":      Ignore,
 	"Isa":         nil,
 	"JSON":        nil,
+	"JSONPath":    JSONPath,
 	"JSONPointer": JSONPointer,
+	"JSONSchema":  JSONSchema,
 	"Keys":        Keys,
 	"Lax":         nil,
 	"Len":         Len,
@@ -405,6 +407,25 @@ func CmpJSON(t TestingT, got, expectedJSON interface{}, params []interface{}, ar
 	return Cmp(t, got, JSON(expectedJSON, params...), args...)
 }
 
+// CmpJSONPath is a shortcut for:
+//
+//   td.Cmp(t, got, td.JSONPath(path, expectedValue), args...)
+//
+// See https://pkg.go.dev/github.com/maxatome/go-testdeep/td#JSONPath for details.
+//
+// Returns true if the test is OK, false if it fails.
+//
+// "args..." are optional and allow to name the test. This name is
+// used in case of failure to qualify the test. If len(args) > 1 and
+// the first item of "args" is a string and contains a '%' rune then
+// fmt.Fprintf is used to compose the name, else "args" are passed to
+// fmt.Fprint. Do not forget it is the name of the test, not the
+// reason of a potential failure.
+func CmpJSONPath(t TestingT, got interface{}, path string, expectedValue interface{}, args ...interface{}) bool {
+	t.Helper()
+	return Cmp(t, got, JSONPath(path, expectedValue), args...)
+}
+
 // CmpJSONPointer is a shortcut for:
 //
 //   td.Cmp(t, got, td.JSONPointer(pointer, expectedValue), args...)
@@ -424,6 +445,25 @@ func CmpJSONPointer(t TestingT, got interface{}, pointer string, expectedValue i
 	return Cmp(t, got, JSONPointer(pointer, expectedValue), args...)
 }
 
+// CmpJSONSchema is a shortcut for:
+//
+//   td.Cmp(t, got, td.JSONSchema(schema), args...)
+//
+// See https://pkg.go.dev/github.com/maxatome/go-testdeep/td#JSONSchema for details.
+//
+// Returns true if the test is OK, false if it fails.
+//
+// "args..." are optional and allow to name the test. This name is
+// used in case of failure to qualify the test. If len(args) > 1 and
+// the first item of "args" is a string and contains a '%' rune then
+// fmt.Fprintf is used to compose the name, else "args" are passed to
+// fmt.Fprint. Do not forget it is the name of the test, not the
+// reason of a potential failure.
+func CmpJSONSchema(t TestingT, got, schema interface{}, args ...interface{}) bool {
+	t.Helper()
+	return Cmp(t, got, JSONSchema(schema), args...)
+}
+
 // CmpKeys is a shortcut for:
 //
 //   td.Cmp(t, got, td.Keys(val), args...)
@@ -0,0 +1,91 @@
+// Copyright (c) 2021, Maxime Soulé
+// All rights reserved.
+//
+// This source code is licensed under the BSD-style license found in the
+// LICENSE file in the root directory of this source tree.
+
+package td
+
+import (
+	"reflect"
+	"sort"
+
+	"github.com/maxatome/go-testdeep/helpers/tdutil"
+	"github.com/maxatome/go-testdeep/internal/color"
+)
+
+// Compare deeply compares "a" and "b" and returns -1 if "a" is lower
+// than "b", 1 if "a" is greater than "b" and 0 if they are equal. It
+// uses the deterministic ordering go-testdeep uses to report map
+// keys, or Bag and Set missing and extra items:
+//   - nil is always lower
+//   - different types are sorted by their name
+//   - false is lesser than true
+//   - float and int numbers are sorted by their value, NaN being
+//     lower than any other float number
+//   - complex numbers are sorted by their real, then by their imaginary parts
+//   - strings are sorted by their value
+//   - map: shorter length is lesser, then sorted by address
+//   - functions, channels and unsafe pointer are sorted by their address
+//   - struct: comparison is spread to each field
+//   - pointer: comparison is spread to the pointed value
+//   - arrays: comparison is spread to each item
+//   - slice: comparison is spread to each item, then shorter length is lesser
+//   - interface: comparison is spread to the value
+//
+// Cyclic references are correctly handled.
+//
+//   td.Compare(12, 42)                             // returns -1
+//   td.Compare([]int{1, 2}, []int{1})              // returns 1
+//   td.Compare(&Person{Age: 26}, &Person{Age: 26}) // returns 0
+//
+// Note that unlike Cmp, Compare is not a test: it never fails, and
+// TestDeep operators are compared as any other struct.
+func Compare(a, b interface{}) int {
+	return tdutil.CompareValues(reflect.ValueOf(a), reflect.ValueOf(b))
+}
+
+// Less returns true if "a" is lower than "b", following Compare
+// ordering rules.
+//
+//   td.Less("abc", "abd") // returns true
+func Less(a, b interface{}) bool {
+	return Compare(a, b) < 0
+}
+
+// Sort sorts in place "slice", that can be a slice of any type,
+// following Compare ordering rules. The sort is stable.
+//
+//   people := []Person{{Name: "Bob"}, {Name: "Alice"}}
+//   td.Sort(people) // Alice, then Bob
+//
+//   items := []interface{}{"b", 3, nil, 1.5, "a"}
+//   td.Sort(items) // nil, 1.5, 3, "a", "b"
+//
+// It allows to compare slices whose order does not matter, but
+// without the cost of Bag operator or to get a deterministic output.
+func Sort(slice interface{}) {
+	vslice := reflect.ValueOf(slice)
+	if vslice.Kind() != reflect.Slice {
+		panic(color.BadUsage("Sort(SLICE)", slice, 1, true))
+	}
+
+	l := vslice.Len()
+	if l < 2 {
+		return
+	}
+
+	// Sort a copy, so items are not modified during the sort
+	vcopy := reflect.MakeSlice(vslice.Type(), l, l)
+	reflect.Copy(vcopy, vslice)
+
+	items := make([]reflect.Value, l)
+	for i := range items {
+		items[i] = vcopy.Index(i)
+	}
+	sort.Stable(tdutil.SortableValues(items))
+
+	for i, item := range items {
+		vslice.Index(i).Set(item)
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright (c) 2021, Maxime Soulé
+// All rights reserved.
+//
+// This source code is licensed under the BSD-style license found in the
+// LICENSE file in the root directory of this source tree.
+
+package td_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/maxatome/go-testdeep/internal/test"
+	"github.com/maxatome/go-testdeep/td"
+)
+
+func TestCompare(t *testing.T) {
+	type cyclic struct {
+		Next *cyclic
+		Val  int
+	}
+	a, b := &cyclic{Val: 1}, &cyclic{Val: 2}
+	a.Next, b.Next = a, b
+
+	test.EqualInt(t, td.Compare(nil, nil), 0)
+	test.EqualInt(t, td.Compare(nil, 1), -1)
+	test.EqualInt(t, td.Compare(12, 42), -1)
+	test.EqualInt(t, td.Compare(42, 12), 1)
+	test.EqualInt(t, td.Compare(42, "42"), -1) // int < string
+	test.EqualInt(t, td.Compare([]int{1, 2}, []int{1}), 1)
+	test.EqualInt(t, td.Compare(math.NaN(), math.NaN()), 0)
+	test.EqualInt(t, td.Compare(math.NaN(), math.Inf(-1)), -1)
+	test.EqualInt(t, td.Compare(a, a), 0)
+	test.EqualInt(t, td.Compare(a, b), -1)
+	test.EqualInt(t, td.Compare(b, a), 1)
+	test.EqualInt(t, td.Compare(
+		MyStruct{ValInt: 1, MyStructMid: MyStructMid{ValStr: "b"}},
+		MyStruct{ValInt: 1, MyStructMid: MyStructMid{ValStr: "a"}}), 1)
+
+	test.IsTrue(t, td.Less("abc", "abd"))
+	test.IsFalse(t, td.Less("abd", "abc"))
+	test.IsFalse(t, td.Less("abc", "abc"))
+}
+
+func TestSort(t *testing.T) {
+	ints := []int{3, 1, 2}
+	td.Sort(ints)
+	td.Cmp(t, ints, []int{1, 2, 3})
+
+	items := []interface{}{"b", 3, nil, 1.5, "a", math.NaN()}
+	td.Sort(items)
+	td.Cmp(t, items, []interface{}{nil, td.NaN(), 1.5, 3, "a", "b"})
+
+	type person struct {
+		Name string
+		Age  int
+	}
+	people := []*person{
+		{Name: "Bob", Age: 26},
+		{Name: "Alice", Age: 42},
+		nil,
+		{Name: "Alice", Age: 24},
+	}
+	td.Sort(people)
+	td.Cmp(t, people, []*person{
+		nil,
+		{Name: "Alice", Age: 24},
+		{Name: "Alice", Age: 42},
+		{Name: "Bob", Age: 26},
+	})
+
+	// Equal items keep their order
+	first, second := &person{Name: "Bob"}, &person{Name: "Bob"}
+	people = []*person{second, {Name: "Alice"}, first}
+	td.Sort(people)
+	td.Cmp(t, people[1], td.Shallow(second))
+	td.Cmp(t, people[2], td.Shallow(first))
+
+	var empty []string
+	td.Sort(empty)
+	td.CmpNil(t, empty)
+
+	test.CheckPanic(t, func() { td.Sort(nil) },
+		"usage: Sort(SLICE), but received nil as 1st parameter")
+	test.CheckPanic(t, func() { td.Sort([3]int{}) },
+		"usage: Sort(SLICE), but received [3]int (array) as 1st parameter")
+}
@@ -1055,6 +1055,48 @@ func ExampleT_JSON_file() {
 	// Full match from io.Reader: true
 }
 
+func ExampleT_JSONPath() {
+	t := td.NewT(&testing.T{})
+
+	got := json.RawMessage(`
+{
+  "store": {
+    "book": [
+      {"title": "Sayings of the Century", "price": 8.95},
+      {"title": "Sword of Honour", "price": 12.99},
+      {"title": "Moby Dick", "price": 8.99, "isbn": "0-553-21311-3"}
+    ],
+    "bicycle": {"color": "red", "price": 19.95}
+  }
+}`)
+
+	ok := t.JSONPath(got, "$..price", td.Bag(8.95, 8.99, 12.99, 19.95))
+	fmt.Println("All prices:", ok)
+
+	ok = t.JSONPath(got, "$.store.book[*].title", td.Len(3))
+	fmt.Println("3 book titles:", ok)
+
+	ok = t.JSONPath(got, "$.store.book[?(@.price < 10)].title", []string{"Sayings of the Century", "Moby Dick"})
+	fmt.Println("Cheap books:", ok)
+
+	ok = t.JSONPath(got, "$..book[?(@.isbn)].title", []string{"Moby Dick"})
+	fmt.Println("Books with ISBN:", ok)
+
+	ok = t.JSONPath(got, "$..price", td.ArrayEach(td.Lt(15)))
+	fmt.Println("All prices are lower than 15:", ok)
+
+	ok = t.JSONPath(got, "$..book[?(@.price > 100)]", td.Empty())
+	fmt.Println("No expensive books:", ok)
+
+	// Output:
+	// All prices: true
+	// 3 book titles: true
+	// Cheap books: true
+	// Books with ISBN: true
+	// All prices are lower than 15: false
+	// No expensive books: true
+}
+
 func ExampleT_JSONPointer_rfc6901() {
 	t := td.NewT(&testing.T{})
 
@@ -1237,6 +1279,58 @@ func ExampleT_JSONPointer_has_hasnt() {
 	// Britt hasn't children: false
 }
 
+func ExampleT_JSONSchema() {
+	t := td.NewT(&testing.T{})
+
+	type Person struct {
+		Name string   `json:"name"`
+		Age  int      `json:"age"`
+		Tags []string `json:"tags,omitempty"`
+	}
+
+	schema := `
+{
+  "type": "object",
+  "required": ["name", "age"],
+  "properties": {
+    "name": {"type": "string", "minLength": 1},
+    "age":  {"type": "integer", "minimum": 0, "maximum": 150},
+    "tags": {"type": "array", "items": {"$ref": "#/definitions/tag"}}
+  },
+  "definitions": {
+    "tag": {"type": "string", "pattern": "^[a-z]+$"}
+  }
+}`
+
+	got := Person{Name: "Bob", Age: 42, Tags: []string{"admin", "dev"}}
+	ok := t.JSONSchema(got, schema)
+	fmt.Println("Bob is valid:", ok)
+
+	got = Person{Name: "Alice", Age: 200}
+	ok = t.JSONSchema(got, schema)
+	fmt.Println("Alice is valid:", ok)
+
+	got = Person{Name: "Britt", Age: 21, Tags: []string{"Admin"}}
+	ok = t.JSONSchema(got, schema)
+	fmt.Println("Britt is valid:", ok)
+
+	// Can be embedded in JSON operator
+	ok = t.Cmp(got, td.JSON(`
+{
+  "name": "Britt",
+  "age":  JSONSchema({"type": "integer", "minimum": 18}),
+  "tags": $1
+}`,
+		td.JSONSchema(`{"type": "array", "maxItems": 2}`)))
+	fmt.Println("Britt is an adult with at most 2 tags:", ok)
+
+	// Output:
+	// Bob is valid: true
+	// Alice is valid: false
+	// Britt is valid: false
+	// Britt is an adult with at most 2 tags: true
+}
+
 func ExampleT_Keys() {
 	t := td.NewT(&testing.T{})
 
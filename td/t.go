@@ -339,6 +339,25 @@ func (t *T) JSON(got, expectedJSON interface{}, params []interface{}, args ...in
 	return t.Cmp(got, JSON(expectedJSON, params...), args...)
 }
 
+// JSONPath is a shortcut for:
+//
+//   t.Cmp(got, td.JSONPath(path, expectedValue), args...)
+//
+// See https://pkg.go.dev/github.com/maxatome/go-testdeep/td#JSONPath for details.
+//
+// Returns true if the test is OK, false if it fails.
+//
+// "args..." are optional and allow to name the test. This name is
+// used in case of failure to qualify the test. If len(args) > 1 and
+// the first item of "args" is a string and contains a '%' rune then
+// fmt.Fprintf is used to compose the name, else "args" are passed to
+// fmt.Fprint. Do not forget it is the name of the test, not the
+// reason of a potential failure.
+func (t *T) JSONPath(got interface{}, path string, expectedValue interface{}, args ...interface{}) bool {
+	t.Helper()
+	return t.Cmp(got, JSONPath(path, expectedValue), args...)
+}
+
 // JSONPointer is a shortcut for:
 //
 //   t.Cmp(got, td.JSONPointer(pointer, expectedValue), args...)
@@ -358,6 +377,25 @@ func (t *T) JSONPointer(got interface{}, pointer string, expectedValue interface
 	return t.Cmp(got, JSONPointer(pointer, expectedValue), args...)
 }
 
+// JSONSchema is a shortcut for:
+//
+//   t.Cmp(got, td.JSONSchema(schema), args...)
+//
+// See https://pkg.go.dev/github.com/maxatome/go-testdeep/td#JSONSchema for details.
+//
+// Returns true if the test is OK, false if it fails.
+//
+// "args..." are optional and allow to name the test. This name is
+// used in case of failure to qualify the test. If len(args) > 1 and
+// the first item of "args" is a string and contains a '%' rune then
+// fmt.Fprintf is used to compose the name, else "args" are passed to
+// fmt.Fprint. Do not forget it is the name of the test, not the
+// reason of a potential failure.
+func (t *T) JSONSchema(got, schema interface{}, args ...interface{}) bool {
+	t.Helper()
+	return t.Cmp(got, JSONSchema(schema), args...)
+}
+
 // Keys is a shortcut for:
 //
 //   t.Cmp(got, td.Keys(val), args...)
@@ -95,8 +95,10 @@ func (u tdJSONUnmarshaler) replaceLocation(tdOp TestDeep, posInJSON json.Positio
 	tdOp.replaceLocation(newPos)
 }
 
-// unmarshal unmarshals "expectedJSON" using placeholder parameters "params".
-func (u tdJSONUnmarshaler) unmarshal(expectedJSON interface{}, params []interface{}) interface{} {
+// read returns the JSON content of "expectedJSON", a JSON string, a
+// JSON filename, a []byte or an io.Reader. "usage" is the usage of
+// the operator function, used in case of a bad "expectedJSON" type.
+func (u tdJSONUnmarshaler) read(expectedJSON interface{}, usage string) []byte {
 	var (
 		err error
 		b   []byte
@@ -127,11 +129,16 @@ func (u tdJSONUnmarshaler) unmarshal(expectedJSON interface{}, params []interfac
 		}
 
 	default:
-		panic(color.BadUsage(
-			u.Func+"(STRING_JSON|STRING_FILENAME|[]byte|io.Reader, ...)",
-			expectedJSON, 1, false))
+		panic(color.BadUsage(u.Func+usage, expectedJSON, 1, false))
 	}
 
+	return b
+}
+
+// unmarshal unmarshals "expectedJSON" using placeholder parameters "params".
+func (u tdJSONUnmarshaler) unmarshal(expectedJSON interface{}, params []interface{}) interface{} {
+	b := u.read(expectedJSON, "(STRING_JSON|STRING_FILENAME|[]byte|io.Reader, ...)")
+
 	params = flat.Interfaces(params...)
 	var byTag map[string]interface{}
 
@@ -515,15 +522,18 @@ func jsonify(ctx ctxerr.Context, got reflect.Value) (interface{}, *ctxerr.Error)
 //
 // A few notes about operators embedding:
 //   - SubMapOf and SuperMapOf take only one parameter, a JSON object;
+//   - JSONSchema takes only one parameter, the JSON schema, generally
+//     a JSON object;
 //   - the optional 3rd parameter of Between has to be specified as a string
 //     and can be: "[]" or "BoundsInIn" (default), "[[" or "BoundsInOut",
 //     "]]" or "BoundsOutIn", "][" or "BoundsOutOut";
 //   - not all operators are embeddable only the following are;
 //   - All, Any, ArrayEach, Bag, Between, Contains, ContainsKey, Empty, Gt,
-//     Gte, HasPrefix, HasSuffix, Ignore, JSONPointer, Keys, Len, Lt, Lte,
-//     MapEach, N, NaN, Nil, None, Not, NotAny, NotEmpty, NotNaN, NotNil,
-//     NotZero, Re, ReAll, Set, SubBagOf, SubMapOf, SubSetOf, SuperBagOf,
-//     SuperMapOf, SuperSetOf, Values and Zero.
+//     Gte, HasPrefix, HasSuffix, Ignore, JSONPath, JSONPointer,
+//     JSONSchema, Keys, Len, Lt, Lte, MapEach, N, NaN, Nil, None, Not,
+//     NotAny, NotEmpty, NotNaN, NotNil, NotZero, Re, ReAll, Set,
+//     SubBagOf, SubMapOf, SubSetOf, SuperBagOf, SuperMapOf, SuperSetOf,
+//     Values and Zero.
 //
 // Operators taking no parameters can also be directly embedded in
 // JSON data using $^OperatorName or "$^OperatorName" notation. They
@@ -0,0 +1,219 @@
+// Copyright (c) 2021, Maxime Soulé
+// All rights reserved.
+//
+// This source code is licensed under the BSD-style license found in the
+// LICENSE file in the root directory of this source tree.
+
+package td
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/maxatome/go-testdeep/internal/color"
+	"github.com/maxatome/go-testdeep/internal/ctxerr"
+	"github.com/maxatome/go-testdeep/internal/jsonpath"
+	"github.com/maxatome/go-testdeep/internal/util"
+)
+
+type tdJSONPath struct {
+	tdSmugglerBase
+	src  string
+	path *jsonpath.Path
+}
+
+var _ TestDeep = &tdJSONPath{}
+
+// summary(JSONPath): compares against JSON representation using a
+// JSONPath returning all selected values
+// input(JSONPath): nil,bool,str,int,float,array,slice,map,struct,ptr
+
+// JSONPath is a smuggler operator. It takes the JSON representation
+// of data, selects all the values matching the JSONPath "path" and
+// compares the []interface{} containing them to "expectedValue".
+//
+// Contrary to JSONPointer, "path" can select several values. It
+// supports:
+//   - $ the root value, mandatory at the beginning of "path";
+//   - .name or ['name'] for an object member, ["name"] works too;
+//   - [n] for an array item, negative indexes counting from the end;
+//   - .* or [*] for all members of an object or all items of an array;
+//   - ..name, ..* or ..[…] to select recursively in all descendants;
+//   - [start:end:step] for an array slice, each part being optional;
+//   - [a,b,…] for a union of several of the previous selectors;
+//   - [?(expr)] or [?expr] to keep all children for which the filter
+//     expression is true. A filter expression can use @ (the current
+//     child) and $ (the root) followed by a path, string, number,
+//     true, false and null literals, comparison operators ==, !=, <,
+//     <=, >, >=, =~ (the right operand being a regexp as /re/ or
+//     /re/i or a string), logical operators &&, || and ! and
+//     parentheses. A path used alone tests its existence.
+//
+// Selected values are sorted in document order, members of an object
+// being walked in lexical order of their keys.
+//
+//   got := map[string]interface{}{
+//     "store": map[string]interface{}{
+//       "book": []map[string]interface{}{
+//         {"title": "Moby Dick", "price": 8.99},
+//         {"title": "The Lord of the Rings", "price": 22.99,
+//           "isbn": "0-395-19395-8"},
+//       },
+//       "bicycle": map[string]interface{}{"price": 19.95},
+//     },
+//   }
+//   td.Cmp(t, got, td.JSONPath("$..price", td.Bag(8.99, 19.95, 22.99)))
+//   td.Cmp(t, got, td.JSONPath("$.store.book[*].title", td.Len(2)))
+//   td.Cmp(t, got, td.JSONPath("$..book[?(@.price < 10)].title",
+//     []string{"Moby Dick"}))
+//   td.Cmp(t, got, td.JSONPath("$..book[?(@.isbn)]", td.Len(1)))
+//   td.Cmp(t, got, td.JSONPath("$..price", td.ArrayEach(td.Gt(5))))
+//
+// When "expectedValue" is a slice, or when the type behind the
+// "expectedValue" operator is a slice, the selected values are
+// converted to this slice type before the comparison, using
+// encoding/json. Otherwise, the comparison is done in Lax mode to
+// simplify numeric tests. If no values are selected, an empty
+// []interface{} is compared to "expectedValue", so
+// td.JSONPath(path, td.Empty()) checks that nothing matches "path".
+//
+// In case of failure, the location of each faulty selected value
+// replaces the JSONPath in the path of the error, as in
+// DATA.JSONPath<$.store.book[1].price> instead of
+// DATA.JSONPath<$..price>[2].
+//
+// When JSONPath is embedded in JSON, SubJSONOf or SuperJSONOf, the
+// leading $ of "path" has to be doubled, as a string starting with $
+// is a placeholder:
+//
+//   td.Cmp(t, got, td.SuperJSONOf(`{"store": JSONPath("$$..price", [19.95, 8.99, 22.99])}`))
+//
+// TypeBehind method always returns nil as the expected type cannot be
+// guessed from a JSONPath.
+func JSONPath(path string, expectedValue interface{}) TestDeep {
+	p := tdJSONPath{
+		tdSmugglerBase: newSmugglerBase(expectedValue),
+		src:            path,
+	}
+
+	var err error
+	p.path, err = jsonpath.Parse(path)
+	if err != nil {
+		panic(color.Bad("JSONPath(): bad JSONPath %q: %s", path, err))
+	}
+
+	if !p.isTestDeeper {
+		p.expectedValue = reflect.ValueOf(expectedValue)
+	}
+	return &p
+}
+
+func (p *tdJSONPath) Match(ctx ctxerr.Context, got reflect.Value) *ctxerr.Error {
+	vgot, eErr := jsonify(ctx, got)
+	if eErr != nil {
+		return ctx.CollectError(eErr)
+	}
+
+	nodes := p.path.Select(vgot)
+	values := make([]interface{}, len(nodes))
+	for i, node := range nodes {
+		values[i] = node.Value
+	}
+
+	ctx = ctx.AddCustomLevel(".JSONPath<" + p.src + ">")
+	ctx.BeLax = true
+
+	numErrors := 0
+	if ctx.Errors != nil {
+		numErrors = len(*ctx.Errors)
+	}
+
+	vvalues := reflect.ValueOf(values)
+
+	// Convert values to the slice type of expected, if any
+	if expectedType := p.internalTypeBehind(); expectedType != nil &&
+		expectedType.Kind() == reflect.Slice &&
+		expectedType != vvalues.Type() {
+		b, _ := json.Marshal(values) // No error can occur here
+
+		newValues := reflect.New(expectedType)
+		if err := json.Unmarshal(b, newValues.Interface()); err != nil {
+			if ctx.BooleanError {
+				return ctxerr.BooleanError
+			}
+			return ctx.CollectError(&ctxerr.Error{
+				Message: fmt.Sprintf(
+					"an error occurred while unmarshalling JSON into %s", expectedType),
+				Summary: ctxerr.NewSummary(err.Error()),
+			})
+		}
+		vvalues = newValues.Elem()
+	}
+
+	err := deepValueEqual(ctx, vvalues, p.expectedValue)
+	if ctx.BooleanError || ctx.Path == nil {
+		return err
+	}
+
+	// Replace .JSONPath<path>[idx] by the location of the idx-th value
+	for e := err; e != nil; e = e.Next {
+		p.relocate(ctx.Path, e, nodes)
+	}
+	if ctx.Errors != nil {
+		for _, e := range (*ctx.Errors)[numErrors:] {
+			p.relocate(ctx.Path, e, nodes)
+		}
+	}
+	return err
+}
+
+// relocate replaces in the path of "err" and of its origin, the
+// level JSONPath<src> followed by an index of "nodes", by the
+// location of the corresponding node.
+func (p *tdJSONPath) relocate(path ctxerr.Path, err *ctxerr.Error, nodes []jsonpath.Node) {
+	for ; err != nil; err = err.Origin {
+		errPath := err.Context.Path
+		pos := len(path) - 1
+		if len(errPath) <= len(path) || !errPath[:len(path)].Equal(path) {
+			return
+		}
+		idx, ok := errPath.ArrayIndexAt(pos + 1)
+		if !ok || idx >= len(nodes) {
+			return
+		}
+		err.Context.Path = errPath.ReplaceLevels(pos, 2,
+			".JSONPath<"+nodes[idx].Location+">")
+	}
+}
+
+func (p *tdJSONPath) String() string {
+	var expected string
+	switch {
+	case p.isTestDeeper:
+		expected = p.expectedValue.Interface().(TestDeep).String()
+	case p.expectedValue.IsValid():
+		expected = util.ToString(p.expectedValue.Interface())
+	default:
+		expected = "nil"
+	}
+	return fmt.Sprintf("JSONPath(%s, %s)", p.src, expected)
+}
+
+func (p *tdJSONPath) internalTypeBehind() reflect.Type {
+	if p.isTestDeeper {
+		return p.expectedValue.Interface().(TestDeep).TypeBehind()
+	}
+	if p.expectedValue.IsValid() {
+		return p.expectedValue.Type()
+	}
+	return nil
+}
+
+func (p *tdJSONPath) TypeBehind() reflect.Type {
+	return nil
+}
+
+func (p *tdJSONPath) HandleInvalid() bool {
+	return true
+}
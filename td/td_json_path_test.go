@@ -0,0 +1,138 @@
+// Copyright (c) 2021, Maxime Soulé
+// All rights reserved.
+//
+// This source code is licensed under the BSD-style license found in the
+// LICENSE file in the root directory of this source tree.
+
+package td_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/maxatome/go-testdeep/internal/test"
+	"github.com/maxatome/go-testdeep/td"
+)
+
+func TestJSONPath(t *testing.T) {
+	type item struct {
+		Name  string   `json:"name"`
+		Price float64  `json:"price"`
+		Tags  []string `json:"tags,omitempty"`
+	}
+	type shop struct {
+		Items []item         `json:"items"`
+		Meta  map[string]int `json:"meta"`
+	}
+
+	got := shop{
+		Items: []item{
+			{Name: "apple", Price: 1.5, Tags: []string{"fruit"}},
+			{Name: "bread", Price: 3},
+			{Name: "cherry", Price: 12, Tags: []string{"fruit", "red"}},
+		},
+		Meta: map[string]int{"count": 3, "version": 2},
+	}
+
+	//
+	// OK
+	checkOK(t, got, td.JSONPath("$.items[*].name",
+		[]interface{}{"apple", "bread", "cherry"}))
+	checkOK(t, got, td.JSONPath("$.items[*].name",
+		[]string{"apple", "bread", "cherry"}))
+	checkOK(t, &got, td.JSONPath("$..price", td.Bag(3, 12, 1.5)))
+	checkOK(t, got, td.JSONPath("$..price", td.ArrayEach(td.Between(1, 15))))
+	checkOK(t, got, td.JSONPath("$..tags[*]", td.Set("fruit", "red")))
+	checkOK(t, got, td.JSONPath("$.items[?(@.tags)]", td.Len(2)))
+	checkOK(t, got, td.JSONPath("$.items[?(@.price > 2)].name",
+		[]string{"bread", "cherry"}))
+	checkOK(t, got, td.JSONPath("$.items[-1:]", []item{got.Items[2]}))
+	checkOK(t, got, td.JSONPath("$.meta.*", []int{3, 2}))
+	checkOK(t, got, td.JSONPath("$.unknown", td.Empty()))
+	checkOK(t, got, td.JSONPath("$", []shop{got}))
+	checkOK(t, nil, td.JSONPath("$", []interface{}{nil}))
+	checkOK(t, json.RawMessage(`[1,[2,[3]]]`),
+		td.JSONPath("$..*", td.Contains(3.0)))
+
+	//
+	// Errors
+	checkError(t, got, td.JSONPath("$..price", []float64{1.5, 3, 13}),
+		expectedError{
+			Message:  mustBe("values differ"),
+			Path:     mustBe("DATA.JSONPath<$.items[2].price>"),
+			Got:      mustBe("(float64) 12"),
+			Expected: mustBe("(float64) 13"),
+		})
+
+	checkError(t, got,
+		td.JSONPath("$.items[*]", td.ArrayEach(td.SuperMapOf(map[string]interface{}{
+			"price": td.Lt(10),
+		}, nil))),
+		expectedError{
+			Message:  mustBe("values differ"),
+			Path:     mustBe(`DATA.JSONPath<$.items[2]>["price"]`),
+			Got:      mustBe("12"),
+			Expected: mustBe("< 10"),
+		})
+
+	checkError(t, got, td.JSONPath("$..price", td.ArrayEach(td.Lt(10))),
+		expectedError{
+			Message:  mustBe("values differ"),
+			Path:     mustBe("DATA.JSONPath<$.items[2].price>"),
+			Got:      mustBe("12"),
+			Expected: mustBe("< 10"),
+		})
+
+	checkError(t, got, td.JSONPath("$..price", td.Len(2)),
+		expectedError{
+			Message:  mustBe("bad length"),
+			Path:     mustBe("DATA.JSONPath<$..price>"),
+			Got:      mustBe("3"),
+			Expected: mustBe("2"),
+		})
+
+	checkError(t, got, td.JSONPath("$..name", []int{1}),
+		expectedError{
+			Message: mustBe("an error occurred while unmarshalling JSON into []int"),
+			Path:    mustBe("DATA.JSONPath<$..name>"),
+			Summary: mustContain("cannot unmarshal string"),
+		})
+
+	checkError(t, func() {}, td.JSONPath("$", td.Ignore()),
+		expectedError{
+			Message: mustBe("json.Marshal failed"),
+			Path:    mustBe("DATA"),
+			Summary: mustContain("json: unsupported type"),
+		})
+
+	// Several errors, each one with its own location
+	ttt := test.NewTestingT()
+	td.Cmp(ttt, got, td.JSONPath("$..price", td.ArrayEach(td.Gt(2))))
+	test.IsTrue(t, strings.Contains(ttt.LastMessage(), "DATA.JSONPath<$.items[0].price>: values differ"))
+	test.IsFalse(t, strings.Contains(ttt.LastMessage(), "DATA.JSONPath<$.items[1].price>"))
+
+	//
+	// Embedded in JSON
+	checkOK(t, got, td.SuperJSONOf(`{"items": JSONPath("$$[*].price", [1.5, 3, 12])}`))
+
+	//
+	// String
+	test.EqualStr(t, td.JSONPath("$..x", td.Len(2)).String(),
+		"JSONPath($..x, len=2)")
+	test.EqualStr(t, td.JSONPath("$..x", []int{2}).String(),
+		"JSONPath($..x, ([]int) (len=1 cap=1) {\n (int) 2\n})")
+	test.EqualStr(t, td.JSONPath("$..x", nil).String(),
+		"JSONPath($..x, nil)")
+
+	//
+	// Bad usage
+	test.CheckPanic(t, func() { td.JSONPath("x", 1234) },
+		`JSONPath(): bad JSONPath "x": path must start with $ at position 0`)
+	test.CheckPanic(t, func() { td.JSONPath("$[?(@.a ==)]", 1234) },
+		`JSONPath(): bad JSONPath "$[?(@.a ==)]": operand expected instead of ")]" at position 10`)
+}
+
+func TestJSONPathTypeBehind(t *testing.T) {
+	equalTypes(t, td.JSONPath("$", 42), nil)
+}
@@ -0,0 +1,197 @@
+// Copyright (c) 2021, Maxime Soulé
+// All rights reserved.
+//
+// This source code is licensed under the BSD-style license found in the
+// LICENSE file in the root directory of this source tree.
+
+package td
+
+import (
+	ejson "encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/maxatome/go-testdeep/internal/color"
+	"github.com/maxatome/go-testdeep/internal/ctxerr"
+	"github.com/maxatome/go-testdeep/internal/jsonschema"
+)
+
+type tdJSONSchema struct {
+	baseOKNil
+	raw    interface{}
+	schema *jsonschema.Schema
+}
+
+var _ TestDeep = &tdJSONSchema{}
+
+// summary(JSONSchema): checks JSON representation against a JSON Schema
+// input(JSONSchema): nil,bool,str,int,float,array,slice,map,struct,ptr
+
+// JSONSchema operator checks that the JSON representation of data
+// is valid against the JSON Schema "schema" (draft 7 or 2019-09).
+// "schema" can be a:
+//
+//   - string containing a JSON schema like `{"type":"integer"}`
+//   - string containing a JSON schema filename, ending with ".json"
+//     (its content is ioutil.ReadFile before unmarshaling)
+//   - []byte containing a JSON schema
+//   - io.Reader stream containing a JSON schema (is ioutil.ReadAll
+//     before unmarshaling)
+//
+//   td.Cmp(t, gotValue, td.JSONSchema(`{
+//     "type": "object",
+//     "required": ["id", "name"],
+//     "properties": {
+//       "id":   {"type": "integer", "minimum": 1},
+//       "name": {"type": "string", "minLength": 1},
+//       "tags": {"type": "array", "items": {"$ref": "#/$defs/tag"}}
+//     },
+//     "$defs": {
+//       "tag": {"type": "string", "pattern": "^[a-z]+$"}
+//     }
+//   }`))
+//   td.Cmp(t, gotValue, td.JSONSchema("testdata/person.schema.json"))
+//
+// Supported keywords are: type, enum, const, nullable, combinators
+// (allOf, anyOf, oneOf, not and if/then/else), multipleOf, minimum,
+// maximum, exclusiveMinimum, exclusiveMaximum, minLength, maxLength,
+// pattern, format, items (a single schema), minItems, maxItems,
+// uniqueItems, properties, required, additionalProperties,
+// minProperties, maxProperties and $ref pointing inside the schema
+// document, overriding its sibling keywords. The following formats
+// are checked: date-time, date, time, email, hostname, ipv4, ipv6,
+// uri, uri-reference, uuid and regex for strings, int32 and int64
+// for numbers. Other formats are annotations and are ignored, as
+// the specification requires. Using any other validation keyword
+// (as contains or patternProperties) or a remote $ref is reported as
+// a violation.
+//
+// Each violation is reported as a separate error, the path of the
+// invalid value being appended to the DATA path, following the JSON
+// representation of data: DATA["tags"][2] for the instance path
+// /tags/2. Note that the number of reported errors is limited by
+// ContextConfig.MaxErrors.
+//
+// JSONSchema can also be embedded in JSON, SubJSONOf and SuperJSONOf
+// operators, the schema being then directly given as a JSON object:
+//
+//   td.Cmp(t, gotValue, td.JSON(`{
+//     "id":   JSONSchema({"type": "integer", "minimum": 1}),
+//     "name": "Bob"
+//   }`))
+//
+// or as a placeholder:
+//
+//   td.Cmp(t, gotValue, td.JSON(`{"id": $1, "name": "Bob"}`,
+//     td.JSONSchema("testdata/id.schema.json")))
+//
+// TypeBehind method always returns nil as the expected type cannot be
+// guessed from a JSON schema.
+func JSONSchema(schema interface{}) TestDeep {
+	s := tdJSONSchema{
+		baseOKNil: newBaseOKNil(3),
+	}
+
+	switch schema := schema.(type) {
+	// Already unmarshaled schema, when embedded in JSON
+	case map[string]interface{}, bool:
+		s.raw = schema
+
+	default:
+		b := newJSONUnmarshaler(s.GetLocation()).
+			read(schema, "(STRING_JSON|STRING_FILENAME|[]byte|io.Reader)")
+		err := ejson.Unmarshal(b, &s.raw)
+		if err != nil {
+			panic(color.Bad("JSONSchema(): JSON schema unmarshal error: %s", err))
+		}
+		switch s.raw.(type) {
+		case map[string]interface{}, bool:
+		default:
+			panic(color.Bad("JSONSchema(): JSON schema must be an object or a boolean, not %s",
+				b))
+		}
+	}
+
+	s.schema = jsonschema.New(s.raw)
+	return &s
+}
+
+func (s *tdJSONSchema) Match(ctx ctxerr.Context, got reflect.Value) *ctxerr.Error {
+	vgot, err := jsonify(ctx, got)
+	if err != nil {
+		return ctx.CollectError(err)
+	}
+
+	violations := s.schema.Validate(vgot)
+	if len(violations) == 0 {
+		return nil
+	}
+	if ctx.BooleanError {
+		return ctxerr.BooleanError
+	}
+
+	for _, violation := range violations {
+		var message string
+		switch violation.Keyword {
+		case "":
+			message = "invalid JSON schema"
+		case "false":
+			message = "JSON schema violation: false schema"
+		default:
+			message = "JSON schema violation: " + violation.Keyword
+		}
+
+		err = jsonSchemaContext(ctx, vgot, violation.InstancePath).
+			CollectError(&ctxerr.Error{
+				Message: message,
+				Summary: ctxerr.ErrorSummaryItems{
+					{Label: "reason", Value: violation.Message},
+					{Label: "schema", Value: violation.SchemaPath},
+				},
+			})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *tdJSONSchema) String() string {
+	return jsonStringify("JSONSchema", reflect.ValueOf(s.raw))
+}
+
+func (s *tdJSONSchema) TypeBehind() reflect.Type {
+	return nil
+}
+
+var jsonSchemaPointerEsc = strings.NewReplacer("~1", "/", "~0", "~")
+
+// jsonSchemaContext returns "ctx" with the JSON pointer "pointer"
+// appended to its path, following the JSON representation "got".
+func jsonSchemaContext(ctx ctxerr.Context, got interface{}, pointer string) ctxerr.Context {
+	if pointer == "" {
+		return ctx
+	}
+
+	for _, part := range strings.Split(pointer[1:], "/") {
+		part = jsonSchemaPointerEsc.Replace(part)
+
+		switch g := got.(type) {
+		case []interface{}:
+			if idx, err := strconv.Atoi(part); err == nil && idx >= 0 && idx < len(g) {
+				ctx = ctx.AddArrayIndex(idx)
+				got = g[idx]
+				continue
+			}
+		case map[string]interface{}:
+			ctx = ctx.AddMapKey(part)
+			got = g[part]
+			continue
+		}
+		// Should not happen as instance paths always exist
+		ctx = ctx.AddCustomLevel("/" + part)
+		got = nil
+	}
+	return ctx
+}
@@ -0,0 +1,205 @@
+// Copyright (c) 2021, Maxime Soulé
+// All rights reserved.
+//
+// This source code is licensed under the BSD-style license found in the
+// LICENSE file in the root directory of this source tree.
+
+package td_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/maxatome/go-testdeep/internal/test"
+	"github.com/maxatome/go-testdeep/td"
+)
+
+func TestJSONSchema(t *testing.T) {
+	type person struct {
+		Name     string   `json:"name"`
+		Age      int      `json:"age"`
+		Emails   []string `json:"emails,omitempty"`
+		Children []person `json:"children,omitempty"`
+	}
+
+	schema := `
+{
+  "type": "object",
+  "required": ["name", "age"],
+  "properties": {
+    "name":     {"type": "string", "minLength": 1},
+    "age":      {"type": "integer", "minimum": 0},
+    "emails":   {"type": "array", "items": {"type": "string", "format": "email"}},
+    "children": {"type": "array", "items": {"$ref": "#"}}
+  }
+}`
+
+	//
+	// OK
+	checkOK(t, person{Name: "Bob", Age: 42}, td.JSONSchema(schema))
+	checkOK(t,
+		&person{
+			Name:     "Bob",
+			Age:      42,
+			Emails:   []string{"bob@example.com"},
+			Children: []person{{Name: "Alice", Age: 12}},
+		},
+		td.JSONSchema([]byte(schema)))
+	checkOK(t, json.RawMessage(`{"name":"Bob","age":42}`),
+		td.JSONSchema(strings.NewReader(schema)))
+	checkOK(t, 12, td.JSONSchema(`{"type": "integer"}`))
+	checkOK(t, nil, td.JSONSchema(`{"type": "null"}`))
+	checkOK(t, "anything", td.JSONSchema(`true`))
+	checkOK(t, map[string]string{"d": "2021-01-01T00:00:00Z"},
+		td.JSONSchema(`{"properties":{"d":{"type":"string","format":"date-time"}}}`))
+	checkOK(t, map[string]float64{"f": 1.5},
+		td.JSONSchema(`{"properties":{"f":{"type":"number","format":"double"}}}`))
+
+	//
+	// Errors
+	checkError(t, person{Name: "Bob", Age: -1}, td.JSONSchema(schema),
+		expectedError{
+			Message: mustBe("JSON schema violation: minimum"),
+			Path:    mustBe(`DATA["age"]`),
+			Summary: mustBe("reason: -1 is less than minimum 0\nschema: #/properties/age/minimum"),
+		})
+
+	checkError(t,
+		person{
+			Name: "Bob",
+			Age:  42,
+			Children: []person{
+				{Name: "Alice", Age: 12},
+				{Name: "Britt", Age: 10, Emails: []string{"britt"}},
+			},
+		},
+		td.JSONSchema(schema),
+		expectedError{
+			Message: mustBe("JSON schema violation: format"),
+			Path:    mustBe(`DATA["children"][1]["emails"][0]`),
+			Summary: mustBe(`reason: "britt" is not a valid email` + "\n" +
+				"schema: #/properties/emails/items/format"),
+		})
+
+	checkError(t, map[string]interface{}{"age": 42}, td.JSONSchema(schema),
+		expectedError{
+			Message: mustBe("JSON schema violation: required"),
+			Path:    mustBe("DATA"),
+			Summary: mustBe(`reason: missing required property "name"` + "\n" +
+				"schema: #/required"),
+		})
+
+	checkError(t, map[string]int{"a/b": 1},
+		td.JSONSchema(`{"additionalProperties": {"type": "string"}}`),
+		expectedError{
+			Message: mustBe("JSON schema violation: type"),
+			Path:    mustBe(`DATA["a/b"]`),
+			Summary: mustBe("reason: 1 is not of type string\nschema: #/additionalProperties/type"),
+		})
+
+	checkError(t, map[string]string{"kind": "dog"},
+		td.JSONSchema(`{
+  "if":   {"properties": {"kind": {"const": "dog"}}},
+  "then": {"required": ["breed"]}
+}`),
+		expectedError{
+			Message: mustBe("JSON schema violation: required"),
+			Path:    mustBe("DATA"),
+			Summary: mustBe(`reason: missing required property "breed"` + "\n" +
+				"schema: #/then/required"),
+		})
+
+	checkError(t, 1, td.JSONSchema(`false`),
+		expectedError{
+			Message: mustBe("JSON schema violation: false schema"),
+			Path:    mustBe("DATA"),
+			Summary: mustBe("reason: no value is allowed by false schema\nschema: #"),
+		})
+
+	checkError(t, map[string]int{"a": 1},
+		td.JSONSchema(`{"properties": {"a": 12}}`),
+		expectedError{
+			Message: mustBe("invalid JSON schema"),
+			Path:    mustBe(`DATA["a"]`),
+			Summary: mustBe("reason: invalid schema of type float64\nschema: #/properties/a"),
+		})
+
+	checkError(t, func() {}, td.JSONSchema(`true`),
+		expectedError{
+			Message: mustBe("json.Marshal failed"),
+			Path:    mustBe("DATA"),
+			Summary: mustContain("json: unsupported type"),
+		})
+
+	//
+	// Several errors
+	ttt := test.NewTestingT()
+	td.Cmp(ttt, person{Name: "", Age: -1}, td.JSONSchema(schema))
+	test.IsTrue(t, strings.Contains(ttt.LastMessage(), `DATA["name"]: JSON schema violation: minLength`))
+	test.IsTrue(t, strings.Contains(ttt.LastMessage(), `DATA["age"]: JSON schema violation: minimum`))
+
+	//
+	// File
+	dir, err := ioutil.TempDir("", "td-json-schema")
+	if err != nil {
+		t.Fatalf("TempDir() failed: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "person.schema.json")
+	err = ioutil.WriteFile(filename, []byte(schema), 0644)
+	if err != nil {
+		t.Fatalf("WriteFile(%s) failed: %s", filename, err)
+	}
+	checkOK(t, person{Name: "Bob", Age: 42}, td.JSONSchema(filename))
+
+	//
+	// Embedded in JSON
+	got := map[string]interface{}{
+		"id":     12,
+		"person": person{Name: "Bob", Age: 42},
+	}
+	checkOK(t, got, td.JSON(`{
+  "id":     JSONSchema({"type": "integer", "minimum": 1}),
+  "person": $1
+}`,
+		td.JSONSchema(schema)))
+	checkOK(t, got, td.SuperJSONOf(`{"id": JSONSchema({"type": "integer"})}`))
+
+	checkError(t, got,
+		td.SuperJSONOf(`{"id": JSONSchema({"type": "integer", "maximum": 10})}`),
+		expectedError{
+			Message: mustBe("JSON schema violation: maximum"),
+			Path:    mustBe(`DATA["id"]`),
+			Summary: mustBe("reason: 12 is greater than maximum 10\nschema: #/maximum"),
+		})
+
+	//
+	// String
+	test.EqualStr(t, td.JSONSchema(`{"type":"integer"}`).String(),
+		`JSONSchema({
+             "type": "integer"
+           })`)
+	test.EqualStr(t, td.JSONSchema(`true`).String(), "JSONSchema(true)")
+
+	//
+	// Bad usage
+	test.CheckPanic(t, func() { td.JSONSchema(42) },
+		"usage: JSONSchema(STRING_JSON|STRING_FILENAME|[]byte|io.Reader), but received int as 1st parameter")
+	test.CheckPanic(t, func() { td.JSONSchema(`{`) },
+		"JSONSchema(): JSON schema unmarshal error: unexpected end of JSON input")
+	test.CheckPanic(t, func() { td.JSONSchema(`[1]`) },
+		"JSONSchema(): JSON schema must be an object or a boolean, not [1]")
+	test.CheckPanic(t, func() { td.JSONSchema(filepath.Join(dir, "unknown.json")) },
+		"JSONSchema(): JSON file "+filepath.Join(dir, "unknown.json")+" cannot be read: ")
+	test.CheckPanic(t, func() { td.JSON(`JSONSchema()`) },
+		"JSON(): JSON unmarshal error: JSONSchema() requires only one parameter at line 1:0 (pos 0)")
+}
+
+func TestJSONSchemaTypeBehind(t *testing.T) {
+	equalTypes(t, td.JSONSchema(`true`), nil)
+}
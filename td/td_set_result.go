@@ -66,6 +66,7 @@ func (r tdSetResult) Summary() ctxerr.ErrorSummary {
 		summary = append(summary, ctxerr.ErrorSummaryItem{
 			Label: missing,
 			Value: util.ToString(r.Missing),
+			Diff:  ctxerr.DiffDelete,
 		})
 	}
 
@@ -84,6 +85,7 @@ func (r tdSetResult) Summary() ctxerr.ErrorSummary {
 		summary = append(summary, ctxerr.ErrorSummaryItem{
 			Label: extra,
 			Value: util.ToString(r.Extra),
+			Diff:  ctxerr.DiffInsert,
 		})
 	}
 